@@ -60,3 +60,19 @@ func GetEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// StorageBackend devuelve el backend de archivos a usar: "supabase" (por defecto) o "local"
+func StorageBackend() string {
+	return strings.ToLower(strings.TrimSpace(GetEnv("STORAGE_BACKEND", "supabase")))
+}
+
+// StorageLocalDir devuelve el directorio donde el backend local guarda los archivos
+func StorageLocalDir() string {
+	return GetEnv("STORAGE_LOCAL_DIR", "./uploads")
+}
+
+// StoragePublicURL devuelve la URL base con la que se construyen los links del backend local
+func StoragePublicURL() string {
+	port := GetEnv("PORT", "8080")
+	return GetEnv("STORAGE_PUBLIC_URL", "http://localhost:"+port)
+}
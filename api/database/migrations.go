@@ -0,0 +1,441 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"TT-SEM-2-BACK/api/models"
+	"TT-SEM-2-BACK/api/units"
+
+	"gorm.io/gorm"
+)
+
+// migracion es un cambio de esquema o de datos que se aplica una sola vez
+type migracion struct {
+	ID string
+	Up func(tx *gorm.DB) error
+}
+
+// schemaMigration registra las migraciones ya aplicadas
+type schemaMigration struct {
+	ID        string    `gorm:"primaryKey;type:text"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// migraciones en orden de aplicación. NUNCA modificar una ya publicada, agregar una nueva al final
+var migraciones = []migracion{
+	{
+		// Estado pasa de bool a texto: true -> aprobado, false -> pendiente (o rechazado si lo último fue un rechazo)
+		ID: "0001_estado_material",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec(`
+				DO $$
+				BEGIN
+					IF EXISTS (
+						SELECT 1 FROM information_schema.columns
+						WHERE table_name = 'materials' AND column_name = 'estado' AND data_type = 'boolean'
+					) THEN
+						ALTER TABLE materials ALTER COLUMN estado DROP DEFAULT;
+						ALTER TABLE materials ALTER COLUMN estado TYPE text
+							USING (CASE WHEN estado THEN 'aprobado' ELSE 'pendiente' END);
+
+						IF to_regclass('notificaciones') IS NOT NULL THEN
+							UPDATE materials m SET estado = 'rechazado'
+							FROM (
+								SELECT DISTINCT ON (material_id) material_id, tipo, created_at
+								FROM notificaciones
+								WHERE material_id IS NOT NULL AND tipo IN ('aprobado', 'rechazado')
+								ORDER BY material_id, created_at DESC
+							) ultima
+							WHERE ultima.material_id = m.id
+								AND ultima.tipo = 'rechazado'
+								AND m.estado = 'pendiente'
+								AND m.updated_at <= ultima.created_at;
+						END IF;
+					END IF;
+				END $$;
+			`).Error
+		},
+	},
+	{
+		ID: "0002_esquema_base",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(
+				&models.Usuario{},
+				&models.Material{},
+				&models.ColaboradorMaterial{},
+				&models.PasoMaterial{},
+				&models.GaleriaMaterial{},
+				&models.Notificacion{},
+				&models.HistorialEstado{},
+			)
+		},
+	},
+	{
+		ID: "0003_estado_material_check",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				`UPDATE materials SET estado = 'borrador' WHERE estado IS NULL OR estado = ''`,
+				`ALTER TABLE materials DROP CONSTRAINT IF EXISTS chk_materials_estado`,
+				`ALTER TABLE materials ADD CONSTRAINT chk_materials_estado
+					CHECK (estado IN ('borrador', 'pendiente', 'aprobado', 'rechazado', 'archivado'))`,
+			)
+		},
+	},
+	{
+		// Revisiones de materiales publicados: a lo más una pendiente por material
+		ID: "0004_revisiones_material",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&models.RevisionMaterial{}); err != nil {
+				return err
+			}
+			return execAll(tx,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_revision_pendiente_unica
+					ON material_revisiones (material_id) WHERE estado = 'pendiente'`,
+			)
+		},
+	},
+	{
+		// Historial completo: cada material existente parte con su contenido actual como revisión
+		ID: "0005_historial_revisiones",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&models.RevisionMaterial{}); err != nil {
+				return err
+			}
+
+			var materiales []models.Material
+			if err := tx.
+				Preload("Pasos", func(db *gorm.DB) *gorm.DB { return db.Order("orden_paso ASC") }).
+				Preload("Galeria", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+				Where("NOT EXISTS (SELECT 1 FROM material_revisiones r WHERE r.material_id = materials.id AND r.estado IN ?)",
+					[]models.EstadoRevision{models.RevisionAplicada, models.RevisionAprobada}).
+				Find(&materiales).Error; err != nil {
+				return err
+			}
+
+			for _, m := range materiales {
+				var ultimo int
+				if err := tx.Model(&models.RevisionMaterial{}).Where("material_id = ?", m.ID).
+					Select("COALESCE(MAX(numero), 0)").Scan(&ultimo).Error; err != nil {
+					return err
+				}
+				if err := tx.Create(&models.RevisionMaterial{
+					MaterialID: m.ID,
+					Numero:     ultimo + 1,
+					Estado:     models.RevisionAplicada,
+					Contenido:  models.SnapshotDe(m),
+					AutorID:    m.CreadorID,
+				}).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// La revisión vigente de cada material aprobado pasa a ser su última versión aprobada
+		ID: "0006_revision_aprobada_vigente",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec(`
+				UPDATE material_revisiones r SET estado = 'aprobada'
+				FROM materials m
+				WHERE m.id = r.material_id
+					AND m.estado = 'aprobado'
+					AND r.estado = 'aplicada'
+					AND r.numero = (
+						SELECT MAX(r2.numero) FROM material_revisiones r2
+						WHERE r2.material_id = r.material_id AND r2.estado IN ('aplicada', 'aprobada')
+					)
+			`).Error
+		},
+	},
+	{
+		ID: "0007_comentarios_revision",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.ComentarioRevision{})
+		},
+	},
+	{
+		// Búsqueda de texto completo en español sin distinguir acentos. La columna busqueda la
+		// mantienen triggers para cubrir también los pasos, que viven en otra tabla
+		ID: "0008_busqueda_texto",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				`CREATE EXTENSION IF NOT EXISTS unaccent`,
+				`DO $$
+				BEGIN
+					IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'es_unaccent') THEN
+						CREATE TEXT SEARCH CONFIGURATION es_unaccent (COPY = spanish);
+						ALTER TEXT SEARCH CONFIGURATION es_unaccent
+							ALTER MAPPING FOR hword, hword_part, word WITH unaccent, spanish_stem;
+					END IF;
+				END $$`,
+				`ALTER TABLE materials ADD COLUMN IF NOT EXISTS busqueda tsvector`,
+				`CREATE INDEX IF NOT EXISTS idx_materials_busqueda ON materials USING GIN (busqueda)`,
+				// Nombre pesa más que composición y herramientas, y estas más que los textos largos
+				`CREATE OR REPLACE FUNCTION material_busqueda(m materials) RETURNS tsvector AS $$
+					SELECT
+						setweight(to_tsvector('es_unaccent', COALESCE(m.nombre, '')), 'A') ||
+						setweight(to_tsvector('es_unaccent', COALESCE((
+							SELECT string_agg(e->>'elemento', ' ')
+							FROM jsonb_array_elements(CASE WHEN jsonb_typeof(m.composicion) = 'array' THEN m.composicion ELSE '[]'::jsonb END) AS e
+						), '')), 'B') ||
+						setweight(to_tsvector('es_unaccent', COALESCE((
+							SELECT string_agg(h, ' ')
+							FROM jsonb_array_elements_text(CASE WHEN jsonb_typeof(m.herramientas) = 'array' THEN m.herramientas ELSE '[]'::jsonb END) AS h
+						), '')), 'B') ||
+						setweight(to_tsvector('es_unaccent', COALESCE(m.descripcion, '')), 'C') ||
+						setweight(to_tsvector('es_unaccent', COALESCE((
+							SELECT string_agg(p.descripcion, ' ')
+							FROM paso_materials p
+							WHERE p.material_id = m.id AND p.deleted_at IS NULL
+						), '')), 'D')
+				$$ LANGUAGE sql STABLE`,
+				`CREATE OR REPLACE FUNCTION materials_busqueda_trigger() RETURNS trigger AS $$
+				BEGIN
+					NEW.busqueda := material_busqueda(NEW);
+					RETURN NEW;
+				END $$ LANGUAGE plpgsql`,
+				`DROP TRIGGER IF EXISTS trg_materials_busqueda ON materials`,
+				`CREATE TRIGGER trg_materials_busqueda BEFORE INSERT OR UPDATE ON materials
+					FOR EACH ROW EXECUTE FUNCTION materials_busqueda_trigger()`,
+				`CREATE OR REPLACE FUNCTION pasos_busqueda_trigger() RETURNS trigger AS $$
+				BEGIN
+					UPDATE materials m SET busqueda = material_busqueda(m)
+					WHERE m.id = COALESCE(NEW.material_id, OLD.material_id);
+					RETURN NULL;
+				END $$ LANGUAGE plpgsql`,
+				`DROP TRIGGER IF EXISTS trg_pasos_busqueda ON paso_materials`,
+				`CREATE TRIGGER trg_pasos_busqueda AFTER INSERT OR UPDATE OR DELETE ON paso_materials
+					FOR EACH ROW EXECUTE FUNCTION pasos_busqueda_trigger()`,
+				`UPDATE materials m SET busqueda = material_busqueda(m)`,
+			)
+		},
+	},
+	{
+		// Valores normalizados de las propiedades mecánicas ya cargadas
+		ID: "0009_mecanicas_normalizadas",
+		Up: func(tx *gorm.DB) error {
+			var materiales []models.Material
+			if err := tx.Unscoped().Select("id", "propiedades_mecanicas").Find(&materiales).Error; err != nil {
+				return err
+			}
+			for _, m := range materiales {
+				for i, p := range m.PropiedadesMecanicas {
+					valor, base, _ := units.NormalizarPropiedad(p.Valor, p.Unidad)
+					m.PropiedadesMecanicas[i].ValorNormalizado = valor
+					m.PropiedadesMecanicas[i].UnidadNormalizada = base
+				}
+				if err := tx.Unscoped().Model(&models.Material{}).Where("id = ?", m.ID).
+					UpdateColumn("propiedades_mecanicas", m.PropiedadesMecanicas).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// Cantidades estructuradas de la composición para escalar recetas
+		ID: "0010_composicion_cantidades",
+		Up: func(tx *gorm.DB) error {
+			var materiales []models.Material
+			if err := tx.Unscoped().Select("id", "composicion").Find(&materiales).Error; err != nil {
+				return err
+			}
+			for _, m := range materiales {
+				for i, comp := range m.Composicion {
+					medida, err := units.NormalizarCantidad(comp.Cantidad)
+					if err != nil || medida == nil {
+						continue
+					}
+					valor := medida.Valor
+					m.Composicion[i].CantidadValor = &valor
+					m.Composicion[i].CantidadUnidad = medida.Unidad.Simbolo
+				}
+				if err := tx.Unscoped().Model(&models.Material{}).Where("id = ?", m.ID).
+					UpdateColumn("composicion", m.Composicion).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// Vocabulario controlado de elementos y herramientas
+		ID: "0011_vocabulario_terminos",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.Termino{})
+		},
+	},
+	{
+		// Catálogo de ingredientes con proveedor y precio para estimar costos
+		ID: "0012_ingredientes",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.Ingrediente{})
+		},
+	},
+	{
+		// Árbol de categorías y etiquetas libres. Las etiquetas también entran en la búsqueda de texto
+		ID: "0013_categorias_etiquetas",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&models.Categoria{}); err != nil {
+				return err
+			}
+			return execAll(tx,
+				`ALTER TABLE categorias DROP CONSTRAINT IF EXISTS fk_categorias_parent`,
+				`ALTER TABLE categorias ADD CONSTRAINT fk_categorias_parent
+					FOREIGN KEY (parent_id) REFERENCES categorias(id) ON DELETE SET NULL`,
+				`ALTER TABLE materials ADD COLUMN IF NOT EXISTS categoria_id uuid
+					REFERENCES categorias(id) ON DELETE SET NULL`,
+				`ALTER TABLE materials ADD COLUMN IF NOT EXISTS etiquetas jsonb NOT NULL DEFAULT '[]'::jsonb`,
+				`CREATE INDEX IF NOT EXISTS idx_materials_categoria_id ON materials (categoria_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_materials_etiquetas ON materials USING GIN (etiquetas)`,
+				`CREATE OR REPLACE FUNCTION material_busqueda(m materials) RETURNS tsvector AS $$
+					SELECT
+						setweight(to_tsvector('es_unaccent', COALESCE(m.nombre, '')), 'A') ||
+						setweight(to_tsvector('es_unaccent', COALESCE((
+							SELECT string_agg(e->>'elemento', ' ')
+							FROM jsonb_array_elements(CASE WHEN jsonb_typeof(m.composicion) = 'array' THEN m.composicion ELSE '[]'::jsonb END) AS e
+						), '')), 'B') ||
+						setweight(to_tsvector('es_unaccent', COALESCE((
+							SELECT string_agg(h, ' ')
+							FROM jsonb_array_elements_text(CASE WHEN jsonb_typeof(m.herramientas) = 'array' THEN m.herramientas ELSE '[]'::jsonb END) AS h
+						), '')), 'B') ||
+						setweight(to_tsvector('es_unaccent', COALESCE((
+							SELECT string_agg(t, ' ')
+							FROM jsonb_array_elements_text(CASE WHEN jsonb_typeof(m.etiquetas) = 'array' THEN m.etiquetas ELSE '[]'::jsonb END) AS t
+						), '')), 'B') ||
+						setweight(to_tsvector('es_unaccent', COALESCE(m.descripcion, '')), 'C') ||
+						setweight(to_tsvector('es_unaccent', COALESCE((
+							SELECT string_agg(p.descripcion, ' ')
+							FROM paso_materials p
+							WHERE p.material_id = m.id AND p.deleted_at IS NULL
+						), '')), 'D')
+				$$ LANGUAGE sql STABLE`,
+			)
+		},
+	},
+	{
+		// Solicitudes de rol persistentes. El índice parcial impide dos pendientes del mismo usuario
+		ID: "0014_solicitudes_rol",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&models.SolicitudRol{}); err != nil {
+				return err
+			}
+			return tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_solicitudes_rol_pendiente
+				ON solicitudes_rol (usuario_id) WHERE estado = 'pendiente'`).Error
+		},
+	},
+	{
+		// Invitaciones a colaborar. Un email solo puede tener una invitación pendiente por material
+		ID: "0015_invitaciones_colaborador",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&models.InvitacionColaborador{}); err != nil {
+				return err
+			}
+			// Los colaboradores ya asociados cuentan como invitaciones aceptadas
+			return execAll(tx,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_invitaciones_colaborador_pendiente
+					ON invitaciones_colaborador (material_id, email) WHERE estado = 'pendiente'`,
+				`INSERT INTO invitaciones_colaborador
+					(material_id, email, usuario_id, invitado_por_id, estado, respondida_en, created_at, updated_at)
+				SELECT mc.material_id, lower(u.email), mc.usuario_id, m.creador_id, 'aceptada', mc.created_at, mc.created_at, now()
+				FROM material_colaboradores mc
+				JOIN usuarios u ON u.google_id = mc.usuario_id
+				JOIN materials m ON m.id = mc.material_id
+				WHERE mc.deleted_at IS NULL AND NOT EXISTS (
+					SELECT 1 FROM invitaciones_colaborador i
+					WHERE i.material_id = mc.material_id AND i.usuario_id = mc.usuario_id
+				)`,
+			)
+		},
+	},
+	{
+		// Permisos por material. Los colaboradores existentes quedan como editores
+		ID: "0016_roles_colaborador",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.ColaboradorMaterial{}, &models.InvitacionColaborador{})
+		},
+	},
+	{
+		// Las revisiones sobreviven al borrado definitivo de su autor, que queda vacío
+		ID: "0017_revisiones_autor_opcional",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec(`ALTER TABLE material_revisiones ALTER COLUMN autor_id DROP NOT NULL`).Error
+		},
+	},
+	{
+		// Igual para los comentarios de moderación: el motivo de un rechazo no se pierde al borrar a quien lo escribió
+		ID: "0018_comentarios_autor_opcional",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec(`ALTER TABLE material_comentarios ALTER COLUMN autor_id DROP NOT NULL`).Error
+		},
+	},
+	{
+		// En una base nueva AutoMigrate crea categoria_id y etiquetas antes que 0013, así que el
+		// ADD COLUMN IF NOT EXISTS no aplicaba sus restricciones. Se fijan aquí para ambos caminos
+		ID: "0019_categorias_etiquetas_restricciones",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				`UPDATE materials SET etiquetas = '[]'::jsonb WHERE etiquetas IS NULL`,
+				`ALTER TABLE materials ALTER COLUMN etiquetas SET DEFAULT '[]'::jsonb`,
+				`ALTER TABLE materials ALTER COLUMN etiquetas SET NOT NULL`,
+				`ALTER TABLE materials DROP CONSTRAINT IF EXISTS fk_materials_categoria`,
+				`ALTER TABLE materials DROP CONSTRAINT IF EXISTS materials_categoria_id_fkey`,
+				`ALTER TABLE materials ADD CONSTRAINT fk_materials_categoria
+					FOREIGN KEY (categoria_id) REFERENCES categorias(id) ON DELETE SET NULL`,
+			)
+		},
+	},
+}
+
+// execAll ejecuta las sentencias una por una (el driver no acepta varias en un mismo Exec)
+func execAll(tx *gorm.DB, sentencias ...string) error {
+	for _, sql := range sentencias {
+		if err := tx.Exec(sql).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunMigrations aplica, cada una en su propia transacción, las migraciones que falten
+func RunMigrations(db *gorm.DB) error {
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return fmt.Errorf("error creando tabla de migraciones: %w", err)
+	}
+
+	var aplicadas []string
+	if err := db.Model(&schemaMigration{}).Pluck("id", &aplicadas).Error; err != nil {
+		return fmt.Errorf("error leyendo migraciones aplicadas: %w", err)
+	}
+	yaAplicada := make(map[string]bool, len(aplicadas))
+	for _, id := range aplicadas {
+		yaAplicada[id] = true
+	}
+
+	for _, m := range migraciones {
+		if yaAplicada[m.ID] {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{ID: m.ID, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("error aplicando migración %s: %w", m.ID, err)
+		}
+
+		log.Printf("✅ Migración aplicada: %s", m.ID)
+	}
+
+	return nil
+}
@@ -0,0 +1,375 @@
+package material
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"TT-SEM-2-BACK/api/database"
+	"TT-SEM-2-BACK/api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaterialAdmin es una fila del listado de administración: incluye los materiales en cualquier estado y
+// los eliminados con borrado lógico
+type MaterialAdmin struct {
+	ID                uuid.UUID             `json:"id"`
+	Nombre            string                `json:"nombre"`
+	Estado            models.EstadoMaterial `json:"estado"`
+	CreadorID         string                `json:"creador_id"`
+	CreadorNombre     string                `json:"creador_nombre"`
+	CreadorEmail      string                `json:"creador_email"`
+	DerivadoDe        *uuid.UUID            `json:"derivado_de"`
+	Derivados         int64                 `json:"derivados"`
+	Colaboradores     int64                 `json:"colaboradores"`
+	Categoria         *CategoriaResumen     `json:"categoria"`
+	Etiquetas         models.StringArray    `json:"etiquetas"`
+	RevisionPendiente bool                  `json:"revision_pendiente"`
+	CreatedAt         time.Time             `json:"created_at"`
+	UpdatedAt         time.Time             `json:"updated_at"`
+	EliminadoEn       *time.Time            `json:"eliminado_en"`
+}
+
+// leerFecha lee un parámetro de fecha (2006-01-02 o RFC3339). Con finDeDia, una fecha sin hora se toma
+// como el inicio del día siguiente para que el rango "hasta" incluya ese día completo
+func leerFecha(c *gin.Context, nombre string, finDeDia bool) (*time.Time, error) {
+	v := strings.TrimSpace(c.Query(nombre))
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return nil, fmt.Errorf("Parámetro '%s' inválido. Usa AAAA-MM-DD o RFC3339", nombre)
+	}
+	if finDeDia {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// leerBooleano lee un parámetro true/false opcional
+func leerBooleano(c *gin.Context, nombre string) (*bool, error) {
+	v := strings.TrimSpace(c.Query(nombre))
+	if v == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, fmt.Errorf("Parámetro '%s' inválido. Usa true o false", nombre)
+	}
+	return &b, nil
+}
+
+// filtrarAdmin aplica los filtros del listado de administración además de los del catálogo
+// (categoría, etiquetas, herramientas y composición):
+// ?estado (uno o varios), ?eliminado, ?creador y ?colaborador (google_id o email), ?derivado,
+// ?derivado_de, ?q (nombre) y los rangos ?creado_desde, ?creado_hasta, ?actualizado_desde, ?actualizado_hasta
+func filtrarAdmin(c *gin.Context, query *gorm.DB) (*gorm.DB, error) {
+	if estados := valoresFiltro(c, "estado"); len(estados) > 0 {
+		for _, e := range estados {
+			if !models.EstadoMaterial(e).Valido() {
+				return nil, fmt.Errorf("Estado '%s' inválido. Usa borrador, pendiente, aprobado, rechazado o archivado", e)
+			}
+		}
+		query = query.Where("materials.estado IN ?", estados)
+	}
+
+	eliminado, err := leerBooleano(c, "eliminado")
+	if err != nil {
+		return nil, err
+	}
+	if eliminado != nil && *eliminado {
+		query = query.Where("materials.deleted_at IS NOT NULL")
+	} else if eliminado != nil {
+		query = query.Where("materials.deleted_at IS NULL")
+	}
+
+	if creador := strings.TrimSpace(c.Query("creador")); creador != "" {
+		query = query.Where(`materials.creador_id IN (
+			SELECT google_id FROM usuarios WHERE google_id = ? OR lower(email) = lower(?))`, creador, creador)
+	}
+	if colaborador := strings.TrimSpace(c.Query("colaborador")); colaborador != "" {
+		query = query.Where(`EXISTS (
+			SELECT 1 FROM material_colaboradores mc JOIN usuarios u ON u.google_id = mc.usuario_id
+			WHERE mc.material_id = materials.id AND mc.deleted_at IS NULL
+				AND (u.google_id = ? OR lower(u.email) = lower(?)))`, colaborador, colaborador)
+	}
+
+	derivado, err := leerBooleano(c, "derivado")
+	if err != nil {
+		return nil, err
+	}
+	if derivado != nil && *derivado {
+		query = query.Where("materials.derivado_de IS NOT NULL AND materials.derivado_de <> ?", uuid.Nil)
+	} else if derivado != nil {
+		query = query.Where("(materials.derivado_de IS NULL OR materials.derivado_de = ?)", uuid.Nil)
+	}
+	if v := strings.TrimSpace(c.Query("derivado_de")); v != "" {
+		padre, err := uuid.Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("Parámetro 'derivado_de' inválido")
+		}
+		query = query.Where("materials.derivado_de = ?", padre)
+	}
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		query = query.Where("unaccent(lower(materials.nombre)) LIKE '%' || unaccent(lower(?)) || '%'", q)
+	}
+
+	rangos := []struct {
+		parametro, condicion string
+		finDeDia             bool
+	}{
+		{"creado_desde", "materials.created_at >= ?", false},
+		{"creado_hasta", "materials.created_at < ?", true},
+		{"actualizado_desde", "materials.updated_at >= ?", false},
+		{"actualizado_hasta", "materials.updated_at < ?", true},
+	}
+	for _, r := range rangos {
+		fecha, err := leerFecha(c, r.parametro, r.finDeDia)
+		if err != nil {
+			return nil, err
+		}
+		if fecha != nil {
+			query = query.Where(r.condicion, *fecha)
+		}
+	}
+
+	return filtrarCatalogo(c, query), nil
+}
+
+// filasAdmin arma las filas del listado con los conteos de colaboradores, derivados y revisiones pendientes
+func filasAdmin(db *gorm.DB, materials []models.Material) ([]MaterialAdmin, error) {
+	filas := make([]MaterialAdmin, 0, len(materials))
+	if len(materials) == 0 {
+		return filas, nil
+	}
+
+	ids := make([]uuid.UUID, len(materials))
+	for i, m := range materials {
+		ids[i] = m.ID
+	}
+
+	type conteo struct {
+		ID    uuid.UUID
+		Total int64
+	}
+	var colaboradores, derivados []conteo
+	if err := db.Raw(`SELECT material_id AS id, COUNT(*) AS total FROM material_colaboradores
+		WHERE deleted_at IS NULL AND material_id IN ? GROUP BY 1`, ids).Scan(&colaboradores).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Raw(`SELECT derivado_de AS id, COUNT(*) AS total FROM materials
+		WHERE derivado_de IN ? GROUP BY 1`, ids).Scan(&derivados).Error; err != nil {
+		return nil, err
+	}
+	var conRevision []uuid.UUID
+	if err := db.Model(&models.RevisionMaterial{}).Distinct("material_id").
+		Where("estado = ? AND material_id IN ?", models.RevisionPendiente, ids).
+		Pluck("material_id", &conRevision).Error; err != nil {
+		return nil, err
+	}
+
+	porColaboradores := map[uuid.UUID]int64{}
+	for _, c := range colaboradores {
+		porColaboradores[c.ID] = c.Total
+	}
+	porDerivados := map[uuid.UUID]int64{}
+	for _, d := range derivados {
+		porDerivados[d.ID] = d.Total
+	}
+	pendientes := map[uuid.UUID]bool{}
+	for _, id := range conRevision {
+		pendientes[id] = true
+	}
+
+	for _, m := range materials {
+		fila := MaterialAdmin{
+			ID:                m.ID,
+			Nombre:            m.Nombre,
+			Estado:            m.Estado,
+			CreadorID:         m.CreadorID,
+			CreadorNombre:     m.Creador.Nombre,
+			CreadorEmail:      m.Creador.Email,
+			Derivados:         porDerivados[m.ID],
+			Colaboradores:     porColaboradores[m.ID],
+			Etiquetas:         m.Etiquetas,
+			RevisionPendiente: pendientes[m.ID],
+			CreatedAt:         m.CreatedAt,
+			UpdatedAt:         m.UpdatedAt,
+		}
+		if fila.Etiquetas == nil {
+			fila.Etiquetas = models.StringArray{}
+		}
+		if m.DerivadoDe != uuid.Nil {
+			padre := m.DerivadoDe
+			fila.DerivadoDe = &padre
+		}
+		if m.Categoria != nil {
+			fila.Categoria = &CategoriaResumen{ID: m.Categoria.ID, Nombre: m.Categoria.Nombre, Slug: m.Categoria.Slug}
+		}
+		if m.DeletedAt.Valid {
+			eliminado := m.DeletedAt.Time
+			fila.EliminadoEn = &eliminado
+		}
+		filas = append(filas, fila)
+	}
+	return filas, nil
+}
+
+// responderCSV envía las filas como archivo CSV
+func responderCSV(c *gin.Context, filas []MaterialAdmin) {
+	nombre := fmt.Sprintf("materiales-%s.csv", time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="`+nombre+`"`)
+	c.Status(http.StatusOK)
+
+	fecha := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"id", "nombre", "estado", "creador_id", "creador_nombre", "creador_email", "derivado_de",
+		"derivados", "colaboradores", "categoria", "etiquetas", "revision_pendiente", "creado", "actualizado", "eliminado"})
+	for _, f := range filas {
+		derivadoDe, categoria := "", ""
+		if f.DerivadoDe != nil {
+			derivadoDe = f.DerivadoDe.String()
+		}
+		if f.Categoria != nil {
+			categoria = f.Categoria.Slug
+		}
+		w.Write([]string{
+			f.ID.String(), f.Nombre, string(f.Estado), f.CreadorID, f.CreadorNombre, f.CreadorEmail, derivadoDe,
+			strconv.FormatInt(f.Derivados, 10), strconv.FormatInt(f.Colaboradores, 10), categoria,
+			strings.Join(f.Etiquetas, ";"), strconv.FormatBool(f.RevisionPendiente),
+			fecha(&f.CreatedAt), fecha(&f.UpdatedAt), fecha(f.EliminadoEn),
+		})
+	}
+	w.Flush()
+}
+
+// GetMaterialsAdmin lista TODOS los materiales, en cualquier estado e incluso los eliminados - Solo Admin.
+// Acepta los filtros de filtrarAdmin, la paginación y el orden de los listados públicos (?page, ?limit,
+// ?sort, ?order) y ?format=csv para exportar todas las filas que cumplen los filtros, sin paginar
+func GetMaterialsAdmin(c *gin.Context) {
+	db, err := database.OpenGormDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	pag, err := leerPaginacion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	formato := strings.ToLower(c.DefaultQuery("format", "json"))
+	if formato != "json" && formato != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parámetro 'format' inválido. Usa json o csv"})
+		return
+	}
+
+	query, err := filtrarAdmin(c, db.Unscoped().Model(&models.Material{}))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query = query.Session(&gorm.Session{}) // Se reutiliza para contar y para paginar
+
+	// En CSV van todas las filas, en el orden pedido (un límite de -1 no recorta)
+	if formato == "csv" {
+		pag.Page, pag.Limit = 1, -1
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error contando materiales: " + err.Error()})
+		return
+	}
+
+	var materials []models.Material
+	if err := pag.aplicar(query).
+		Preload("Creador", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Categoria").
+		Find(&materials).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error listando materiales: " + err.Error()})
+		return
+	}
+
+	filas, err := filasAdmin(db, materials)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error calculando conteos: " + err.Error()})
+		return
+	}
+
+	if formato == "csv" {
+		responderCSV(c, filas)
+		return
+	}
+	responderPagina(c, filas, total, pag)
+}
+
+// GetMaterialAdmin obtiene un material por ID sin filtro de estado, aunque esté eliminado, con su estado
+// de moderación y sus colaboradores - Solo Admin
+func GetMaterialAdmin(c *gin.Context) {
+	db, err := database.OpenGormDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var material models.Material
+	if err := db.Unscoped().Where("id = ?", id).
+		Preload("Creador", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Categoria").
+		Preload("Colaboradores").
+		Preload("Pasos").
+		Preload("Galeria").
+		First(&material).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material no encontrado"})
+		return
+	}
+
+	moderacion, err := cargarModeracion(db, material)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error obteniendo estado de moderación: " + err.Error()})
+		return
+	}
+	invitaciones, err := ultimasInvitaciones(db, material.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error obteniendo colaboradores: " + err.Error()})
+		return
+	}
+
+	var eliminadoEn *time.Time
+	if material.DeletedAt.Valid {
+		eliminadoEn = &material.DeletedAt.Time
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"material":      material,
+		"eliminado_en":  eliminadoEn,
+		"moderacion":    moderacion,
+		"colaboradores": invitaciones,
+	})
+}
@@ -1,6 +1,8 @@
 package material
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 
@@ -10,6 +12,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // Helper para enviar las notificaciones en segundo plano
@@ -60,7 +63,7 @@ func SendNotification(usuarioId string, matId uuid.UUID, materialName string, ti
 	}(usuarioId, matId, materialName, tipo)
 }
 
-// ApproveMaterial aprueba un material cambiando estado a true
+// ApproveMaterial aprueba un material pendiente y lo hace público
 func ApproveMaterial(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
@@ -83,7 +86,7 @@ func ApproveMaterial(c *gin.Context) {
 	}
 
 	// Verificar si ya está aprobado
-	if material.Estado {
+	if material.Estado == models.EstadoAprobado {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":    "El material ya está aprobado",
 			"material": material,
@@ -91,10 +94,10 @@ func ApproveMaterial(c *gin.Context) {
 		return
 	}
 
-	// Aprobar el material
-	material.Estado = true
-	if err := db.Save(&material).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error aprobando material: " + err.Error()})
+	// Aprobar el material (la máquina de estados solo lo permite desde pendiente)
+	adminGoogleID, _ := middleware.GetUserGoogleID(c)
+	if err := cambiarEstado(db, &material, models.EstadoAprobado, adminGoogleID, ""); err != nil {
+		responderErrorEstado(c, material, err)
 		return
 	}
 
@@ -102,7 +105,6 @@ func ApproveMaterial(c *gin.Context) {
 	SendNotification(material.CreadorID, material.ID, material.Nombre, "aprobado", "")
 
 	// Log de la aprobación
-	adminGoogleID, _ := middleware.GetUserGoogleID(c)
 	log.Printf("✅ Material aprobado: %s (%s) por admin: %s", material.Nombre, material.ID, adminGoogleID)
 
 	c.JSON(http.StatusOK, gin.H{
@@ -113,10 +115,11 @@ func ApproveMaterial(c *gin.Context) {
 
 // Estructura para recibir la razón desde el frontend
 type RechazoRequest struct {
-	Razon string `json:"razon"`
+	Razon       string              `json:"razon"`
+	Comentarios []ComentarioRequest `json:"comentarios"` // Comentarios sobre partes concretas del material
 }
 
-// RejectMaterial rechaza/desaprueba un material cambiando estado a false
+// RejectMaterial rechaza un material pendiente o despublica uno aprobado
 func RejectMaterial(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
@@ -146,10 +149,41 @@ func RejectMaterial(c *gin.Context) {
 		return
 	}
 
-	// Rechazar/desaprobar el material
-	material.Estado = false
-	if err := db.Save(&material).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error rechazando material: " + err.Error()})
+	// Validar los comentarios contra la versión en curso antes de rechazar
+	actual, err := revisionActual(db, material.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error buscando la versión en curso: " + err.Error()})
+		return
+	}
+	if len(req.Comentarios) > 0 && actual == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "El material no tiene versiones sobre las que comentar"})
+		return
+	}
+	for _, com := range req.Comentarios {
+		if err := validarComentario(actual.Contenido, com); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Rechazar/desaprobar el material (la razón queda en el historial de estados)
+	// Los comentarios se guardan en la misma transacción: un rechazo nunca queda sin su explicación
+	adminGoogleID, _ := middleware.GetUserGoogleID(c)
+	comentarios := []models.ComentarioRevision{}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := cambiarEstado(tx, &material, models.EstadoRechazado, adminGoogleID, req.Razon); err != nil {
+			return err
+		}
+		if len(req.Comentarios) == 0 {
+			return nil
+		}
+		var err error
+		if comentarios, err = crearComentarios(tx, material.ID, actual.Numero, adminGoogleID, req.Comentarios); err != nil {
+			return fmt.Errorf("error guardando comentarios de rechazo: %w", err)
+		}
+		return nil
+	}); err != nil {
+		responderErrorEstado(c, material, err)
 		return
 	}
 
@@ -157,15 +191,18 @@ func RejectMaterial(c *gin.Context) {
 	SendNotification(material.CreadorID, material.ID, material.Nombre, "rechazado", req.Razon)
 
 	// Log del rechazo
-	adminGoogleID, _ := middleware.GetUserGoogleID(c)
 	log.Printf("❌ Material rechazado: %s (%s) por admin: %s. Razón: %s", material.Nombre, material.ID, adminGoogleID, req.Razon)
 	c.JSON(http.StatusOK, gin.H{
-		"message":  "Material rechazado/desaprobado exitosamente",
-		"material": material,
+		"message":     "Material rechazado/desaprobado exitosamente",
+		"material":    material,
+		"comentarios": comentarios,
 	})
 }
 
-// ToggleApprovalMaterial cambia el estado de aprobación
+// ToggleApprovalMaterial publica un material o, si ya está aprobado, lo despublica (rechazado).
+// La máquina de estados solo aprueba desde pendiente: un material rechazado o en borrador pasa por
+// pendiente y aprobado en la misma transacción, así el toggle siempre puede deshacer lo que hizo.
+// Un material archivado se debe desarchivar antes
 func ToggleApprovalMaterial(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
@@ -187,12 +224,39 @@ func ToggleApprovalMaterial(c *gin.Context) {
 		return
 	}
 
-	// Cambiar estado
-	nuevoEstado := !material.Estado
-	material.Estado = nuevoEstado
+	// Cambiar estado: los pasos que hacen falta según el estado actual
+	nuevoEstado := material.Estado != models.EstadoAprobado
+	var pasos []models.EstadoMaterial
+	switch material.Estado {
+	case models.EstadoAprobado:
+		pasos = []models.EstadoMaterial{models.EstadoRechazado}
+	case models.EstadoPendiente:
+		pasos = []models.EstadoMaterial{models.EstadoAprobado}
+	case models.EstadoRechazado, models.EstadoBorrador:
+		pasos = []models.EstadoMaterial{models.EstadoPendiente, models.EstadoAprobado}
+	default:
+		c.JSON(http.StatusConflict, gin.H{
+			"error":         "El material está archivado",
+			"detail":        "Desarchívalo antes de publicarlo",
+			"estado_actual": material.Estado,
+		})
+		return
+	}
 
-	if err := db.Save(&material).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error cambiando estado: " + err.Error()})
+	adminGoogleID, _ := middleware.GetUserGoogleID(c)
+	original := material.Estado
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		for _, destino := range pasos {
+			if err := cambiarEstado(tx, &material, destino, adminGoogleID, ""); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		if !errors.Is(err, errEstadoCambiado) {
+			material.Estado = original // La transacción se deshizo
+		}
+		responderErrorEstado(c, material, err)
 		return
 	}
 
@@ -203,7 +267,6 @@ func ToggleApprovalMaterial(c *gin.Context) {
 	}
 	SendNotification(material.CreadorID, material.ID, material.Nombre, tipoNotificacion, "")
 	// Log del cambio
-	adminGoogleID, _ := middleware.GetUserGoogleID(c)
 	estadoTexto := "rechazado"
 	emoji := "❌"
 	if nuevoEstado {
@@ -214,7 +277,7 @@ func ToggleApprovalMaterial(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":      "Estado de aprobación cambiado exitosamente",
-		"nuevo_estado": nuevoEstado,
+		"nuevo_estado": material.Estado,
 		"material":     material,
 	})
 }
@@ -0,0 +1,269 @@
+package material
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"TT-SEM-2-BACK/api/database"
+	"TT-SEM-2-BACK/api/models"
+	"TT-SEM-2-BACK/api/units"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// toleranciaPorcentaje es el margen aceptado al sumar una receta en porcentajes (redondeos del autor)
+const toleranciaPorcentaje = 0.5
+
+// normalizarComposicion calcula la cantidad estructurada de cada componente y devuelve los errores:
+// cantidades que no se entienden, porcentajes mezclados con cantidades absolutas o porcentajes que no
+// suman 100. Una cantidad vacía ("al gusto") no es un error, simplemente no se escala
+func normalizarComposicion(comp models.JSONComponentes) []ErrorCampo {
+	var errores []ErrorCampo
+	var suma float64
+	porcentajes, absolutas := 0, 0
+
+	for i := range comp {
+		comp[i].CantidadValor = nil
+		comp[i].CantidadUnidad = ""
+
+		m, err := units.NormalizarCantidad(comp[i].Cantidad)
+		if err != nil {
+			errores = append(errores, ErrorCampo{
+				Campo: "composicion",
+				Error: fmt.Sprintf("Cantidad de '%s' inválida: %v", comp[i].Elemento, err),
+			})
+			continue
+		}
+		if m == nil {
+			continue
+		}
+
+		valor := m.Valor
+		comp[i].CantidadValor = &valor
+		comp[i].CantidadUnidad = m.Unidad.Simbolo
+		if m.Unidad.Magnitud == units.Porcentaje {
+			porcentajes++
+			suma += valor
+		} else {
+			absolutas++
+		}
+	}
+
+	switch {
+	case porcentajes > 0 && absolutas > 0:
+		errores = append(errores, ErrorCampo{
+			Campo: "composicion",
+			Error: "No se pueden mezclar porcentajes con cantidades absolutas en la misma composición",
+		})
+	case porcentajes > 0 && math.Abs(suma-100) > toleranciaPorcentaje:
+		errores = append(errores, ErrorCampo{
+			Campo: "composicion",
+			Error: fmt.Sprintf("Los porcentajes de la composición suman %s%%, deben sumar 100%%", formatearCantidad(suma)),
+		})
+	}
+
+	return errores
+}
+
+// formatearCantidad redondea a tres decimales y quita los ceros sobrantes
+func formatearCantidad(v float64) string {
+	return strconv.FormatFloat(redondearCantidad(v), 'f', -1, 64)
+}
+
+func redondearCantidad(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+// ComponenteEscalado es un componente de la composición ajustado al lote pedido
+type ComponenteEscalado struct {
+	Elemento         string   `json:"elemento"`
+	CantidadOriginal string   `json:"cantidad_original"`
+	Valor            *float64 `json:"valor"`  // nil si no se pudo escalar
+	Unidad           string   `json:"unidad"` // Unidad de Valor
+	Cantidad         string   `json:"cantidad,omitempty"`
+	Escalable        bool     `json:"escalable"`
+	Motivo           string   `json:"motivo,omitempty"` // Por qué no se escaló
+
+	base     float64 // Valor en la unidad base de su magnitud, para calcular costos
+	magnitud units.Magnitud
+}
+
+// leerLote lee ?batch y ?factor. Con requerido = false ninguno de los dos es obligatorio
+func leerLote(c *gin.Context, requerido bool) (*units.Medida, float64, error) {
+	var lote *units.Medida
+	if s := strings.TrimSpace(c.Query("batch")); s != "" {
+		m, err := units.NormalizarCantidad(s)
+		if err != nil || m.Unidad.Magnitud == units.Porcentaje || m.Valor <= 0 {
+			return nil, 0, errors.New("Parámetro 'batch' inválido. Usa una cantidad positiva de masa, volumen o conteo, por ejemplo '500 g'")
+		}
+		lote = m
+	}
+	var factor float64
+	if s := strings.TrimSpace(c.Query("factor")); s != "" {
+		f, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+		if err != nil || f <= 0 || math.IsInf(f, 0) {
+			return nil, 0, errors.New("Parámetro 'factor' inválido")
+		}
+		factor = f
+	}
+	if lote != nil && factor != 0 {
+		return nil, 0, errors.New("Indica 'batch' o 'factor' (solo uno)")
+	}
+	if lote == nil && factor == 0 {
+		if requerido {
+			return nil, 0, errors.New("Indica 'batch' o 'factor' (solo uno)")
+		}
+		factor = 1
+	}
+	return lote, factor, nil
+}
+
+// ComposicionEscalada es el resultado de escalar una composición
+type ComposicionEscalada struct {
+	Tipo        string               `json:"tipo"`   // "porcentaje" o "absoluta"
+	Factor      *float64             `json:"factor"` // nil en recetas en porcentajes
+	Composicion []ComponenteEscalado `json:"composicion"`
+}
+
+// escalarComposicion aplica el lote o el factor a la composición. Devuelve un error de validación
+// (para responder 400) si la receta no se puede escalar con lo pedido
+func escalarComposicion(comp models.JSONComponentes, lote *units.Medida, factor float64) (ComposicionEscalada, error) {
+	medidas := make([]*units.Medida, len(comp))
+	enPorcentaje := false
+	for i, co := range comp {
+		medidas[i], _ = units.NormalizarCantidad(co.Cantidad)
+		if medidas[i] != nil && medidas[i].Unidad.Magnitud == units.Porcentaje {
+			enPorcentaje = true
+		}
+	}
+
+	resultado := ComposicionEscalada{Tipo: "absoluta"}
+	if enPorcentaje {
+		resultado.Tipo = "porcentaje"
+		if lote == nil {
+			return resultado, errors.New("La composición está en porcentajes, indica el tamaño del lote con 'batch'")
+		}
+	}
+
+	// 1. Factor a partir del lote: total de los componentes absolutos de la misma magnitud
+	if lote != nil && !enPorcentaje {
+		var total float64
+		for _, m := range medidas {
+			if m != nil && m.Unidad.Magnitud == lote.Unidad.Magnitud {
+				total += m.Valor
+			}
+		}
+		if total == 0 {
+			return resultado, fmt.Errorf("Ningún componente está expresado en %s, no se puede escalar a %s %s",
+				lote.Unidad.Magnitud, formatearCantidad(lote.Original.DesdeBase(lote.Valor)), lote.Original.Simbolo)
+		}
+		factor = lote.Valor / total
+	}
+	if !enPorcentaje {
+		f := redondearCantidad(factor)
+		resultado.Factor = &f
+	}
+
+	// 2. Escalar cada componente
+	resultado.Composicion = make([]ComponenteEscalado, len(comp))
+	for i, co := range comp {
+		e := ComponenteEscalado{Elemento: co.Elemento, CantidadOriginal: co.Cantidad}
+		m := medidas[i]
+
+		var base float64
+		unidad := units.Unidad{}
+		switch {
+		case m == nil && strings.TrimSpace(co.Cantidad) == "":
+			e.Motivo = "Sin cantidad"
+		case m == nil:
+			e.Motivo = "Cantidad no reconocida"
+		case enPorcentaje && m.Unidad.Magnitud != units.Porcentaje:
+			e.Motivo = "Cantidad absoluta en una receta en porcentajes"
+		case enPorcentaje:
+			base, unidad = m.Valor/100*lote.Valor, lote.Original
+			e.Escalable = true
+		case lote != nil && m.Unidad.Magnitud == lote.Unidad.Magnitud:
+			base, unidad = m.Valor*factor, lote.Original
+			e.Escalable = true
+		default:
+			base, unidad = m.Valor*factor, m.Original
+			e.Escalable = true
+		}
+
+		if e.Escalable {
+			v := redondearCantidad(unidad.DesdeBase(base))
+			e.Valor = &v
+			e.Unidad = unidad.Simbolo
+			e.Cantidad = strings.TrimSpace(formatearCantidad(v) + " " + unidad.Simbolo)
+			e.base, e.magnitud = base, unidad.Magnitud
+		}
+		resultado.Composicion[i] = e
+	}
+
+	return resultado, nil
+}
+
+// GetScaledMaterial devuelve la composición de un material aprobado escalada a un lote.
+// ?batch=500 g fija el tamaño del lote: en una receta en porcentajes cada componente es su porcentaje
+// del lote; en una receta absoluta se escala por lote / total de los componentes de la misma magnitud.
+// ?factor=2 multiplica directamente las cantidades absolutas. Los componentes de la misma magnitud que
+// el lote se expresan en la unidad del lote; el resto conserva la unidad en que se escribieron
+func GetScaledMaterial(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	// 1. Lote o factor
+	lote, factor, err := leerLote(c, true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	var material models.Material
+	if err := db.Where("id = ? AND estado = ?", id, models.EstadoAprobado).First(&material).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material no encontrado o no está aprobado"})
+		return
+	}
+
+	// 2. Cantidades estructuradas (se recalculan por si el material es anterior a esta función)
+	advertencias := []string{}
+	for _, e := range normalizarComposicion(material.Composicion) {
+		advertencias = append(advertencias, e.Error)
+	}
+
+	// 3. Escalar
+	escalada, err := escalarComposicion(material.Composicion, lote, factor)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	respuesta := gin.H{
+		"material_id":  material.ID,
+		"nombre":       material.Nombre,
+		"tipo":         escalada.Tipo,
+		"factor":       escalada.Factor,
+		"composicion":  escalada.Composicion,
+		"advertencias": advertencias,
+	}
+	if lote != nil {
+		valor := redondearCantidad(lote.Original.DesdeBase(lote.Valor))
+		respuesta["batch"] = gin.H{"valor": valor, "unidad": lote.Original.Simbolo}
+	}
+
+	c.JSON(http.StatusOK, respuesta)
+}
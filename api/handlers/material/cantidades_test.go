@@ -0,0 +1,202 @@
+package material
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"TT-SEM-2-BACK/api/models"
+	"TT-SEM-2-BACK/api/units"
+)
+
+// receta arma una composición a partir de pares elemento, cantidad
+func receta(pares ...string) models.JSONComponentes {
+	comp := models.JSONComponentes{}
+	for i := 0; i+1 < len(pares); i += 2 {
+		comp = append(comp, models.Componente{Elemento: pares[i], Cantidad: pares[i+1]})
+	}
+	return comp
+}
+
+// loteDe interpreta el tamaño de lote igual que ?batch
+func loteDe(t *testing.T, cantidad string) *units.Medida {
+	t.Helper()
+	m, err := units.NormalizarCantidad(cantidad)
+	if err != nil || m == nil {
+		t.Fatalf("lote %q inválido: %v", cantidad, err)
+	}
+	return m
+}
+
+func TestNormalizarComposicion(t *testing.T) {
+	casos := []struct {
+		nombre  string
+		comp    models.JSONComponentes
+		errores []string // Fragmentos esperados en los errores, en orden
+	}{
+		{"porcentajes que suman 100", receta("Arcilla", "60 %", "Agua", "40%"), nil},
+		{"dentro de la tolerancia", receta("Arcilla", "60,3 %", "Agua", "39,3 %"), nil},
+		{"fuera de la tolerancia", receta("Arcilla", "60 %", "Agua", "39,4 %"), []string{"suman 99.4%"}},
+		{"porcentajes de más", receta("Arcilla", "70 %", "Agua", "40 %"), []string{"suman 110%"}},
+		{"mezcla de porcentajes y absolutas", receta("Arcilla", "60 %", "Agua", "200 g"), []string{"mezclar porcentajes"}},
+		{"absolutas de distintas magnitudes", receta("Harina", "500 g", "Agua", "1 1/2 tazas", "Huevos", "2"), nil},
+		{"cantidad vacía", receta("Sal", "", "Harina", "500 g"), nil},
+		{"cantidad no reconocida", receta("Sal", "una pizca", "Harina", "500 g"), []string{"Cantidad de 'Sal' inválida"}},
+		{"magnitud que no es cantidad", receta("Resina", "25 MPa"), []string{"Cantidad de 'Resina' inválida"}},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			errores := normalizarComposicion(c.comp)
+			if len(errores) != len(c.errores) {
+				t.Fatalf("errores = %+v, quiere %d", errores, len(c.errores))
+			}
+			for i, fragmento := range c.errores {
+				if !strings.Contains(errores[i].Error, fragmento) {
+					t.Errorf("error %d = %q, quiere que contenga %q", i, errores[i].Error, fragmento)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizarComposicionCalculaCantidades(t *testing.T) {
+	comp := receta("Harina", "0,5 kg", "Agua", "1 1/2 tazas", "Huevos", "2", "Sal", "")
+	if errores := normalizarComposicion(comp); len(errores) > 0 {
+		t.Fatalf("errores inesperados: %+v", errores)
+	}
+
+	quiere := []struct {
+		valor  float64
+		unidad string
+	}{{500, "g"}, {360, "mL"}, {2, "u"}}
+	for i, q := range quiere {
+		if comp[i].CantidadValor == nil || *comp[i].CantidadValor != q.valor || comp[i].CantidadUnidad != q.unidad {
+			t.Errorf("%s = %v %s, quiere %v %s", comp[i].Elemento, comp[i].CantidadValor, comp[i].CantidadUnidad, q.valor, q.unidad)
+		}
+	}
+	if comp[3].CantidadValor != nil || comp[3].CantidadUnidad != "" {
+		t.Errorf("Sal sin cantidad no debería normalizarse: %v %s", comp[3].CantidadValor, comp[3].CantidadUnidad)
+	}
+}
+
+// cantidades devuelve la cantidad escalada de cada componente ("" si no se escaló)
+func cantidades(e ComposicionEscalada) []string {
+	res := make([]string, len(e.Composicion))
+	for i, c := range e.Composicion {
+		res[i] = c.Cantidad
+	}
+	return res
+}
+
+func TestEscalarComposicion(t *testing.T) {
+	casos := []struct {
+		nombre     string
+		comp       models.JSONComponentes
+		lote       string
+		factor     float64
+		tipo       string
+		factorCalc *float64 // nil en recetas en porcentajes
+		quiere     []string
+	}{
+		{
+			nombre: "factor directo conserva las unidades",
+			comp:   receta("Harina", "100 g", "Leche", "1 taza", "Huevos", "2"),
+			factor: 2, tipo: "absoluta", factorCalc: ptr(2),
+			quiere: []string{"200 g", "2 taza", "4 u"},
+		},
+		{
+			nombre: "lote en otra unidad de la misma magnitud",
+			comp:   receta("Harina", "200 g", "Azúcar", "300 g", "Huevos", "2"),
+			lote:   "1 kg", tipo: "absoluta", factorCalc: ptr(2),
+			quiere: []string{"0.4 kg", "0.6 kg", "4 u"},
+		},
+		{
+			nombre: "lote que mezcla unidades de masa",
+			comp:   receta("Cera", "1 lb", "Aceite", "546,40763 g"),
+			lote:   "2 kg", tipo: "absoluta", factorCalc: ptr(2),
+			quiere: []string{"0.907 kg", "1.093 kg"},
+		},
+		{
+			nombre: "porcentajes sobre el lote",
+			comp:   receta("Arcilla", "60 %", "Agua", "40 %"),
+			lote:   "500 g", tipo: "porcentaje",
+			quiere: []string{"300 g", "200 g"},
+		},
+		{
+			nombre: "absoluta dentro de una receta en porcentajes",
+			comp:   receta("Arcilla", "100 %", "Pigmento", "5 g", "Sellador", ""),
+			lote:   "1 L", tipo: "porcentaje",
+			quiere: []string{"1 L", "", ""},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			var l *units.Medida
+			if c.lote != "" {
+				l = loteDe(t, c.lote)
+			}
+			res, err := escalarComposicion(c.comp, l, c.factor)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if res.Tipo != c.tipo {
+				t.Errorf("tipo = %s, quiere %s", res.Tipo, c.tipo)
+			}
+			switch {
+			case c.factorCalc == nil && res.Factor != nil:
+				t.Errorf("factor = %v, quiere nil", *res.Factor)
+			case c.factorCalc != nil && (res.Factor == nil || math.Abs(*res.Factor-*c.factorCalc) > 1e-9):
+				t.Errorf("factor = %v, quiere %v", res.Factor, *c.factorCalc)
+			}
+			got := cantidades(res)
+			for i := range c.quiere {
+				if got[i] != c.quiere[i] {
+					t.Errorf("%s = %q, quiere %q", res.Composicion[i].Elemento, got[i], c.quiere[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEscalarComposicionMotivos(t *testing.T) {
+	res, err := escalarComposicion(receta("Arcilla", "100 %", "Pigmento", "5 g", "Sellador", "", "Sal", "una pizca"), loteDe(t, "1 kg"), 0)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	quiere := []string{"", "Cantidad absoluta en una receta en porcentajes", "Sin cantidad", "Cantidad no reconocida"}
+	for i, motivo := range quiere {
+		e := res.Composicion[i]
+		if e.Motivo != motivo || e.Escalable != (motivo == "") {
+			t.Errorf("%s: motivo = %q escalable = %v, quiere %q", e.Elemento, e.Motivo, e.Escalable, motivo)
+		}
+	}
+}
+
+func TestEscalarComposicionErrores(t *testing.T) {
+	casos := []struct {
+		nombre string
+		comp   models.JSONComponentes
+		lote   string
+		factor float64
+		quiere string
+	}{
+		{"porcentajes sin lote", receta("Arcilla", "60 %", "Agua", "40 %"), "", 2, "indica el tamaño del lote"},
+		{"lote de otra magnitud", receta("Harina", "500 g", "Huevos", "2"), "1 L", 0, "Ningún componente está expresado en volumen"},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			var l *units.Medida
+			if c.lote != "" {
+				l = loteDe(t, c.lote)
+			}
+			_, err := escalarComposicion(c.comp, l, c.factor)
+			if err == nil || !strings.Contains(err.Error(), c.quiere) {
+				t.Errorf("error = %v, quiere que contenga %q", err, c.quiere)
+			}
+		})
+	}
+}
+
+func ptr(v float64) *float64 { return &v }
@@ -0,0 +1,355 @@
+package material
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"TT-SEM-2-BACK/api/database"
+	"TT-SEM-2-BACK/api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	maxEtiquetas     = 20
+	maxLargoEtiqueta = 40
+)
+
+var reNoSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slugCategoria arma el identificador de URL a partir del nombre ("Bioplásticos" -> "bioplasticos")
+func slugCategoria(nombre string) string {
+	return strings.Trim(reNoSlug.ReplaceAllString(claveProp(nombre), "-"), "-")
+}
+
+// leerEtiquetas acepta un arreglo JSON o una lista separada por comas. Las etiquetas se guardan en
+// minúsculas, sin "#" inicial y sin repetir
+func leerEtiquetas(str string) (models.StringArray, error) {
+	var crudas []string
+	if strings.HasPrefix(strings.TrimSpace(str), "[") {
+		if err := json.Unmarshal([]byte(str), &crudas); err != nil {
+			return nil, errors.New("Formato de etiquetas inválido")
+		}
+	} else {
+		crudas = strings.Split(str, ",")
+	}
+
+	etiquetas := models.StringArray{}
+	vistas := make(map[string]bool)
+	for _, e := range crudas {
+		e = normalizarEtiqueta(e)
+		if e == "" || vistas[claveProp(e)] {
+			continue
+		}
+		if len([]rune(e)) > maxLargoEtiqueta {
+			return nil, fmt.Errorf("La etiqueta '%s' supera los %d caracteres", e, maxLargoEtiqueta)
+		}
+		vistas[claveProp(e)] = true
+		etiquetas = append(etiquetas, e)
+	}
+	if len(etiquetas) > maxEtiquetas {
+		return nil, fmt.Errorf("Máximo %d etiquetas por material", maxEtiquetas)
+	}
+	return etiquetas, nil
+}
+
+// normalizarEtiqueta deja la etiqueta como se guarda: minúsculas, sin "#" y con espacios simples
+func normalizarEtiqueta(e string) string {
+	return strings.ToLower(strings.Join(strings.Fields(strings.TrimLeft(strings.TrimSpace(e), "#")), " "))
+}
+
+// validarCategoria comprueba que la categoría del snapshot exista
+func validarCategoria(db *gorm.DB, snap models.SnapshotMaterial) []ErrorCampo {
+	if snap.CategoriaID == nil {
+		return nil
+	}
+	var n int64
+	if err := db.Model(&models.Categoria{}).Where("id = ?", *snap.CategoriaID).Count(&n).Error; err != nil {
+		return []ErrorCampo{{Campo: "categoria_id", Error: "Error verificando la categoría"}}
+	}
+	if n == 0 {
+		return []ErrorCampo{{Campo: "categoria_id", Error: "La categoría no existe"}}
+	}
+	return nil
+}
+
+// NodoCategoria es una categoría con sus hijas y cuántos materiales aprobados tiene (incluyendo las hijas)
+type NodoCategoria struct {
+	models.Categoria
+	Materiales int64            `json:"materiales"`
+	Hijas      []*NodoCategoria `json:"hijas"`
+}
+
+// arbolCategorias arma el árbol a partir de la lista plana y el conteo directo de cada categoría
+func arbolCategorias(categorias []models.Categoria, conteos map[uuid.UUID]int64) []*NodoCategoria {
+	nodos := make(map[uuid.UUID]*NodoCategoria, len(categorias))
+	for _, cat := range categorias {
+		nodos[cat.ID] = &NodoCategoria{Categoria: cat, Hijas: []*NodoCategoria{}}
+	}
+
+	raices := []*NodoCategoria{}
+	for _, cat := range categorias {
+		nodo := nodos[cat.ID]
+		if cat.ParentID != nil {
+			if padre, ok := nodos[*cat.ParentID]; ok {
+				padre.Hijas = append(padre.Hijas, nodo)
+				continue
+			}
+		}
+		raices = append(raices, nodo)
+	}
+
+	var ordenar func([]*NodoCategoria) int64
+	ordenar = func(lista []*NodoCategoria) int64 {
+		sort.SliceStable(lista, func(i, j int) bool {
+			if lista[i].Orden != lista[j].Orden {
+				return lista[i].Orden < lista[j].Orden
+			}
+			return lista[i].Nombre < lista[j].Nombre
+		})
+		var total int64
+		for _, n := range lista {
+			n.Materiales = conteos[n.ID] + ordenar(n.Hijas)
+			total += n.Materiales
+		}
+		return total
+	}
+	ordenar(raices)
+
+	return raices
+}
+
+// cargarArbolCategorias lee las categorías y cuenta los materiales aprobados de cada una
+func cargarArbolCategorias(db *gorm.DB) ([]*NodoCategoria, error) {
+	var categorias []models.Categoria
+	if err := db.Find(&categorias).Error; err != nil {
+		return nil, err
+	}
+
+	var filas []struct {
+		CategoriaID uuid.UUID
+		Total       int64
+	}
+	if err := db.Model(&models.Material{}).
+		Select("categoria_id, COUNT(*) AS total").
+		Where("estado = ? AND categoria_id IS NOT NULL", models.EstadoAprobado).
+		Group("categoria_id").Scan(&filas).Error; err != nil {
+		return nil, err
+	}
+	conteos := make(map[uuid.UUID]int64, len(filas))
+	for _, f := range filas {
+		conteos[f.CategoriaID] = f.Total
+	}
+
+	return arbolCategorias(categorias, conteos), nil
+}
+
+// CategoriaRequest es el cuerpo para crear o editar una categoría
+type CategoriaRequest struct {
+	Nombre      string     `json:"nombre"`
+	Slug        string     `json:"slug"` // Opcional, se genera del nombre
+	Descripcion string     `json:"descripcion"`
+	ParentID    *uuid.UUID `json:"parent_id"`
+	Orden       int        `json:"orden"`
+}
+
+// aplicar valida el request y lo copia a la categoría. Impide slugs repetidos y ciclos en el árbol
+func (req CategoriaRequest) aplicar(db *gorm.DB, cat *models.Categoria) (int, error) {
+	nombre := strings.TrimSpace(req.Nombre)
+	if nombre == "" {
+		return http.StatusBadRequest, errors.New("El nombre es requerido")
+	}
+	slug := slugCategoria(req.Slug)
+	if slug == "" {
+		slug = slugCategoria(nombre)
+	}
+	if slug == "" {
+		return http.StatusBadRequest, errors.New("No se pudo generar un slug a partir del nombre")
+	}
+
+	var repetidas int64
+	if err := db.Model(&models.Categoria{}).Where("slug = ? AND id <> ?", slug, cat.ID).Count(&repetidas).Error; err != nil {
+		return http.StatusInternalServerError, errors.New("Error verificando el slug")
+	}
+	if repetidas > 0 {
+		return http.StatusConflict, fmt.Errorf("Ya existe una categoría con el slug '%s'", slug)
+	}
+
+	// El padre debe existir y no puede ser la misma categoría ni una de sus descendientes
+	if req.ParentID != nil {
+		var ancestros []uuid.UUID
+		if err := db.Raw(`
+			WITH RECURSIVE arriba AS (
+				SELECT id, parent_id FROM categorias WHERE id = ?
+				UNION
+				SELECT c.id, c.parent_id FROM categorias c JOIN arriba ON c.id = arriba.parent_id
+			) SELECT id FROM arriba`, *req.ParentID).Scan(&ancestros).Error; err != nil {
+			return http.StatusInternalServerError, errors.New("Error verificando la categoría padre")
+		}
+		if len(ancestros) == 0 {
+			return http.StatusBadRequest, errors.New("La categoría padre no existe")
+		}
+		for _, id := range ancestros {
+			if id == cat.ID {
+				return http.StatusBadRequest, errors.New("Una categoría no puede quedar dentro de sí misma")
+			}
+		}
+	}
+
+	cat.Nombre = nombre
+	cat.Slug = slug
+	cat.Descripcion = strings.TrimSpace(req.Descripcion)
+	cat.ParentID = req.ParentID
+	cat.Orden = req.Orden
+	return 0, nil
+}
+
+// GetCategorias devuelve el árbol de categorías con la cantidad de materiales aprobados de cada rama
+func GetCategorias(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	arbol, err := cargarArbolCategorias(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error obteniendo categorías"})
+		return
+	}
+
+	c.JSON(http.StatusOK, arbol)
+}
+
+// CreateCategoria agrega una categoría al árbol - Solo Admin
+func CreateCategoria(c *gin.Context) {
+	var req CategoriaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos: " + err.Error()})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	categoria := models.Categoria{ID: uuid.New()}
+	if status, err := req.aplicar(db, &categoria); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if err := db.Create(&categoria).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando categoría: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, categoria)
+}
+
+// UpdateCategoria renombra o mueve una categoría dentro del árbol - Solo Admin
+func UpdateCategoria(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req CategoriaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos: " + err.Error()})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	var categoria models.Categoria
+	if err := db.First(&categoria, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Categoría no encontrada"})
+		return
+	}
+
+	if status, err := req.aplicar(db, &categoria); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if err := db.Save(&categoria).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando categoría: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, categoria)
+}
+
+// DeleteCategoria elimina una categoría. Sus hijas y sus materiales (también los de revisiones
+// pendientes) pasan a la categoría padre - Solo Admin
+func DeleteCategoria(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	var categoria models.Categoria
+	if err := db.First(&categoria, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Categoría no encontrada"})
+		return
+	}
+
+	var movidos int64
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Categoria{}).Where("parent_id = ?", id).
+			Update("parent_id", categoria.ParentID).Error; err != nil {
+			return err
+		}
+
+		res := tx.Unscoped().Model(&models.Material{}).Where("categoria_id = ?", id).
+			UpdateColumn("categoria_id", categoria.ParentID)
+		if res.Error != nil {
+			return res.Error
+		}
+		movidos = res.RowsAffected
+
+		var revisiones []models.RevisionMaterial
+		if err := tx.Where("estado = ? AND contenido->>'categoria_id' = ?", models.RevisionPendiente, id.String()).
+			Find(&revisiones).Error; err != nil {
+			return err
+		}
+		for _, r := range revisiones {
+			r.Contenido.CategoriaID = categoria.ParentID
+			if err := tx.Model(&models.RevisionMaterial{}).Where("id = ?", r.ID).
+				UpdateColumn("contenido", r.Contenido).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(&categoria).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error eliminando categoría: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "Categoría eliminada",
+		"materiales_movidos": movidos,
+		"categoria_destino":  categoria.ParentID,
+	})
+}
@@ -0,0 +1,442 @@
+package material
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"TT-SEM-2-BACK/api/database"
+	"TT-SEM-2-BACK/api/middleware"
+	"TT-SEM-2-BACK/api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ComentarioRequest es un comentario de moderación enviado por el frontend
+type ComentarioRequest struct {
+	Seccion    models.SeccionComentario `json:"seccion"`
+	Referencia string                   `json:"referencia"`
+	Mensaje    string                   `json:"mensaje"`
+	ParentID   *uuid.UUID               `json:"parent_id"`
+}
+
+// ComentarioConEstado es un hilo de comentarios con la indicación de si la parte comentada cambió después
+type ComentarioConEstado struct {
+	models.ComentarioRevision
+	ModificadoDesde bool `json:"modificado_desde"`
+}
+
+// revisionActual devuelve la versión en curso del material: la revisión pendiente si hay,
+// si no la última aplicada/aprobada
+func revisionActual(db *gorm.DB, materialID uuid.UUID) (*models.RevisionMaterial, error) {
+	pendiente, err := buscarRevisionPendiente(db, materialID)
+	if err != nil || pendiente != nil {
+		return pendiente, err
+	}
+
+	var rev models.RevisionMaterial
+	err = db.Where("material_id = ? AND estado IN ?", materialID,
+		[]models.EstadoRevision{models.RevisionAplicada, models.RevisionAprobada}).
+		Order("numero DESC").
+		First(&rev).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// validarComentario revisa que la sección exista y que la referencia apunte a algo del contenido comentado
+func validarComentario(snap models.SnapshotMaterial, req ComentarioRequest) error {
+	if strings.TrimSpace(req.Mensaje) == "" {
+		return errors.New("El mensaje del comentario es obligatorio")
+	}
+	if !req.Seccion.Valida() {
+		return fmt.Errorf("Sección '%s' inválida", req.Seccion)
+	}
+	if req.Referencia == "" || req.Seccion == models.SeccionGeneral {
+		return nil
+	}
+
+	if !referenciaExiste(snap, req.Seccion, req.Referencia) {
+		return fmt.Errorf("'%s' no existe en la sección %s", req.Referencia, req.Seccion)
+	}
+	return nil
+}
+
+// referenciaExiste busca la referencia dentro de la sección del snapshot
+func referenciaExiste(snap models.SnapshotMaterial, seccion models.SeccionComentario, ref string) bool {
+	switch seccion {
+	case models.SeccionCampo:
+		switch ref {
+		case "nombre", "descripcion", "derivado_de", "categoria_id", "etiquetas":
+			return true
+		}
+		return false
+	case models.SeccionComposicion:
+		return contieneClave(snap.Composicion, ref, func(c models.Componente) string { return c.Elemento })
+	case models.SeccionPropMecanicas:
+		return contieneClave(snap.PropiedadesMecanicas, ref, func(p models.PropiedadMecanica) string { return p.Nombre })
+	case models.SeccionPropPerceptivas:
+		return contieneClave(snap.PropiedadesPerceptivas, ref, func(p models.PropiedadGeneral) string { return p.Nombre })
+	case models.SeccionPropEmocionales:
+		return contieneClave(snap.PropiedadesEmocionales, ref, func(p models.PropiedadGeneral) string { return p.Nombre })
+	case models.SeccionHerramientas:
+		return contieneClave(snap.Herramientas, ref, func(h string) string { return h })
+	case models.SeccionPaso:
+		return contieneClave(snap.Pasos, ref, func(p models.SnapshotPaso) string { return strconv.Itoa(p.OrdenPaso) })
+	case models.SeccionGaleria:
+		return contieneClave(snap.Galeria, ref, func(g models.SnapshotGaleria) string { return g.URLImagen })
+	}
+	return false
+}
+
+func contieneClave[T any](items []T, ref string, clave func(T) string) bool {
+	for _, it := range items {
+		if strings.EqualFold(strings.TrimSpace(clave(it)), strings.TrimSpace(ref)) {
+			return true
+		}
+	}
+	return false
+}
+
+// listaToca indica si el diff de una lista afecta a la referencia (o a cualquier elemento si no hay referencia)
+func listaToca[T any](l CambioLista[T], ref string, clave func(T) string) bool {
+	if ref == "" {
+		return !l.Vacio()
+	}
+	if contieneClave(l.Agregados, ref, clave) || contieneClave(l.Eliminados, ref, clave) {
+		return true
+	}
+	for _, m := range l.Modificados {
+		if strings.EqualFold(strings.TrimSpace(m.Clave), strings.TrimSpace(ref)) {
+			return true
+		}
+	}
+	return false
+}
+
+// referenciaModificada indica si la parte comentada cambió entre dos versiones
+func referenciaModificada(diff DiffSnapshot, seccion models.SeccionComentario, ref string) bool {
+	switch seccion {
+	case models.SeccionGeneral:
+		return !diff.SinCambios
+	case models.SeccionCampo:
+		// Las etiquetas son una lista: el diff no las reporta como campo
+		etiquetas := len(diff.Etiquetas.Agregadas) > 0 || len(diff.Etiquetas.Eliminadas) > 0
+		if ref == "etiquetas" || (ref == "" && etiquetas) {
+			return etiquetas
+		}
+		for _, campo := range diff.Campos {
+			if ref == "" || campo.Campo == ref {
+				return true
+			}
+		}
+		return false
+	case models.SeccionComposicion:
+		return listaToca(diff.Composicion, ref, func(c models.Componente) string { return c.Elemento })
+	case models.SeccionPropMecanicas:
+		return listaToca(diff.PropMecanicas, ref, func(p models.PropiedadMecanica) string { return p.Nombre })
+	case models.SeccionPropPerceptivas:
+		return listaToca(diff.PropPerceptivas, ref, func(p models.PropiedadGeneral) string { return p.Nombre })
+	case models.SeccionPropEmocionales:
+		return listaToca(diff.PropEmocionales, ref, func(p models.PropiedadGeneral) string { return p.Nombre })
+	case models.SeccionHerramientas:
+		cambios := append(append([]string{}, diff.Herramientas.Agregadas...), diff.Herramientas.Eliminadas...)
+		if ref == "" {
+			return len(cambios) > 0
+		}
+		return contieneClave(cambios, ref, func(h string) string { return h })
+	case models.SeccionPaso:
+		return listaToca(diff.Pasos, ref, func(p models.SnapshotPaso) string { return strconv.Itoa(p.OrdenPaso) })
+	case models.SeccionGaleria:
+		return listaToca(diff.Galeria, ref, func(g models.SnapshotGaleria) string { return g.URLImagen })
+	}
+	return false
+}
+
+// crearComentarios guarda comentarios raíz sobre una revisión del material
+func crearComentarios(db *gorm.DB, materialID uuid.UUID, numero int, autorID string, reqs []ComentarioRequest) ([]models.ComentarioRevision, error) {
+	comentarios := make([]models.ComentarioRevision, 0, len(reqs))
+	for _, req := range reqs {
+		comentarios = append(comentarios, models.ComentarioRevision{
+			MaterialID: materialID,
+			Revision:   numero,
+			Seccion:    req.Seccion,
+			Referencia: strings.TrimSpace(req.Referencia),
+			AutorID:    autorID,
+			Mensaje:    strings.TrimSpace(req.Mensaje),
+		})
+	}
+	if len(comentarios) == 0 {
+		return comentarios, nil
+	}
+	return comentarios, db.Create(&comentarios).Error
+}
+
+// comentariosConEstado carga los hilos del material y marca los que cambiaron desde que se comentaron
+func comentariosConEstado(db *gorm.DB, materialID uuid.UUID, resuelto *bool) ([]ComentarioConEstado, error) {
+	query := db.Preload("Autor").
+		Preload("Respuestas", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		Preload("Respuestas.Autor").
+		Where("material_id = ? AND parent_id IS NULL", materialID)
+	if resuelto != nil {
+		query = query.Where("resuelto = ?", *resuelto)
+	}
+
+	var hilos []models.ComentarioRevision
+	if err := query.Order("created_at ASC").Find(&hilos).Error; err != nil {
+		return nil, err
+	}
+
+	actual, err := revisionActual(db, materialID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Contenido de cada revisión comentada, para compararlo con la versión en curso
+	numeros := []int{}
+	for _, h := range hilos {
+		numeros = append(numeros, h.Revision)
+	}
+	var revisiones []models.RevisionMaterial
+	if len(numeros) > 0 {
+		if err := db.Where("material_id = ? AND numero IN ?", materialID, numeros).Find(&revisiones).Error; err != nil {
+			return nil, err
+		}
+	}
+	contenidos := make(map[int]models.SnapshotMaterial, len(revisiones))
+	for _, r := range revisiones {
+		contenidos[r.Numero] = r.Contenido
+	}
+
+	diffs := map[int]DiffSnapshot{}
+	resultado := make([]ComentarioConEstado, 0, len(hilos))
+	for _, h := range hilos {
+		item := ComentarioConEstado{ComentarioRevision: h}
+		antes, ok := contenidos[h.Revision]
+		if actual != nil && ok && actual.Numero != h.Revision {
+			diff, calculado := diffs[h.Revision]
+			if !calculado {
+				diff = diffSnapshots(antes, actual.Contenido)
+				diffs[h.Revision] = diff
+			}
+			item.ModificadoDesde = referenciaModificada(diff, h.Seccion, h.Referencia)
+		}
+		resultado = append(resultado, item)
+	}
+
+	return resultado, nil
+}
+
+// GetComentarios lista los hilos de comentarios de moderación de un material - Cualquier colaborador o Admin
+func GetComentarios(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	material, _, ok := cargarMaterialConRol(c, db, models.RolLector)
+	if !ok {
+		return
+	}
+
+	// Filtro opcional ?resuelto=true|false
+	var resuelto *bool
+	if v := c.Query("resuelto"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parámetro 'resuelto' inválido"})
+			return
+		}
+		resuelto = &b
+	}
+
+	hilos, err := comentariosConEstado(db, material.ID, resuelto)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error obteniendo comentarios: " + err.Error()})
+		return
+	}
+
+	abiertos := 0
+	for _, h := range hilos {
+		if !h.Resuelto {
+			abiertos++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":       len(hilos),
+		"abiertos":    abiertos,
+		"comentarios": hilos,
+	})
+}
+
+// CreateComentario abre un hilo (solo admin) o responde a uno existente (propietario, editores o admin)
+func CreateComentario(c *gin.Context) {
+	var req ComentarioRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos: " + err.Error()})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	material, googleID, ok := cargarMaterialConRol(c, db, models.RolEditor)
+	if !ok {
+		return
+	}
+
+	actual, err := revisionActual(db, material.ID)
+	if err != nil || actual == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se encontró la versión en curso del material"})
+		return
+	}
+
+	// 1. Respuesta a un hilo existente
+	if req.ParentID != nil {
+		var padre models.ComentarioRevision
+		if err := db.Where("id = ? AND material_id = ? AND parent_id IS NULL", *req.ParentID, material.ID).
+			First(&padre).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hilo de comentarios no encontrado"})
+			return
+		}
+		if strings.TrimSpace(req.Mensaje) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "El mensaje del comentario es obligatorio"})
+			return
+		}
+
+		respuesta := models.ComentarioRevision{
+			MaterialID: material.ID,
+			Revision:   actual.Numero,
+			Seccion:    padre.Seccion,
+			Referencia: padre.Referencia,
+			ParentID:   &padre.ID,
+			AutorID:    googleID,
+			Mensaje:    strings.TrimSpace(req.Mensaje),
+		}
+		if err := db.Create(&respuesta).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando respuesta: " + err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Respuesta agregada", "comentario": respuesta})
+		return
+	}
+
+	// 2. Hilo nuevo: solo los moderadores comentan partes del material
+	if !middleware.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Solo un administrador puede abrir comentarios de revisión"})
+		return
+	}
+	if err := validarComentario(actual.Contenido, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comentarios, err := crearComentarios(db, material.ID, actual.Numero, googleID, []ComentarioRequest{req})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando comentario: " + err.Error()})
+		return
+	}
+
+	notificarComentarios(material.CreadorID, material.ID, material.Nombre, len(comentarios))
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Comentario agregado", "comentario": comentarios[0]})
+}
+
+// ResolveComentario marca un hilo como resuelto en la versión en curso - Propietario, editores o Admin
+func ResolveComentario(c *gin.Context) {
+	marcarComentario(c, true)
+}
+
+// ReopenComentario vuelve a abrir un hilo resuelto - Propietario, editores o Admin
+func ReopenComentario(c *gin.Context) {
+	marcarComentario(c, false)
+}
+
+func marcarComentario(c *gin.Context, resuelto bool) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	material, googleID, ok := cargarMaterialConRol(c, db, models.RolEditor)
+	if !ok {
+		return
+	}
+
+	comentarioID, err := uuid.Parse(c.Param("comentarioId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de comentario inválido"})
+		return
+	}
+
+	var hilo models.ComentarioRevision
+	if err := db.Where("id = ? AND material_id = ? AND parent_id IS NULL", comentarioID, material.ID).
+		First(&hilo).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Hilo de comentarios no encontrado"})
+		return
+	}
+
+	cambios := map[string]interface{}{
+		"resuelto":             false,
+		"resuelto_por_id":      "",
+		"resuelto_en":          nil,
+		"resuelto_en_revision": nil,
+	}
+	if resuelto {
+		actual, err := revisionActual(db, material.ID)
+		if err != nil || actual == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se encontró la versión en curso del material"})
+			return
+		}
+		cambios = map[string]interface{}{
+			"resuelto":             true,
+			"resuelto_por_id":      googleID,
+			"resuelto_en":          time.Now().UTC(),
+			"resuelto_en_revision": actual.Numero,
+		}
+	}
+
+	if err := db.Model(&hilo).Updates(cambios).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error actualizando comentario: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comentario actualizado", "comentario": hilo})
+}
+
+// notificarComentarios avisa al creador que tiene comentarios nuevos de revisión
+func notificarComentarios(usuarioID string, matID uuid.UUID, matNombre string, cantidad int) {
+	go func() {
+		db, err := database.GetDB()
+		if err != nil {
+			log.Printf("⚠️ Error conectando DB para notificación: %v", err)
+			return
+		}
+
+		db.Create(&models.Notificacion{
+			UsuarioID:  usuarioID,
+			MaterialID: &matID,
+			Titulo:     "Comentarios de Revisión",
+			Mensaje:    fmt.Sprintf("Tu material '%s' tiene %d comentario(s) nuevo(s) de revisión.", matNombre, cantidad),
+			Tipo:       "info",
+			Link:       "/material/" + matID.String() + "/comentarios",
+		})
+	}()
+}
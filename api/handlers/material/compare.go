@@ -0,0 +1,234 @@
+package material
+
+import (
+	"fmt"
+	"net/http"
+
+	"TT-SEM-2-BACK/api/database"
+	"TT-SEM-2-BACK/api/models"
+	"TT-SEM-2-BACK/api/units"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	minComparar = 2
+	maxComparar = 6
+)
+
+// CeldaComparacion es el valor de una fila para un material. Presente = false marca que el material no la tiene
+type CeldaComparacion struct {
+	Presente        bool     `json:"presente"`
+	Valor           string   `json:"valor,omitempty"`
+	Unidad          string   `json:"unidad,omitempty"`
+	ValorConvertido *float64 `json:"valor_convertido,omitempty"` // Solo mecánicas, en la unidad de la fila
+}
+
+// FilaComparacion es una fila de la matriz: un elemento, propiedad o herramienta con una celda por material
+type FilaComparacion struct {
+	Clave   string             `json:"clave"`
+	Unidad  string             `json:"unidad,omitempty"` // Unidad común de valor_convertido (mecánicas)
+	Valores []CeldaComparacion `json:"valores"`
+}
+
+// ColumnaComparacion identifica a cada material en el orden en que se pidió
+type ColumnaComparacion struct {
+	ID                   uuid.UUID `json:"id"`
+	Nombre               string    `json:"nombre"`
+	PrimeraImagenGaleria string    `json:"primera_imagen_galeria,omitempty"`
+	CantidadPasos        int       `json:"cantidad_pasos"`
+}
+
+// alinear arma las filas con la unión de claves de todos los materiales, en orden de aparición.
+// Las claves se comparan sin mayúsculas ni acentos y se muestra el primer nombre encontrado
+func alinear[T any](listas [][]T, clave func(T) string, celda func(T) CeldaComparacion) []FilaComparacion {
+	filas := []FilaComparacion{}
+	indice := make(map[string]int)
+
+	for col, lista := range listas {
+		for _, item := range lista {
+			k := claveProp(clave(item))
+			if k == "" {
+				continue
+			}
+			i, ok := indice[k]
+			if !ok {
+				i = len(filas)
+				indice[k] = i
+				filas = append(filas, FilaComparacion{
+					Clave:   clave(item),
+					Valores: make([]CeldaComparacion, len(listas)),
+				})
+			}
+			// Si un material repite la clave se queda la primera
+			if !filas[i].Valores[col].Presente {
+				filas[i].Valores[col] = celda(item)
+			}
+		}
+	}
+	return filas
+}
+
+// convertirFilaMecanica pone todos los valores de la fila en una unidad común: la del primer material
+// que tenga un valor interpretable. Los de magnitudes incompatibles quedan sin valor_convertido
+func convertirFilaMecanica(fila *FilaComparacion, props [][]models.PropiedadMecanica) {
+	var comun *units.Unidad
+	for col := range fila.Valores {
+		if !fila.Valores[col].Presente {
+			continue
+		}
+		p := buscarPropiedad(props[col], fila.Clave)
+		if p == nil || p.ValorNormalizado == nil {
+			continue
+		}
+		original, ok := units.Buscar(p.Unidad)
+		if !ok {
+			original, ok = units.Buscar(p.UnidadNormalizada)
+		}
+		if ok {
+			comun = &original
+			break
+		}
+	}
+	if comun == nil {
+		return
+	}
+
+	fila.Unidad = comun.Simbolo
+	for col := range fila.Valores {
+		p := buscarPropiedad(props[col], fila.Clave)
+		if p == nil || p.ValorNormalizado == nil {
+			continue
+		}
+		base, ok := units.Buscar(p.UnidadNormalizada)
+		if !ok || base.Magnitud != comun.Magnitud {
+			continue
+		}
+		v := comun.DesdeBase(*p.ValorNormalizado)
+		fila.Valores[col].ValorConvertido = &v
+	}
+}
+
+func buscarPropiedad(props []models.PropiedadMecanica, nombre string) *models.PropiedadMecanica {
+	for i := range props {
+		if claveProp(props[i].Nombre) == claveProp(nombre) {
+			return &props[i]
+		}
+	}
+	return nil
+}
+
+// CompareMaterials devuelve una matriz alineada para comparar de 2 a 6 materiales aprobados.
+// ?ids=a,b,c (o ?ids=a&ids=b). Las columnas siguen el orden pedido
+func CompareMaterials(c *gin.Context) {
+	// 1. Validar los IDs
+	valores := valoresFiltro(c, "ids")
+	if len(valores) < minComparar || len(valores) > maxComparar {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Se pueden comparar entre %d y %d materiales", minComparar, maxComparar)})
+		return
+	}
+	ids := make([]uuid.UUID, 0, len(valores))
+	vistos := make(map[uuid.UUID]bool)
+	for _, v := range valores {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido: " + v})
+			return
+		}
+		if vistos[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID repetido: " + v})
+			return
+		}
+		vistos[id] = true
+		ids = append(ids, id)
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	// 2. Cargar solo aprobados
+	var encontrados []models.Material
+	if err := db.Where("id IN ? AND estado = ?", ids, models.EstadoAprobado).
+		Preload("Pasos").
+		Preload("Galeria", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Find(&encontrados).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error cargando materiales: " + err.Error()})
+		return
+	}
+
+	porID := make(map[uuid.UUID]models.Material, len(encontrados))
+	for _, m := range encontrados {
+		porID[m.ID] = m
+	}
+	faltantes := []uuid.UUID{}
+	materiales := make([]models.Material, 0, len(ids))
+	for _, id := range ids {
+		m, ok := porID[id]
+		if !ok {
+			faltantes = append(faltantes, id)
+			continue
+		}
+		materiales = append(materiales, m)
+	}
+	if len(faltantes) > 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":     "Materiales no encontrados o no aprobados",
+			"faltantes": faltantes,
+		})
+		return
+	}
+
+	// 3. Columnas y listas por material
+	columnas := make([]ColumnaComparacion, len(materiales))
+	composiciones := make([][]models.Componente, len(materiales))
+	mecanicas := make([][]models.PropiedadMecanica, len(materiales))
+	perceptivas := make([][]models.PropiedadGeneral, len(materiales))
+	emocionales := make([][]models.PropiedadGeneral, len(materiales))
+	herramientas := make([][]string, len(materiales))
+	for i, m := range materiales {
+		columnas[i] = ColumnaComparacion{ID: m.ID, Nombre: m.Nombre, CantidadPasos: len(m.Pasos)}
+		if len(m.Galeria) > 0 {
+			columnas[i].PrimeraImagenGaleria = m.Galeria[0].URLImagen
+		}
+		composiciones[i] = m.Composicion
+		mecanicas[i] = m.PropiedadesMecanicas
+		perceptivas[i] = m.PropiedadesPerceptivas
+		emocionales[i] = m.PropiedadesEmocionales
+		herramientas[i] = m.Herramientas
+	}
+
+	// 4. Matriz
+	general := func(p models.PropiedadGeneral) CeldaComparacion {
+		return CeldaComparacion{Presente: true, Valor: p.Valor}
+	}
+	nombreGeneral := func(p models.PropiedadGeneral) string { return p.Nombre }
+
+	filasMecanicas := alinear(mecanicas,
+		func(p models.PropiedadMecanica) string { return p.Nombre },
+		func(p models.PropiedadMecanica) CeldaComparacion {
+			return CeldaComparacion{Presente: true, Valor: p.Valor, Unidad: p.Unidad}
+		})
+	for i := range filasMecanicas {
+		convertirFilaMecanica(&filasMecanicas[i], mecanicas)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"materiales": columnas,
+		"composicion": alinear(composiciones,
+			func(comp models.Componente) string { return comp.Elemento },
+			func(comp models.Componente) CeldaComparacion {
+				return CeldaComparacion{Presente: true, Valor: comp.Cantidad}
+			}),
+		"prop_mecanicas":   filasMecanicas,
+		"prop_perceptivas": alinear(perceptivas, nombreGeneral, general),
+		"prop_emocionales": alinear(emocionales, nombreGeneral, general),
+		"herramientas": alinear(herramientas,
+			func(h string) string { return h },
+			func(string) CeldaComparacion { return CeldaComparacion{Presente: true} }),
+	})
+}
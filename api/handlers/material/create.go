@@ -1,17 +1,17 @@
 package material
 
 import (
-	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
-	"strings"
+	"strconv"
 
 	"TT-SEM-2-BACK/api/database"
 	"TT-SEM-2-BACK/api/models"
+	"TT-SEM-2-BACK/api/storage"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // CreateMaterial maneja la creación de un material
@@ -30,200 +30,116 @@ func CreateMaterial(c *gin.Context) {
 		return
 	}
 
-	// 2. Parsear form-data (max 32MB para uploads)
-	if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Error parseando form-data: " + err.Error()})
+	store, err := storage.GetStorage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error configurando el storage: " + err.Error()})
 		return
 	}
 
-	// 3. Extraer y Validar campos básicos
-	nombre := c.PostForm("nombre")
-	if nombre == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "El campo 'nombre' es requerido"})
+	// 2. Parsear form-data (max 32MB para uploads)
+	if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Error parseando form-data: " + err.Error()})
 		return
 	}
-	descripcion := c.PostForm("descripcion")
-
-	// 4. Parsear JSONs Complejos
-
-	// Herramientas (Array de Strings)
-	var herramientas models.StringArray
-	if str := c.PostForm("herramientas"); str != "" {
-		if err := json.Unmarshal([]byte(str), &herramientas); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de herramientas inválido"})
-			return
-		}
-	}
 
-	// Composición (Array de Objetos: Elemento + Cantidad)
-	var composicion models.JSONComponentes
-	if str := c.PostForm("composicion"); str != "" {
-		if err := json.Unmarshal([]byte(str), &composicion); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de composición inválido"})
-			return
-		}
+	// 3. Validar todos los campos antes de subir nada. Se reportan todos los errores juntos
+	snap := models.SnapshotMaterial{}
+	errores := aplicarCamposForm(c, &snap)
+	advertencias := normalizarMecanicas(snap.PropiedadesMecanicas)
+	avisosVocabulario := revisarVocabulario(db, &snap)
+	errores = append(errores, validarIngredientes(db, snap.Composicion)...)
+	errores = append(errores, validarCategoria(db, snap)...)
+	if snap.Nombre == "" {
+		errores = append([]ErrorCampo{{Campo: "nombre", Error: "El campo 'nombre' es requerido"}}, errores...)
 	}
 
-	// Propiedades Mecánicas (Nombre + Valor + Unidad)
-	var propMecanicas models.JSONMecanicas
-	if str := c.PostForm("prop_mecanicas"); str != "" {
-		if err := json.Unmarshal([]byte(str), &propMecanicas); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de prop. mecánicas inválido"})
-			return
-		}
+	colaboradoresCampo := "colaboradores"
+	colaboradoresStr := c.PostForm("colaboradores")
+	if colaboradoresStr == "" {
+		colaboradoresCampo = "colaboradores_material"
+		colaboradoresStr = c.PostForm("colaboradores_material")
 	}
-
-	// Propiedades Perceptivas (Nombre + Valor)
-	var propPerceptivas models.JSONGenerales
-	if str := c.PostForm("prop_perceptivas"); str != "" {
-		if err := json.Unmarshal([]byte(str), &propPerceptivas); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de prop. perceptivas inválido"})
-			return
+	colaboradores := []colaboradorPedido{}
+	if colaboradoresStr != "" {
+		pedidos, errCampo := leerColaboradores(colaboradoresCampo, colaboradoresStr)
+		if errCampo != nil {
+			errores = append(errores, *errCampo)
+		} else {
+			colaboradores = pedidos
 		}
 	}
 
-	// Propiedades Emocionales (Nombre + Valor)
-	var propEmocionales models.JSONGenerales
-	if str := c.PostForm("prop_emocionales"); str != "" {
-		if err := json.Unmarshal([]byte(str), &propEmocionales); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Formato de prop. emocionales inválido"})
-			return
-		}
+	if len(errores) > 0 {
+		responderErroresCampos(c, http.StatusBadRequest, "Datos del material inválidos", errores)
+		return
 	}
 
-	// Derivado De (UUID opcional)
-	var derivadoDe uuid.UUID
-	if str := c.PostForm("derivado_de"); str != "" {
-		parsedUUID, err := uuid.Parse(str)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "UUID de derivado_de inválido"})
-			return
-		}
-		derivadoDe = parsedUUID
+	// 4. Subir galería y archivos de los pasos. Si alguno falla se borra lo ya subido
+	materialID := uuid.New()
+	sub := nuevasSubidas(store)
+	if errores := aplicarArchivosForm(c, sub, &snap, prefijoMaterial(materialID)); len(errores) > 0 {
+		sub.compensar()
+		responderErroresCampos(c, http.StatusInternalServerError, "Error subiendo archivos, no se guardó el material", errores)
+		return
 	}
 
 	// 5. Crear el Objeto Material
 	material := models.Material{
-		ID:                     uuid.New(),
-		Nombre:                 nombre,
-		Descripcion:            descripcion,
-		Herramientas:           herramientas,
-		Composicion:            composicion,
-		PropiedadesMecanicas:   propMecanicas,
-		PropiedadesPerceptivas: propPerceptivas,
-		PropiedadesEmocionales: propEmocionales,
-		DerivadoDe:             derivadoDe,
+		ID:                     materialID,
+		Nombre:                 snap.Nombre,
+		Descripcion:            snap.Descripcion,
+		Herramientas:           snap.Herramientas,
+		Composicion:            snap.Composicion,
+		PropiedadesMecanicas:   snap.PropiedadesMecanicas,
+		PropiedadesPerceptivas: snap.PropiedadesPerceptivas,
+		PropiedadesEmocionales: snap.PropiedadesEmocionales,
+		DerivadoDe:             snap.DerivadoDe,
 		CreadorID:              googleID,
-		Estado:                 false, // Pendiente de aprobación
+		Estado:                 models.EstadoBorrador, // Se envía a revisión solo si el autor lo pide
 	}
+	enviar := enviarARevision(c)
 
-	// Guardar Material (Esto guarda automáticamente los JSONs en las columnas jsonb)
-	if err := db.Create(&material).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando material en BD: " + err.Error()})
-		return
-	}
-
-	// 6. Guardar Colaboradores
-	colaboradoresStr := c.PostForm("colaboradores")
-	if colaboradoresStr == "" {
-		colaboradoresStr = c.PostForm("colaboradores_material")
-	}
-
-	if colaboradoresStr != "" {
-		var emails []string
-		if err := json.Unmarshal([]byte(colaboradoresStr), &emails); err == nil && len(emails) > 0 {
-			var usuarios []models.Usuario
-			// Buscar usuarios por email
-			if err := db.Where("email IN ?", emails).Find(&usuarios).Error; err == nil {
-				// Insertar relaciones manualmente
-				for _, u := range usuarios {
-					link := models.ColaboradorMaterial{
-						MaterialID: material.ID,
-						UsuarioID:  u.GoogleID,
-					}
-					// Usamos db.Create directo sobre la estructura, GORM usará la tabla definida en TableName()
-					if err := db.Create(&link).Error; err != nil {
-						log.Printf("⚠️ Error asociando colaborador %s: %v", u.Email, err)
-					}
-				}
-			}
+	// 6. Guardar todo en una sola transacción: material, pasos, galería, invitaciones a colaborar,
+	// primera revisión del historial y, si el autor lo pidió, el envío a revisión
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&material).Error; err != nil {
+			return fmt.Errorf("error guardando material: %w", err)
 		}
-	}
-
-	// 7. Guardar Galería
-	var galeriaCaptions []string
-	if str := c.PostForm("galeria_captions"); str != "" {
-		json.Unmarshal([]byte(str), &galeriaCaptions)
-	}
-
-	files := c.Request.MultipartForm.File["galeria_images[]"]
-	for i, fileHeader := range files {
-		safeFilename := strings.ReplaceAll(fileHeader.Filename, " ", "_")
-		filePath := fmt.Sprintf("materials/%s/%s", material.ID.String(), safeFilename)
-
-		url, err := database.SubirAStorageSupabase(fileHeader, "pasos-bucket", filePath)
-		if err != nil {
-			log.Printf("Error subiendo imagen galería: %v", err)
-			continue
+		if err := aplicarSnapshot(tx, &material, snap); err != nil {
+			return err
 		}
-
-		caption := ""
-		if i < len(galeriaCaptions) {
-			caption = galeriaCaptions[i]
+		if err := sincronizarInvitaciones(tx, material, googleID, colaboradores); err != nil {
+			return err
 		}
-
-		db.Create(&models.GaleriaMaterial{
-			MaterialID: material.ID,
-			URLImagen:  url,
-			Caption:    caption,
-		})
-	}
-
-	// 8. Guardar Pasos
-	pasosStr := c.PostForm("pasos")
-	if pasosStr != "" {
-		var pasos []struct {
-			OrdenPaso   int    `json:"orden_paso"`
-			Descripcion string `json:"descripcion"`
+		if _, err := registrarRevisionAplicada(tx, material.ID, snap, googleID, nil); err != nil {
+			return fmt.Errorf("error registrando revisión inicial: %w", err)
 		}
-		if err := json.Unmarshal([]byte(pasosStr), &pasos); err == nil {
-			for i, p := range pasos {
-				pasoModel := models.PasoMaterial{
-					MaterialID:  material.ID,
-					OrdenPaso:   p.OrdenPaso,
-					Descripcion: p.Descripcion,
-				}
-
-				// Upload Imagen Paso
-				fileKeyImg := fmt.Sprintf("paso_images[%d]", i)
-				if headers := c.Request.MultipartForm.File[fileKeyImg]; len(headers) > 0 {
-					safeName := strings.ReplaceAll(headers[0].Filename, " ", "_")
-					path := fmt.Sprintf("materials/%s/pasos/%d/%s", material.ID.String(), i, safeName)
-					if url, err := database.SubirAStorageSupabase(headers[0], "pasos-bucket", path); err == nil {
-						pasoModel.URLImagen = url
-					}
-				}
-
-				// Upload Video Paso
-				fileKeyVid := fmt.Sprintf("paso_videos[%d]", i)
-				if headers := c.Request.MultipartForm.File[fileKeyVid]; len(headers) > 0 {
-					safeName := strings.ReplaceAll(headers[0].Filename, " ", "_")
-					path := fmt.Sprintf("materials/%s/pasos/%d/%s", material.ID.String(), i, safeName)
-					if url, err := database.SubirAStorageSupabase(headers[0], "pasos-bucket", path); err == nil {
-						pasoModel.URLVideo = url
-					}
-				}
-
-				db.Create(&pasoModel)
-			}
+		if enviar {
+			return cambiarEstado(tx, &material, models.EstadoPendiente, googleID, "")
 		}
+		return nil
+	}); err != nil {
+		sub.compensar()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando material en BD: " + err.Error()})
+		return
+	}
+
+	// 7. Avisar a los admins solo si quedó en la cola de revisión
+	if material.Estado == models.EstadoPendiente {
+		notificarAdmins(material.ID, material.Nombre, material.CreadorID)
 	}
 
-	// 9. Recargar y Responder
+	// 8. Recargar y Responder
 	db.Preload("Creador").Preload("Colaboradores").Preload("Galeria").Preload("Pasos").Find(&material)
+	invitaciones, _ := ultimasInvitaciones(db, material.ID)
+
+	c.JSON(http.StatusCreated, materialConAdvertencias{Material: material, Advertencias: advertencias, Vocabulario: avisosVocabulario, Invitaciones: invitaciones})
+}
 
-	notificarAdmins(material.ID, material.Nombre, material.CreadorID)
-	c.JSON(http.StatusCreated, material)
+// enviarARevision lee el campo opcional "enviar_revision" del form
+func enviarARevision(c *gin.Context) bool {
+	enviar, _ := strconv.ParseBool(c.PostForm("enviar_revision"))
+	return enviar
 }
 
 // Función auxiliar de notificaciones
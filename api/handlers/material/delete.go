@@ -1,14 +1,17 @@
 package material
 
 import (
+	"context"
 	"log"
 	"net/http"
 
 	"TT-SEM-2-BACK/api/database"
 	"TT-SEM-2-BACK/api/models"
+	"TT-SEM-2-BACK/api/storage"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // DeleteMaterial maneja la eliminación de un material
@@ -32,9 +35,9 @@ func DeleteMaterial(c *gin.Context) {
 	}
 	c.ShouldBindJSON(&req)
 
-	// Verificar existencia
+	// Verificar existencia (cargamos galería y pasos para limpiar sus archivos después)
 	var material models.Material
-	if err := db.First(&material, "id = ?", id).Error; err != nil {
+	if err := db.Preload("Galeria").Preload("Pasos").First(&material, "id = ?", id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Material no encontrado"})
 		return
 	}
@@ -48,6 +51,7 @@ func DeleteMaterial(c *gin.Context) {
 		log.Printf("⚠️ Error borrando colaboradores: %v", err)
 		// No retornamos error fatal, intentamos seguir borrando lo demás
 	}
+	db.Where("material_id = ?", id).Delete(&models.InvitacionColaborador{})
 
 	// 2. Eliminar Galería
 	db.Where("material_id = ?", id).Unscoped().Delete(&models.GaleriaMaterial{})
@@ -55,19 +59,49 @@ func DeleteMaterial(c *gin.Context) {
 	// 3. Eliminar Pasos
 	db.Where("material_id = ?", id).Unscoped().Delete(&models.PasoMaterial{})
 
-	// 4. Eliminar Material
+	// 4. Eliminar Revisiones e Historial de estados
+	db.Where("material_id = ?", id).Delete(&models.RevisionMaterial{})
+	db.Where("material_id = ?", id).Delete(&models.HistorialEstado{})
+	db.Where("material_id = ?", id).Delete(&models.ComentarioRevision{})
+
+	// 5. Eliminar Material
 	// (Las propiedades JSON se borran junto con el material, no hay que hacer nada extra)
 	if err := db.Unscoped().Delete(&material).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error eliminando material: " + err.Error()})
 		return
 	}
 
+	// 6. Eliminar archivos del storage que ya no usa ninguna fila
+	limpiarArchivosMaterial(c.Request.Context(), db, material)
+
 	// Notificar
 	sendDeleteNotification(creadorID, nombreMaterial, req.Razon)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Material eliminado exitosamente"})
 }
 
+// limpiarArchivosMaterial borra los archivos del material eliminado y cualquier huérfano en su carpeta
+func limpiarArchivosMaterial(ctx context.Context, db *gorm.DB, material models.Material) {
+	store, err := storage.GetStorage()
+	if err != nil {
+		log.Printf("⚠️ Storage no disponible, no se limpiaron archivos de %s: %v", material.ID, err)
+		return
+	}
+
+	// Primero las URLs conocidas (pueden estar fuera de la carpeta del material)
+	eliminarArchivosSinUso(ctx, db, store, urlsArchivosMaterial(material.Galeria, material.Pasos))
+
+	// Luego todo lo que quede en su carpeta y nadie referencie
+	reporte, err := reconciliarStorage(ctx, db, store, prefijoMaterial(material.ID), 0, false)
+	if err != nil {
+		log.Printf("⚠️ Error limpiando carpeta de %s: %v", material.ID, err)
+		return
+	}
+	for _, e := range reporte.Errores {
+		log.Printf("⚠️ Error limpiando archivo: %s", e)
+	}
+}
+
 func sendDeleteNotification(usuarioId string, materialName string, mensajeExtra string) {
 	go func() {
 		db, _ := database.GetDB()
@@ -0,0 +1,149 @@
+package material
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"TT-SEM-2-BACK/api/database"
+	"TT-SEM-2-BACK/api/middleware"
+	"TT-SEM-2-BACK/api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// diffConPadre compara el contenido de un material derivado con la versión publicada de su padre.
+// Devuelve nil si el material no deriva de otro, el padre ya no existe o el usuario no puede verlo
+// (no está aprobado y no colabora en él)
+func diffConPadre(db *gorm.DB, hijo models.SnapshotMaterial, googleID string, isAdmin bool) (*DiffSnapshot, error) {
+	if hijo.DerivadoDe == uuid.Nil {
+		return nil, nil
+	}
+	padre, err := cargarMaterialEditable(db, hijo.DerivadoDe)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if padre.Estado != models.EstadoAprobado && rolEnMaterial(db, padre, googleID, isAdmin) == "" {
+		return nil, nil
+	}
+
+	diff := diffDerivado(models.SnapshotDe(padre), hijo)
+	return &diff, nil
+}
+
+// diffDerivado compara un derivado con su padre ignorando derivado_de, que siempre difiere
+// (el hijo apunta al padre) y no es un cambio de la receta
+func diffDerivado(padre, hijo models.SnapshotMaterial) DiffSnapshot {
+	hijo.DerivadoDe = padre.DerivadoDe
+	return diffSnapshots(padre, hijo)
+}
+
+// DeriveRequest son los datos opcionales con los que nace el derivado
+type DeriveRequest struct {
+	Nombre      string `json:"nombre"`
+	Descripcion string `json:"descripcion"`
+}
+
+// DeriveMaterial crea un borrador del usuario copiando composición, propiedades, herramientas,
+// pasos y galería de otro material, con derivado_de apuntando a él. Los archivos no se duplican:
+// el derivado reutiliza las mismas URLs y la limpieza del storage solo borra lo que nadie referencia
+func DeriveMaterial(c *gin.Context) {
+	googleID, exists := middleware.GetUserGoogleID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Datos de usuario incompletos"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req DeriveRequest
+	// El cuerpo es opcional
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos: " + err.Error()})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	// 1. El padre debe ser público, o el usuario colaborar en él (o ser admin)
+	padre, err := cargarMaterialEditable(db, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material no encontrado"})
+		return
+	}
+	if padre.Estado != models.EstadoAprobado && rolEnMaterial(db, padre, googleID, middleware.IsAdmin(c)) == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material no encontrado o no está aprobado"})
+		return
+	}
+
+	// 2. Contenido del derivado
+	snap := copiarSnapshot(models.SnapshotDe(padre))
+	snap.Composicion = append(models.JSONComponentes{}, padre.Composicion...)
+	snap.PropiedadesMecanicas = append(models.JSONMecanicas{}, padre.PropiedadesMecanicas...)
+	snap.PropiedadesPerceptivas = append(models.JSONGenerales{}, padre.PropiedadesPerceptivas...)
+	snap.PropiedadesEmocionales = append(models.JSONGenerales{}, padre.PropiedadesEmocionales...)
+	snap.Herramientas = append(models.StringArray{}, padre.Herramientas...)
+	snap.DerivadoDe = padre.ID
+	snap.Nombre = fmt.Sprintf("%s (derivado)", padre.Nombre)
+	if nombre := strings.TrimSpace(req.Nombre); nombre != "" {
+		snap.Nombre = nombre
+	}
+	if desc := strings.TrimSpace(req.Descripcion); desc != "" {
+		snap.Descripcion = desc
+	}
+
+	material := models.Material{
+		ID:         uuid.New(),
+		Nombre:     snap.Nombre,
+		DerivadoDe: padre.ID,
+		CreadorID:  googleID,
+		Estado:     models.EstadoBorrador,
+	}
+
+	// 3. Crear fila, pasos, galería y primera revisión en una transacción
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&material).Error; err != nil {
+			return fmt.Errorf("error guardando material: %w", err)
+		}
+		if err := aplicarSnapshot(tx, &material, snap); err != nil {
+			return err
+		}
+		_, err := registrarRevisionAplicada(tx, material.ID, snap, googleID, nil)
+		return err
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creando derivado: " + err.Error()})
+		return
+	}
+
+	diferencias, err := diffConPadre(db, snap, googleID, middleware.IsAdmin(c))
+	if err != nil {
+		log.Printf("⚠️ Error comparando derivado %s con su padre: %v", material.ID, err)
+	}
+
+	log.Printf("🧬 Material %s derivado de %s por %s", material.ID, padre.ID, googleID)
+
+	db.Preload("Creador").Preload("Galeria").Preload("Pasos").Find(&material)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":               "Material derivado creado como borrador",
+		"material":              material,
+		"derivado_de":           padre.ID,
+		"diferencias_con_padre": diferencias,
+	})
+}
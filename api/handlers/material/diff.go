@@ -0,0 +1,214 @@
+package material
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"TT-SEM-2-BACK/api/models"
+
+	"github.com/google/uuid"
+)
+
+// CambioCampo es un campo simple que cambió
+type CambioCampo struct {
+	Campo   string      `json:"campo"`
+	Antes   interface{} `json:"antes"`
+	Despues interface{} `json:"despues"`
+}
+
+// CambioElemento es un elemento de una lista que existe en ambas versiones pero cambió
+type CambioElemento[T any] struct {
+	Clave   string   `json:"clave"`
+	Campos  []string `json:"campos"`
+	Antes   T        `json:"antes"`
+	Despues T        `json:"despues"`
+}
+
+// CambioLista agrupa lo agregado, eliminado y modificado en una lista (composición, pasos, etc.)
+type CambioLista[T any] struct {
+	Agregados   []T                 `json:"agregados"`
+	Eliminados  []T                 `json:"eliminados"`
+	Modificados []CambioElemento[T] `json:"modificados"`
+}
+
+// Vacio indica si la lista no tuvo cambios
+func (l CambioLista[T]) Vacio() bool {
+	return len(l.Agregados) == 0 && len(l.Eliminados) == 0 && len(l.Modificados) == 0
+}
+
+// CambioHerramientas lista las herramientas (o etiquetas) agregadas y quitadas
+type CambioHerramientas struct {
+	Agregadas  []string `json:"agregadas"`
+	Eliminadas []string `json:"eliminadas"`
+}
+
+// DiffSnapshot es la diferencia campo a campo entre dos versiones de un material
+type DiffSnapshot struct {
+	SinCambios      bool                                  `json:"sin_cambios"`
+	Campos          []CambioCampo                         `json:"campos"`
+	Composicion     CambioLista[models.Componente]        `json:"composicion"`
+	PropMecanicas   CambioLista[models.PropiedadMecanica] `json:"prop_mecanicas"`
+	PropPerceptivas CambioLista[models.PropiedadGeneral]  `json:"prop_perceptivas"`
+	PropEmocionales CambioLista[models.PropiedadGeneral]  `json:"prop_emocionales"`
+	Herramientas    CambioHerramientas                    `json:"herramientas"`
+	Etiquetas       CambioHerramientas                    `json:"etiquetas"`
+	Pasos           CambioLista[models.SnapshotPaso]      `json:"pasos"`
+	Galeria         CambioLista[models.SnapshotGaleria]   `json:"galeria"`
+}
+
+// diffSnapshots compara dos versiones del contenido de un material
+func diffSnapshots(antes, despues models.SnapshotMaterial) DiffSnapshot {
+	diff := DiffSnapshot{Campos: []CambioCampo{}}
+
+	if antes.Nombre != despues.Nombre {
+		diff.Campos = append(diff.Campos, CambioCampo{"nombre", antes.Nombre, despues.Nombre})
+	}
+	if antes.Descripcion != despues.Descripcion {
+		diff.Campos = append(diff.Campos, CambioCampo{"descripcion", antes.Descripcion, despues.Descripcion})
+	}
+	if antes.DerivadoDe != despues.DerivadoDe {
+		diff.Campos = append(diff.Campos, CambioCampo{"derivado_de", antes.DerivadoDe, despues.DerivadoDe})
+	}
+	if !mismaCategoria(antes.CategoriaID, despues.CategoriaID) {
+		diff.Campos = append(diff.Campos, CambioCampo{"categoria_id", antes.CategoriaID, despues.CategoriaID})
+	}
+
+	diff.Composicion = diffLista(antes.Composicion, despues.Composicion, func(c models.Componente) string { return c.Elemento })
+	diff.PropMecanicas = diffLista(antes.PropiedadesMecanicas, despues.PropiedadesMecanicas, func(p models.PropiedadMecanica) string { return p.Nombre })
+	diff.PropPerceptivas = diffLista(antes.PropiedadesPerceptivas, despues.PropiedadesPerceptivas, func(p models.PropiedadGeneral) string { return p.Nombre })
+	diff.PropEmocionales = diffLista(antes.PropiedadesEmocionales, despues.PropiedadesEmocionales, func(p models.PropiedadGeneral) string { return p.Nombre })
+	diff.Herramientas = diffHerramientas(antes.Herramientas, despues.Herramientas)
+	diff.Etiquetas = diffHerramientas(antes.Etiquetas, despues.Etiquetas)
+	diff.Pasos = diffLista(antes.Pasos, despues.Pasos, func(p models.SnapshotPaso) string { return strconv.Itoa(p.OrdenPaso) })
+	diff.Galeria = diffLista(antes.Galeria, despues.Galeria, func(g models.SnapshotGaleria) string { return g.URLImagen })
+
+	diff.SinCambios = len(diff.Campos) == 0 &&
+		diff.Composicion.Vacio() && diff.PropMecanicas.Vacio() &&
+		diff.PropPerceptivas.Vacio() && diff.PropEmocionales.Vacio() &&
+		len(diff.Herramientas.Agregadas) == 0 && len(diff.Herramientas.Eliminadas) == 0 &&
+		len(diff.Etiquetas.Agregadas) == 0 && len(diff.Etiquetas.Eliminadas) == 0 &&
+		diff.Pasos.Vacio() && diff.Galeria.Vacio()
+
+	return diff
+}
+
+// mismaCategoria compara dos categorías opcionales
+func mismaCategoria(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// diffLista empareja los elementos por clave (sin distinguir mayúsculas) y compara los pares
+func diffLista[T any](antes, despues []T, clave func(T) string) CambioLista[T] {
+	lista := CambioLista[T]{
+		Agregados:   []T{},
+		Eliminados:  []T{},
+		Modificados: []CambioElemento[T]{},
+	}
+
+	clavesAntes := clavesUnicas(antes, clave)
+	porClave := make(map[string]T, len(antes))
+	for i, el := range antes {
+		porClave[clavesAntes[i]] = el
+	}
+
+	clavesDespues := clavesUnicas(despues, clave)
+	vistas := make(map[string]bool)
+	for i, el := range despues {
+		k := clavesDespues[i]
+		previo, ok := porClave[k]
+		if !ok {
+			lista.Agregados = append(lista.Agregados, el)
+			continue
+		}
+		vistas[k] = true
+		if campos := camposDistintos(previo, el); len(campos) > 0 {
+			lista.Modificados = append(lista.Modificados, CambioElemento[T]{
+				Clave:   clave(el),
+				Campos:  campos,
+				Antes:   previo,
+				Despues: el,
+			})
+		}
+	}
+
+	for i, el := range antes {
+		if !vistas[clavesAntes[i]] {
+			lista.Eliminados = append(lista.Eliminados, el)
+		}
+	}
+
+	return lista
+}
+
+// clavesUnicas normaliza las claves y numera las repetidas ("agua", "agua#2") para poder emparejarlas
+func clavesUnicas[T any](items []T, clave func(T) string) []string {
+	claves := make([]string, len(items))
+	vistas := make(map[string]int)
+	for i, el := range items {
+		k := strings.ToLower(strings.TrimSpace(clave(el)))
+		vistas[k]++
+		if vistas[k] > 1 {
+			k = fmt.Sprintf("%s#%d", k, vistas[k])
+		}
+		claves[i] = k
+	}
+	return claves
+}
+
+// camposDistintos devuelve los nombres JSON de los campos que difieren entre dos structs
+func camposDistintos[T any](a, b T) []string {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Kind() != reflect.Struct {
+		if reflect.DeepEqual(a, b) {
+			return nil
+		}
+		return []string{"valor"}
+	}
+
+	var campos []string
+	for i := 0; i < va.NumField(); i++ {
+		// Los campos derivados (diff:"-") cambian junto con los que los originan
+		if va.Type().Field(i).Tag.Get("diff") == "-" {
+			continue
+		}
+		if reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			continue
+		}
+		nombre := strings.Split(va.Type().Field(i).Tag.Get("json"), ",")[0]
+		if nombre == "" {
+			nombre = va.Type().Field(i).Name
+		}
+		campos = append(campos, nombre)
+	}
+	return campos
+}
+
+// diffHerramientas compara las herramientas como conjuntos (sin distinguir mayúsculas)
+func diffHerramientas(antes, despues models.StringArray) CambioHerramientas {
+	cambio := CambioHerramientas{Agregadas: []string{}, Eliminadas: []string{}}
+
+	enAntes := make(map[string]bool)
+	for _, h := range antes {
+		enAntes[strings.ToLower(strings.TrimSpace(h))] = true
+	}
+	enDespues := make(map[string]bool)
+	for _, h := range despues {
+		k := strings.ToLower(strings.TrimSpace(h))
+		enDespues[k] = true
+		if !enAntes[k] {
+			cambio.Agregadas = append(cambio.Agregadas, h)
+		}
+	}
+	for _, h := range antes {
+		if !enDespues[strings.ToLower(strings.TrimSpace(h))] {
+			cambio.Eliminadas = append(cambio.Eliminadas, h)
+		}
+	}
+
+	return cambio
+}
@@ -0,0 +1,155 @@
+package material
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"TT-SEM-2-BACK/api/database"
+	"TT-SEM-2-BACK/api/models"
+	"TT-SEM-2-BACK/api/units"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DeltaMecanica es el cambio numérico de una propiedad mecánica respecto al padre,
+// expresado en la unidad que usa el padre
+type DeltaMecanica struct {
+	Nombre           string   `json:"nombre"`
+	Antes            float64  `json:"antes"`
+	Despues          float64  `json:"despues"`
+	Unidad           string   `json:"unidad"`
+	Delta            float64  `json:"delta"`
+	PorcentajeCambio *float64 `json:"porcentaje_cambio"` // nil si el padre vale 0
+}
+
+// deltasMecanicas calcula el cambio de las propiedades que ambos tienen con valores comparables
+func deltasMecanicas(padre, hijo models.JSONMecanicas) []DeltaMecanica {
+	deltas := []DeltaMecanica{}
+	for _, p := range padre {
+		h := buscarPropiedad(hijo, p.Nombre)
+		if p.ValorNormalizado == nil || h == nil || h.ValorNormalizado == nil || p.UnidadNormalizada != h.UnidadNormalizada {
+			continue
+		}
+		if *p.ValorNormalizado == *h.ValorNormalizado {
+			continue
+		}
+
+		unidad, ok := units.Buscar(p.Unidad)
+		if !ok {
+			unidad, _ = units.Buscar(p.UnidadNormalizada)
+		}
+		antes, despues := unidad.DesdeBase(*p.ValorNormalizado), unidad.DesdeBase(*h.ValorNormalizado)
+
+		d := DeltaMecanica{
+			Nombre:  p.Nombre,
+			Antes:   antes,
+			Despues: despues,
+			Unidad:  unidad.Simbolo,
+			Delta:   despues - antes,
+		}
+		if antes != 0 {
+			pct := math.Round((despues-antes)/math.Abs(antes)*10000) / 100
+			d.PorcentajeCambio = &pct
+		}
+		deltas = append(deltas, d)
+	}
+	return deltas
+}
+
+// GetMaterialDiffParent compara un material derivado aprobado con la versión publicada de su padre:
+// composición (elementos agregados, quitados y cantidades cambiadas), propiedades mecánicas con
+// variación porcentual, herramientas y pasos
+func GetMaterialDiffParent(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	hijo, err := cargarMaterialEditable(db, id)
+	if err != nil || hijo.Estado != models.EstadoAprobado {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material no encontrado o no está aprobado"})
+		return
+	}
+	if hijo.DerivadoDe == uuid.Nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El material no deriva de otro"})
+		return
+	}
+
+	padre, err := cargarMaterialEditable(db, hijo.DerivadoDe)
+	if err != nil || padre.Estado != models.EstadoAprobado {
+		c.JSON(http.StatusNotFound, gin.H{"error": "El material padre ya no está disponible"})
+		return
+	}
+
+	diff := diffDerivado(models.SnapshotDe(padre), models.SnapshotDe(hijo))
+
+	c.JSON(http.StatusOK, gin.H{
+		"material_id":      hijo.ID,
+		"padre":            gin.H{"id": padre.ID, "nombre": padre.Nombre},
+		"resumen":          resumirDiff(diff),
+		"diff":             diff,
+		"deltas_mecanicas": deltasMecanicas(padre.PropiedadesMecanicas, hijo.PropiedadesMecanicas),
+	})
+}
+
+// derivadoConResumen es un material derivado con un resumen corto de lo que cambió respecto al padre
+type derivadoConResumen struct {
+	models.Material
+	ResumenCambios []string `json:"resumen_cambios"`
+}
+
+// conResumenDerivados agrega a cada hijo el resumen de cambios respecto al padre (?resumen=true)
+func conResumenDerivados(c *gin.Context, padreID uuid.UUID, hijos []models.Material) (interface{}, bool) {
+	if pedir, _ := strconv.ParseBool(c.Query("resumen")); !pedir {
+		return hijos, true
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return nil, false
+	}
+	padre, err := cargarMaterialEditable(db, padreID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material padre no encontrado"})
+		return nil, false
+	}
+	base := models.SnapshotDe(padre)
+
+	// Pasos y galería de todos los hijos en una sola carga, ordenados como en cargarMaterialEditable
+	ids := make([]uuid.UUID, len(hijos))
+	for i, h := range hijos {
+		ids[i] = h.ID
+	}
+	var completos []models.Material
+	if err := db.Where("id IN ?", ids).
+		Preload("Pasos", func(db *gorm.DB) *gorm.DB { return db.Order("orden_paso ASC") }).
+		Preload("Galeria", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Find(&completos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error comparando derivados: " + err.Error()})
+		return nil, false
+	}
+	porID := make(map[uuid.UUID]models.Material, len(completos))
+	for _, m := range completos {
+		porID[m.ID] = m
+	}
+
+	resultado := make([]derivadoConResumen, 0, len(hijos))
+	for _, h := range hijos {
+		resultado = append(resultado, derivadoConResumen{
+			Material:       h,
+			ResumenCambios: resumirDiff(diffDerivado(base, models.SnapshotDe(porID[h.ID]))),
+		})
+	}
+	return resultado, true
+}
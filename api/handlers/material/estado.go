@@ -0,0 +1,264 @@
+package material
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"TT-SEM-2-BACK/api/database"
+	"TT-SEM-2-BACK/api/middleware"
+	"TT-SEM-2-BACK/api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// errEstadoCambiado indica que otra petición cambió el estado del material después de leerlo
+var errEstadoCambiado = errors.New("el estado del material cambió mientras se procesaba la petición")
+
+// cambiarEstado valida la transición con la máquina de estados, la guarda y la registra en el historial.
+// Todo cambio de estado de un material debe pasar por aquí. La actualización exige que el estado siga
+// siendo el que se validó; si otra petición lo cambió antes devuelve errEstadoCambiado
+func cambiarEstado(db *gorm.DB, material *models.Material, nuevo models.EstadoMaterial, usuarioID, motivo string) error {
+	anterior := material.Estado
+	if err := anterior.ValidarTransicion(nuevo); err != nil {
+		return err
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Material{}).Where("id = ? AND estado = ?", material.ID, anterior).Update("estado", nuevo)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			var actual models.Material
+			if tx.Select("estado").First(&actual, "id = ?", material.ID).Error == nil {
+				material.Estado = actual.Estado
+			}
+			return errEstadoCambiado
+		}
+		// Al aprobar, la revisión vigente queda marcada como la última versión aprobada
+		if nuevo == models.EstadoAprobado {
+			if err := marcarRevisionAprobada(tx, material.ID, usuarioID); err != nil {
+				return err
+			}
+		}
+		return tx.Create(&models.HistorialEstado{
+			MaterialID:     material.ID,
+			EstadoAnterior: anterior,
+			EstadoNuevo:    nuevo,
+			UsuarioID:      usuarioID,
+			Motivo:         motivo,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	material.Estado = nuevo
+	return nil
+}
+
+// responderErrorEstado traduce el error de cambiarEstado a la respuesta HTTP adecuada
+func responderErrorEstado(c *gin.Context, material models.Material, err error) {
+	if errors.Is(err, models.ErrTransicionInvalida) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":                "No se puede cambiar el estado del material",
+			"detail":               err.Error(),
+			"estado_actual":        material.Estado,
+			"transiciones_validas": material.Estado.TransicionesPermitidas(),
+		})
+		return
+	}
+	if errors.Is(err, errEstadoCambiado) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":         "El estado del material cambió mientras se procesaba la petición, vuelve a intentarlo",
+			"estado_actual": material.Estado,
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Error cambiando estado: " + err.Error()})
+}
+
+// rolEnMaterial devuelve el permiso del usuario sobre el material: propietario si es su creador (o admin),
+// el rol de colaborador si aceptó una invitación, o "" si no tiene ninguno
+func rolEnMaterial(db *gorm.DB, material models.Material, googleID string, isAdmin bool) models.RolColaborador {
+	if isAdmin || material.CreadorID == googleID {
+		return models.RolPropietario
+	}
+	var colab models.ColaboradorMaterial
+	if err := db.Where("material_id = ? AND usuario_id = ?", material.ID, googleID).First(&colab).Error; err != nil {
+		return ""
+	}
+	return colab.Rol
+}
+
+// cargarMaterialConRol busca el material y verifica que el usuario tenga al menos el rol pedido sobre él.
+// Si algo falla ya respondió y devuelve ok = false
+func cargarMaterialConRol(c *gin.Context, db *gorm.DB, minimo models.RolColaborador) (models.Material, string, bool) {
+	var material models.Material
+
+	googleID, exists := middleware.GetUserGoogleID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Datos de usuario incompletos"})
+		return material, "", false
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return material, "", false
+	}
+
+	if err := db.First(&material, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material no encontrado"})
+		return material, "", false
+	}
+
+	rol := rolEnMaterial(db, material, googleID, middleware.IsAdmin(c))
+	if !rol.Incluye(minimo) {
+		detalle := "Solo el propietario del material puede hacer esto"
+		switch minimo {
+		case models.RolEditor:
+			detalle = "Solo el propietario y los editores del material pueden hacer esto"
+		case models.RolLector:
+			detalle = "No colaboras en este material"
+		}
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "No tienes permiso",
+			"detail": detalle,
+			"rol":    rol,
+		})
+		return material, "", false
+	}
+
+	return material, googleID, true
+}
+
+// cargarMaterialPropio busca el material y verifica que el usuario sea su propietario o admin
+func cargarMaterialPropio(c *gin.Context, db *gorm.DB) (models.Material, string, bool) {
+	return cargarMaterialConRol(c, db, models.RolPropietario)
+}
+
+// SubmitMaterial envía un borrador (o un material rechazado ya corregido) a revisión
+func SubmitMaterial(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	material, googleID, ok := cargarMaterialConRol(c, db, models.RolEditor)
+	if !ok {
+		return
+	}
+
+	if err := cambiarEstado(db, &material, models.EstadoPendiente, googleID, ""); err != nil {
+		responderErrorEstado(c, material, err)
+		return
+	}
+
+	notificarAdmins(material.ID, material.Nombre, material.CreadorID)
+	log.Printf("📨 Material enviado a revisión: %s (%s) por %s", material.Nombre, material.ID, googleID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Material enviado a revisión",
+		"material": material,
+	})
+}
+
+// WithdrawMaterial retira un material de la cola de revisión y lo devuelve a borrador
+func WithdrawMaterial(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	material, googleID, ok := cargarMaterialConRol(c, db, models.RolEditor)
+	if !ok {
+		return
+	}
+
+	if material.Estado != models.EstadoPendiente {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":         "Solo se pueden retirar materiales pendientes de revisión",
+			"estado_actual": material.Estado,
+		})
+		return
+	}
+
+	if err := cambiarEstado(db, &material, models.EstadoBorrador, googleID, ""); err != nil {
+		responderErrorEstado(c, material, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Material retirado de revisión",
+		"material": material,
+	})
+}
+
+// ArchiveMaterial archiva un material (deja de ser público si estaba aprobado)
+func ArchiveMaterial(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	material, googleID, ok := cargarMaterialPropio(c, db)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Razon string `json:"razon"`
+	}
+	c.ShouldBindJSON(&req)
+
+	if err := cambiarEstado(db, &material, models.EstadoArchivado, googleID, req.Razon); err != nil {
+		responderErrorEstado(c, material, err)
+		return
+	}
+
+	log.Printf("📦 Material archivado: %s (%s) por %s", material.Nombre, material.ID, googleID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Material archivado",
+		"material": material,
+	})
+}
+
+// UnarchiveMaterial devuelve un material archivado a borrador
+func UnarchiveMaterial(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	material, googleID, ok := cargarMaterialPropio(c, db)
+	if !ok {
+		return
+	}
+
+	if material.Estado != models.EstadoArchivado {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":         "El material no está archivado",
+			"estado_actual": material.Estado,
+		})
+		return
+	}
+
+	if err := cambiarEstado(db, &material, models.EstadoBorrador, googleID, ""); err != nil {
+		responderErrorEstado(c, material, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Material desarchivado, quedó como borrador",
+		"material": material,
+	})
+}
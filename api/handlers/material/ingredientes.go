@@ -0,0 +1,386 @@
+package material
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"TT-SEM-2-BACK/api/database"
+	"TT-SEM-2-BACK/api/middleware"
+	"TT-SEM-2-BACK/api/models"
+	"TT-SEM-2-BACK/api/units"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// magnitudesIngrediente son las magnitudes en las que se puede poner precio a un ingrediente
+var magnitudesIngrediente = map[units.Magnitud]bool{units.Masa: true, units.Volumen: true, units.Conteo: true}
+
+// IngredienteRequest es el cuerpo para crear o editar un ingrediente
+type IngredienteRequest struct {
+	Nombre         string   `json:"nombre"`
+	Proveedor      string   `json:"proveedor"`
+	PrecioUnitario *float64 `json:"precio_unitario"`
+	Moneda         string   `json:"moneda"`
+	Unidad         string   `json:"unidad"`
+	Enlace         string   `json:"enlace"`
+	Notas          string   `json:"notas"`
+}
+
+// aplicar valida el request y lo copia al ingrediente
+func (req IngredienteRequest) aplicar(ing *models.Ingrediente) error {
+	nombre := strings.TrimSpace(req.Nombre)
+	if nombre == "" {
+		return errors.New("El nombre es requerido")
+	}
+	unidad, ok := units.Buscar(req.Unidad)
+	if !ok || !magnitudesIngrediente[unidad.Magnitud] {
+		return fmt.Errorf("Unidad '%s' inválida. Usa una unidad de masa, volumen o conteo (kg, L, u...)", req.Unidad)
+	}
+	if req.PrecioUnitario != nil && (*req.PrecioUnitario < 0 || math.IsInf(*req.PrecioUnitario, 0)) {
+		return errors.New("El precio unitario no puede ser negativo")
+	}
+
+	ing.Nombre = nombre
+	ing.Proveedor = strings.TrimSpace(req.Proveedor)
+	ing.PrecioUnitario = req.PrecioUnitario
+	ing.Moneda = strings.ToUpper(strings.TrimSpace(req.Moneda))
+	if ing.Moneda == "" {
+		ing.Moneda = "MXN"
+	}
+	ing.Unidad = unidad.Simbolo
+	ing.Enlace = strings.TrimSpace(req.Enlace)
+	ing.Notas = strings.TrimSpace(req.Notas)
+	return nil
+}
+
+// validarIngredientes comprueba que los ingrediente_id de la composición existan
+func validarIngredientes(db *gorm.DB, comp models.JSONComponentes) []ErrorCampo {
+	ids := []uuid.UUID{}
+	for _, co := range comp {
+		if co.IngredienteID != nil {
+			ids = append(ids, *co.IngredienteID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var existentes []uuid.UUID
+	if err := db.Model(&models.Ingrediente{}).Where("id IN ?", ids).Pluck("id", &existentes).Error; err != nil {
+		return []ErrorCampo{{Campo: "composicion", Error: "Error verificando ingredientes"}}
+	}
+	encontrados := make(map[uuid.UUID]bool, len(existentes))
+	for _, id := range existentes {
+		encontrados[id] = true
+	}
+
+	var errores []ErrorCampo
+	for _, co := range comp {
+		if co.IngredienteID != nil && !encontrados[*co.IngredienteID] {
+			errores = append(errores, ErrorCampo{
+				Campo: "composicion",
+				Error: fmt.Sprintf("El ingrediente de '%s' no existe", co.Elemento),
+			})
+		}
+	}
+	return errores
+}
+
+// GetIngredientes lista el catálogo de ingredientes. ?q= busca por nombre o proveedor
+func GetIngredientes(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	query := db.Order("nombre ASC, proveedor ASC")
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := "%" + q + "%"
+		query = query.Where("unaccent(nombre) ILIKE unaccent(?) OR unaccent(proveedor) ILIKE unaccent(?)", like, like)
+	}
+
+	var ingredientes []models.Ingrediente
+	if err := query.Find(&ingredientes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error obteniendo ingredientes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ingredientes)
+}
+
+// CreateIngrediente agrega un ingrediente al catálogo
+func CreateIngrediente(c *gin.Context) {
+	googleID, _ := middleware.GetUserGoogleID(c)
+
+	var req IngredienteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos: " + err.Error()})
+		return
+	}
+
+	ingrediente := models.Ingrediente{CreadoPorID: googleID}
+	if err := req.aplicar(&ingrediente); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	if err := db.Create(&ingrediente).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando ingrediente: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, ingrediente)
+}
+
+// UpdateIngrediente edita un ingrediente (por ejemplo, para actualizar su precio)
+func UpdateIngrediente(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req IngredienteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos: " + err.Error()})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	var ingrediente models.Ingrediente
+	if err := db.First(&ingrediente, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ingrediente no encontrado"})
+		return
+	}
+
+	if err := req.aplicar(&ingrediente); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := db.Save(&ingrediente).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando ingrediente: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ingrediente)
+}
+
+// DeleteIngrediente elimina un ingrediente que ningún material tenga vinculado - Solo Admin
+func DeleteIngrediente(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	var usos int64
+	if err := db.Model(&models.Material{}).Where(`EXISTS (
+		SELECT 1 FROM jsonb_array_elements(`+arregloJSON("composicion")+`) AS e
+		WHERE e->>'ingrediente_id' = ?)`, id.String()).Count(&usos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error verificando uso del ingrediente"})
+		return
+	}
+	if usos > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("El ingrediente está vinculado a %d material(es), desvincúlalo antes de eliminarlo", usos)})
+		return
+	}
+
+	res := db.Delete(&models.Ingrediente{}, "id = ?", id)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error eliminando ingrediente"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ingrediente no encontrado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ingrediente eliminado"})
+}
+
+// CostoComponente es una línea del desglose de costo
+type CostoComponente struct {
+	Elemento       string              `json:"elemento"`
+	Cantidad       string              `json:"cantidad,omitempty"` // Cantidad del lote
+	Ingrediente    *models.Ingrediente `json:"ingrediente,omitempty"`
+	Vinculo        string              `json:"vinculo,omitempty"` // "id" o "nombre"
+	PrecioUnitario *float64            `json:"precio_unitario,omitempty"`
+	UnidadPrecio   string              `json:"unidad_precio,omitempty"`
+	Moneda         string              `json:"moneda,omitempty"`
+	Costo          *float64            `json:"costo"` // nil si no se pudo calcular
+	Advertencia    string              `json:"advertencia,omitempty"`
+}
+
+// buscarIngrediente devuelve el ingrediente vinculado al componente. Sin ingrediente_id se busca por
+// nombre y, si hay varios (distintos proveedores), se prefiere uno con precio y el más reciente
+func buscarIngrediente(co models.Componente, porID map[uuid.UUID]*models.Ingrediente, porNombre map[string][]*models.Ingrediente) (*models.Ingrediente, string) {
+	if co.IngredienteID != nil {
+		return porID[*co.IngredienteID], "id"
+	}
+	var elegido *models.Ingrediente
+	for _, ing := range porNombre[claveProp(co.Elemento)] {
+		switch {
+		case elegido == nil:
+			elegido = ing
+		case (ing.PrecioUnitario != nil) != (elegido.PrecioUnitario != nil):
+			if ing.PrecioUnitario != nil {
+				elegido = ing
+			}
+		case ing.UpdatedAt.After(elegido.UpdatedAt):
+			elegido = ing
+		}
+	}
+	return elegido, "nombre"
+}
+
+// GetMaterialCost estima el costo de un lote a partir de las cantidades estructuradas de la composición
+// y los precios del catálogo de ingredientes. Acepta ?batch= y ?factor= igual que /scaled; sin ellos
+// calcula la receta tal como está escrita. Devuelve el desglose, el total por moneda y avisa de los
+// componentes que no se pudieron costear
+func GetMaterialCost(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	lote, factor, err := leerLote(c, false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	// 1. Material: los no publicados solo los ven sus colaboradores o un admin
+	var material models.Material
+	if err := db.First(&material, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material no encontrado"})
+		return
+	}
+	googleID, _ := middleware.GetUserGoogleID(c)
+	if material.Estado != models.EstadoAprobado && rolEnMaterial(db, material, googleID, middleware.IsAdmin(c)) == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material no encontrado"})
+		return
+	}
+
+	// 2. Escalar la composición al lote pedido
+	escalada, err := escalarComposicion(material.Composicion, lote, factor)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 3. Ingredientes vinculados por id o por nombre
+	ids := []uuid.UUID{}
+	nombres := []string{}
+	for _, co := range material.Composicion {
+		if co.IngredienteID != nil {
+			ids = append(ids, *co.IngredienteID)
+		} else if k := claveProp(co.Elemento); k != "" {
+			nombres = append(nombres, k)
+		}
+	}
+	var ingredientes []models.Ingrediente
+	if len(ids) > 0 || len(nombres) > 0 {
+		if err := db.Where("id IN ? OR unaccent(lower(btrim(nombre))) IN ?", append(ids, uuid.Nil), append(nombres, "")).
+			Find(&ingredientes).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error obteniendo ingredientes"})
+			return
+		}
+	}
+	porID := make(map[uuid.UUID]*models.Ingrediente)
+	porNombre := make(map[string][]*models.Ingrediente)
+	for i := range ingredientes {
+		porID[ingredientes[i].ID] = &ingredientes[i]
+		k := claveProp(ingredientes[i].Nombre)
+		porNombre[k] = append(porNombre[k], &ingredientes[i])
+	}
+
+	// 4. Desglose
+	desglose := make([]CostoComponente, len(material.Composicion))
+	totales := make(map[string]float64)
+	advertencias := []string{}
+	completo := true
+	for i, co := range material.Composicion {
+		e := escalada.Composicion[i]
+		linea := CostoComponente{Elemento: co.Elemento, Cantidad: e.Cantidad}
+
+		ing, vinculo := buscarIngrediente(co, porID, porNombre)
+		if ing != nil {
+			linea.Ingrediente, linea.Vinculo = ing, vinculo
+			linea.PrecioUnitario, linea.UnidadPrecio, linea.Moneda = ing.PrecioUnitario, ing.Unidad, ing.Moneda
+		}
+		unidadPrecio, _ := units.Buscar(linea.UnidadPrecio)
+
+		switch {
+		case !e.Escalable:
+			linea.Advertencia = e.Motivo
+		case ing == nil && co.IngredienteID != nil:
+			linea.Advertencia = "El ingrediente vinculado ya no existe"
+		case ing == nil:
+			linea.Advertencia = "Sin ingrediente en el catálogo"
+		case ing.PrecioUnitario == nil:
+			linea.Advertencia = "El ingrediente no tiene precio"
+		case unidadPrecio.Magnitud != e.magnitud:
+			linea.Advertencia = fmt.Sprintf("Unidades incompatibles: la receta usa %s y el precio es por %s", e.magnitud, unidadPrecio.Magnitud)
+		default:
+			costo := math.Round(unidadPrecio.DesdeBase(e.base)**ing.PrecioUnitario*100) / 100
+			linea.Costo = &costo
+			totales[ing.Moneda] += costo
+		}
+
+		if linea.Costo == nil {
+			completo = false
+			advertencias = append(advertencias, fmt.Sprintf("%s: %s", co.Elemento, linea.Advertencia))
+		}
+		desglose[i] = linea
+	}
+	for moneda, total := range totales {
+		totales[moneda] = math.Round(total*100) / 100
+	}
+
+	respuesta := gin.H{
+		"material_id":  material.ID,
+		"nombre":       material.Nombre,
+		"tipo":         escalada.Tipo,
+		"factor":       escalada.Factor,
+		"desglose":     desglose,
+		"totales":      totales,
+		"completo":     completo, // false si algún componente quedó fuera del total
+		"advertencias": advertencias,
+	}
+	if lote != nil {
+		valor := redondearCantidad(lote.Original.DesdeBase(lote.Valor))
+		respuesta["batch"] = gin.H{"valor": valor, "unidad": lote.Original.Simbolo}
+	}
+
+	c.JSON(http.StatusOK, respuesta)
+}
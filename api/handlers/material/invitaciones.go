@@ -0,0 +1,735 @@
+package material
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"TT-SEM-2-BACK/api/database"
+	"TT-SEM-2-BACK/api/middleware"
+	"TT-SEM-2-BACK/api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InvitacionRequest es el cuerpo de POST /materials/:id/collaborators. Sin rol se invita como editor
+type InvitacionRequest struct {
+	Email string                `json:"email"`
+	Rol   models.RolColaborador `json:"rol"`
+}
+
+// TransferenciaRequest es el cuerpo de POST /materials/:id/transfer. RolAnterior es el rol con el que
+// se queda el propietario actual: editor (por defecto), lector o "ninguno"
+type TransferenciaRequest struct {
+	UsuarioID   string `json:"usuario_id"`
+	RolAnterior string `json:"rol_anterior"`
+}
+
+// RolColaboradorRequest es el cuerpo de PUT /materials/:id/collaborators/:invitacionId
+type RolColaboradorRequest struct {
+	Rol models.RolColaborador `json:"rol"`
+}
+
+// colaboradorPedido es un colaborador del campo "colaboradores" del form. Rol vacío si no se indicó
+type colaboradorPedido struct {
+	Email string                `json:"email"`
+	Rol   models.RolColaborador `json:"rol"`
+}
+
+// errInvitacionResuelta indica que la invitación ya no está pendiente (otra petición la resolvió)
+var errInvitacionResuelta = errors.New("la invitación ya fue respondida")
+
+// normalizarEmail valida el email y lo devuelve en minúsculas
+func normalizarEmail(email string) (string, bool) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", false
+	}
+	return email, true
+}
+
+// leerColaboradores lee el JSON de colaboradores del form: emails sueltos o {"email", "rol"}. Los emails se
+// devuelven en minúsculas y sin repetir; no hace falta que tengan cuenta, los desconocidos quedan invitados
+// hasta que se registren
+func leerColaboradores(campo, colaboradoresStr string) ([]colaboradorPedido, *ErrorCampo) {
+	var crudos []json.RawMessage
+	if err := json.Unmarshal([]byte(colaboradoresStr), &crudos); err != nil {
+		return nil, &ErrorCampo{Campo: campo, Error: "Formato de colaboradores inválido"}
+	}
+
+	pedidos := []colaboradorPedido{}
+	vistos := map[string]bool{}
+	invalidos := []string{}
+	for _, crudo := range crudos {
+		var p colaboradorPedido
+		if err := json.Unmarshal(crudo, &p.Email); err != nil {
+			if err := json.Unmarshal(crudo, &p); err != nil {
+				return nil, &ErrorCampo{Campo: campo, Error: "Formato de colaboradores inválido"}
+			}
+		}
+		if p.Rol != "" && !p.Rol.Valido() {
+			return nil, &ErrorCampo{Campo: campo, Error: fmt.Sprintf("Rol '%s' inválido para %s, usa 'editor' o 'lector'", p.Rol, p.Email)}
+		}
+		email, ok := normalizarEmail(p.Email)
+		if !ok {
+			invalidos = append(invalidos, p.Email)
+			continue
+		}
+		if !vistos[email] {
+			vistos[email] = true
+			p.Email = email
+			pedidos = append(pedidos, p)
+		}
+	}
+	if len(invalidos) > 0 {
+		return nil, &ErrorCampo{
+			Campo: campo,
+			Error: fmt.Sprintf("Emails inválidos: %s", strings.Join(invalidos, ", ")),
+		}
+	}
+	return pedidos, nil
+}
+
+// ultimasInvitaciones devuelve la invitación más reciente de cada email del material, sin las canceladas.
+// Es el estado de cada colaborador que ve el dueño
+func ultimasInvitaciones(db *gorm.DB, materialID uuid.UUID) ([]models.InvitacionColaborador, error) {
+	invitaciones := []models.InvitacionColaborador{}
+	err := db.Preload("Usuario").
+		Where(`id IN (
+			SELECT DISTINCT ON (email) id FROM invitaciones_colaborador
+			WHERE material_id = ? ORDER BY email, created_at DESC
+		)`, materialID).
+		Where("estado <> ?", models.InvitacionCancelada).
+		Order("email").
+		Find(&invitaciones).Error
+	return invitaciones, err
+}
+
+// crearInvitacion registra la invitación y, si el email ya tiene cuenta, le avisa al invitado
+func crearInvitacion(tx *gorm.DB, material models.Material, invitadorID, email string, rol models.RolColaborador, usuario *models.Usuario) (models.InvitacionColaborador, error) {
+	if rol == "" {
+		rol = models.RolEditor
+	}
+	inv := models.InvitacionColaborador{
+		MaterialID:    material.ID,
+		Email:         email,
+		InvitadoPorID: invitadorID,
+		Rol:           rol,
+		Estado:        models.InvitacionPendiente,
+	}
+	if usuario != nil {
+		inv.UsuarioID = &usuario.GoogleID
+	}
+	if err := tx.Create(&inv).Error; err != nil {
+		return inv, fmt.Errorf("error invitando a %s: %w", email, err)
+	}
+	if usuario == nil {
+		return inv, nil
+	}
+
+	var invitador models.Usuario
+	tx.Where("google_id = ?", invitadorID).First(&invitador)
+	matID := material.ID
+	err := tx.Create(&models.Notificacion{
+		UsuarioID:  usuario.GoogleID,
+		MaterialID: &matID,
+		Titulo:     "Invitación a colaborar",
+		Mensaje:    fmt.Sprintf("%s te invitó a colaborar como %s en el material '%s'.", invitador.Nombre, rol, material.Nombre),
+		Tipo:       "invitacion_colaborador",
+		Link:       "/invitations",
+	}).Error
+	return inv, err
+}
+
+// sincronizarInvitaciones hace que los colaboradores del material sean los de la lista:
+// invita a los nuevos, deja como están los pendientes, aceptados y rechazados (un rechazo solo se
+// vuelve a invitar explícitamente), cambia el rol a quien lo trae indicado y quita a los que ya no aparecen
+func sincronizarInvitaciones(tx *gorm.DB, material models.Material, invitadorID string, pedidos []colaboradorPedido) error {
+	emails := make([]string, len(pedidos))
+	for i, p := range pedidos {
+		emails[i] = p.Email
+	}
+
+	// 1. Usuarios con cuenta entre los emails
+	usuarios := []models.Usuario{}
+	if len(emails) > 0 {
+		if err := tx.Where("lower(email) IN ?", emails).Find(&usuarios).Error; err != nil {
+			return fmt.Errorf("error buscando colaboradores: %w", err)
+		}
+	}
+	porEmail := make(map[string]*models.Usuario, len(usuarios))
+	idsConservados := []string{}
+	for i := range usuarios {
+		porEmail[strings.ToLower(usuarios[i].Email)] = &usuarios[i]
+		idsConservados = append(idsConservados, usuarios[i].GoogleID)
+	}
+
+	// 2. Quitar a los que ya no están: se cancelan sus invitaciones y se borra la colaboración
+	cancelar := tx.Model(&models.InvitacionColaborador{}).
+		Where("material_id = ? AND estado IN ?", material.ID,
+			[]models.EstadoInvitacion{models.InvitacionPendiente, models.InvitacionAceptada})
+	quitar := tx.Table("material_colaboradores").Where("material_id = ?", material.ID)
+	if len(emails) > 0 {
+		cancelar = cancelar.Where("email NOT IN ?", emails)
+	}
+	if len(idsConservados) > 0 {
+		quitar = quitar.Where("usuario_id NOT IN ?", idsConservados)
+	}
+	if err := cancelar.Updates(map[string]interface{}{"estado": models.InvitacionCancelada, "respondida_en": time.Now()}).Error; err != nil {
+		return fmt.Errorf("error cancelando invitaciones: %w", err)
+	}
+	if err := quitar.Delete(nil).Error; err != nil {
+		return fmt.Errorf("error limpiando colaboradores: %w", err)
+	}
+
+	// 3. Invitar a los emails que no tienen una invitación vigente y actualizar el rol de los que sí
+	actuales, err := ultimasInvitaciones(tx, material.ID)
+	if err != nil {
+		return fmt.Errorf("error leyendo invitaciones: %w", err)
+	}
+	vigentes := make(map[string]models.InvitacionColaborador, len(actuales))
+	for _, inv := range actuales {
+		vigentes[inv.Email] = inv
+	}
+	for _, p := range pedidos {
+		usuario := porEmail[p.Email]
+		if usuario != nil && usuario.GoogleID == material.CreadorID {
+			continue
+		}
+		inv, existe := vigentes[p.Email]
+		if !existe {
+			if _, err := crearInvitacion(tx, material, invitadorID, p.Email, p.Rol, usuario); err != nil {
+				return err
+			}
+			continue
+		}
+		if p.Rol != "" && p.Rol != inv.Rol && inv.Estado != models.InvitacionRechazada {
+			if err := cambiarRolColaborador(tx, inv, p.Rol); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// cambiarRolColaborador cambia el rol de la invitación y, si ya fue aceptada, el de la colaboración
+func cambiarRolColaborador(tx *gorm.DB, inv models.InvitacionColaborador, rol models.RolColaborador) error {
+	if err := tx.Model(&models.InvitacionColaborador{}).Where("id = ?", inv.ID).Update("rol", rol).Error; err != nil {
+		return fmt.Errorf("error cambiando rol de %s: %w", inv.Email, err)
+	}
+	if inv.Estado != models.InvitacionAceptada || inv.UsuarioID == nil {
+		return nil
+	}
+	if err := tx.Model(&models.ColaboradorMaterial{}).
+		Where("material_id = ? AND usuario_id = ?", inv.MaterialID, *inv.UsuarioID).
+		Update("rol", rol).Error; err != nil {
+		return fmt.Errorf("error cambiando rol de %s: %w", inv.Email, err)
+	}
+	return nil
+}
+
+// GetColaboradores devuelve el estado de la invitación y el rol de cada colaborador - Cualquier colaborador o Admin
+func GetColaboradores(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	material, _, ok := cargarMaterialConRol(c, db, models.RolLector)
+	if !ok {
+		return
+	}
+
+	invitaciones, err := ultimasInvitaciones(db, material.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error obteniendo colaboradores: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitaciones)
+}
+
+// InviteColaborador invita a un email a colaborar. Sirve también para volver a invitar a quien rechazó
+func InviteColaborador(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	material, googleID, ok := cargarMaterialPropio(c, db)
+	if !ok {
+		return
+	}
+
+	var req InvitacionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request inválido: " + err.Error()})
+		return
+	}
+	email, valido := normalizarEmail(req.Email)
+	if !valido {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email inválido"})
+		return
+	}
+	if req.Rol != "" && !req.Rol.Valido() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rol inválido", "roles_validos": []models.RolColaborador{models.RolEditor, models.RolLector}})
+		return
+	}
+
+	// 1. El invitado puede no tener cuenta todavía
+	var usuario *models.Usuario
+	var encontrado models.Usuario
+	if err := db.Where("lower(email) = ?", email).First(&encontrado).Error; err == nil {
+		usuario = &encontrado
+	}
+	if usuario != nil && usuario.GoogleID == material.CreadorID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El creador del material no necesita invitación"})
+		return
+	}
+
+	// 2. No repetir una invitación pendiente ni invitar a quien ya colabora
+	var vigente models.InvitacionColaborador
+	if err := db.Where("material_id = ? AND email = ? AND estado IN ?", material.ID, email,
+		[]models.EstadoInvitacion{models.InvitacionPendiente, models.InvitacionAceptada}).
+		First(&vigente).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":      "Ese email ya tiene una invitación " + string(vigente.Estado),
+			"invitacion": vigente,
+		})
+		return
+	}
+
+	var inv models.InvitacionColaborador
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		inv, err = crearInvitacion(tx, material, googleID, email, req.Rol, usuario)
+		return err
+	}); err != nil {
+		if strings.Contains(err.Error(), "idx_invitaciones_colaborador_pendiente") {
+			c.JSON(http.StatusConflict, gin.H{"error": "Ese email ya tiene una invitación pendiente"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creando invitación: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, inv)
+}
+
+// RemoveColaborador cancela una invitación pendiente o quita a un colaborador que ya la aceptó - Dueño o Admin
+func RemoveColaborador(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	material, _, ok := cargarMaterialPropio(c, db)
+	if !ok {
+		return
+	}
+
+	invID, err := uuid.Parse(c.Param("invitacionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de invitación inválido"})
+		return
+	}
+
+	var inv models.InvitacionColaborador
+	if err := db.First(&inv, "id = ? AND material_id = ?", invID, material.ID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitación no encontrada"})
+		return
+	}
+	if inv.Estado != models.InvitacionPendiente && inv.Estado != models.InvitacionAceptada {
+		c.JSON(http.StatusConflict, gin.H{"error": "La invitación ya está " + string(inv.Estado)})
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&inv).Updates(map[string]interface{}{
+			"estado":        models.InvitacionCancelada,
+			"respondida_en": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		if inv.UsuarioID == nil {
+			return nil
+		}
+		return tx.Table("material_colaboradores").
+			Where("material_id = ? AND usuario_id = ?", material.ID, *inv.UsuarioID).
+			Delete(nil).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error quitando colaborador: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Colaborador quitado", "invitacion": inv})
+}
+
+// errPropietarioCambiado indica que otra petición transfirió el material antes
+var errPropietarioCambiado = errors.New("el propietario del material cambió")
+
+// TransferMaterial transfiere la propiedad del material a uno de sus colaboradores - Propietario o Admin.
+// El nuevo propietario deja de figurar como colaborador y el anterior queda con el rol indicado
+func TransferMaterial(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	material, googleID, ok := cargarMaterialPropio(c, db)
+	if !ok {
+		return
+	}
+
+	var req TransferenciaRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.UsuarioID) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Indica el 'usuario_id' del nuevo propietario"})
+		return
+	}
+	rolAnterior := models.RolColaborador(req.RolAnterior)
+	switch {
+	case req.RolAnterior == "":
+		rolAnterior = models.RolEditor
+	case req.RolAnterior == "ninguno":
+		rolAnterior = ""
+	case !rolAnterior.Valido():
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rol anterior inválido", "roles_validos": []string{"editor", "lector", "ninguno"}})
+		return
+	}
+
+	// 1. Solo se transfiere a quien ya colabora (aceptó su invitación)
+	if req.UsuarioID == material.CreadorID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ese usuario ya es el propietario"})
+		return
+	}
+	var nuevo models.Usuario
+	if err := db.Where("google_id = ?", req.UsuarioID).First(&nuevo).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+		return
+	}
+	var colab models.ColaboradorMaterial
+	if err := db.Where("material_id = ? AND usuario_id = ?", material.ID, nuevo.GoogleID).First(&colab).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Solo se puede transferir el material a uno de sus colaboradores"})
+		return
+	}
+	var anterior models.Usuario
+	db.Where("google_id = ?", material.CreadorID).First(&anterior)
+
+	ahora := time.Now()
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		// La condición sobre el creador evita dos transferencias simultáneas
+		res := tx.Model(&models.Material{}).
+			Where("id = ? AND creador_id = ?", material.ID, material.CreadorID).
+			Update("creador_id", nuevo.GoogleID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errPropietarioCambiado
+		}
+
+		// 2. El nuevo propietario deja de ser colaborador
+		if err := tx.Table("material_colaboradores").
+			Where("material_id = ? AND usuario_id = ?", material.ID, nuevo.GoogleID).Delete(nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.InvitacionColaborador{}).
+			Where("material_id = ? AND usuario_id = ? AND estado IN ?", material.ID, nuevo.GoogleID,
+				[]models.EstadoInvitacion{models.InvitacionPendiente, models.InvitacionAceptada}).
+			Updates(map[string]interface{}{"estado": models.InvitacionCancelada, "respondida_en": ahora}).Error; err != nil {
+			return err
+		}
+
+		// 3. El anterior se queda como colaborador, con su invitación ya aceptada para que figure en la lista
+		if rolAnterior != "" && anterior.GoogleID != "" {
+			if err := asociarColaborador(tx, material.ID, anterior.GoogleID, rolAnterior); err != nil {
+				return err
+			}
+			if err := tx.Create(&models.InvitacionColaborador{
+				MaterialID:    material.ID,
+				Email:         strings.ToLower(anterior.Email),
+				UsuarioID:     &anterior.GoogleID,
+				InvitadoPorID: nuevo.GoogleID,
+				Rol:           rolAnterior,
+				Estado:        models.InvitacionAceptada,
+				RespondidaEn:  &ahora,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		matID := material.ID
+		if err := tx.Create(&models.Notificacion{
+			UsuarioID:  nuevo.GoogleID,
+			MaterialID: &matID,
+			Titulo:     "Ahora eres propietario",
+			Mensaje:    fmt.Sprintf("%s te transfirió el material '%s'.", anterior.Nombre, material.Nombre),
+			Tipo:       "transferencia_material",
+			Link:       "/materials/" + matID.String(),
+		}).Error; err != nil {
+			return err
+		}
+		// Si lo transfirió un admin, el propietario anterior también se entera
+		if googleID != material.CreadorID {
+			return tx.Create(&models.Notificacion{
+				UsuarioID:  material.CreadorID,
+				MaterialID: &matID,
+				Titulo:     "Material transferido",
+				Mensaje:    fmt.Sprintf("Un administrador transfirió tu material '%s' a %s.", material.Nombre, nuevo.Nombre),
+				Tipo:       "transferencia_material",
+				Link:       "/materials/" + matID.String(),
+			}).Error
+		}
+		return nil
+	}); err != nil {
+		if errors.Is(err, errPropietarioCambiado) {
+			c.JSON(http.StatusConflict, gin.H{"error": "El material ya fue transferido por otra petición"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error transfiriendo el material: " + err.Error()})
+		return
+	}
+
+	db.Preload("Creador").Preload("Colaboradores").First(&material, "id = ?", material.ID)
+	invitaciones, _ := ultimasInvitaciones(db, material.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Material transferido a " + nuevo.Nombre,
+		"material":     material,
+		"invitaciones": invitaciones,
+	})
+}
+
+// UpdateColaboradorRol cambia el rol de un colaborador o de una invitación pendiente - Propietario o Admin
+func UpdateColaboradorRol(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	material, _, ok := cargarMaterialPropio(c, db)
+	if !ok {
+		return
+	}
+
+	invID, err := uuid.Parse(c.Param("invitacionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de invitación inválido"})
+		return
+	}
+
+	var req RolColaboradorRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !req.Rol.Valido() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rol inválido", "roles_validos": []models.RolColaborador{models.RolEditor, models.RolLector}})
+		return
+	}
+
+	var inv models.InvitacionColaborador
+	if err := db.First(&inv, "id = ? AND material_id = ?", invID, material.ID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitación no encontrada"})
+		return
+	}
+	if inv.Estado != models.InvitacionPendiente && inv.Estado != models.InvitacionAceptada {
+		c.JSON(http.StatusConflict, gin.H{"error": "La invitación ya está " + string(inv.Estado)})
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return cambiarRolColaborador(tx, inv, req.Rol)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	inv.Rol = req.Rol
+
+	c.JSON(http.StatusOK, gin.H{"message": "Rol actualizado", "invitacion": inv})
+}
+
+// asociarColaborador agrega al usuario como colaborador con el rol indicado. Si antes colaboraba y se le
+// quitó con borrado lógico, la fila se reactiva
+func asociarColaborador(tx *gorm.DB, materialID uuid.UUID, usuarioID string, rol models.RolColaborador) error {
+	link := models.ColaboradorMaterial{MaterialID: materialID, UsuarioID: usuarioID, Rol: rol}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "material_id"}, {Name: "usuario_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"deleted_at": nil, "rol": rol}),
+	}).Create(&link).Error; err != nil {
+		return fmt.Errorf("error asociando colaborador: %w", err)
+	}
+	return nil
+}
+
+// deUsuario filtra las invitaciones dirigidas al usuario: las suyas y las de su email que quedaron
+// sin vincular (por ejemplo si cambió de email después de registrarse)
+func deUsuario(db *gorm.DB, usuario models.Usuario) *gorm.DB {
+	return db.Where("usuario_id = ? OR (usuario_id IS NULL AND email = ?)",
+		usuario.GoogleID, strings.ToLower(usuario.Email))
+}
+
+// GetMisInvitaciones lista las invitaciones del usuario. ?estado=pendiente (por defecto), aceptada, rechazada o todas
+func GetMisInvitaciones(c *gin.Context) {
+	googleID, exists := middleware.GetUserGoogleID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	var usuario models.Usuario
+	if err := db.Where("google_id = ?", googleID).First(&usuario).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+		return
+	}
+
+	query := deUsuario(db, usuario).
+		Preload("Material", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "nombre", "estado", "creador_id")
+		}).
+		Preload("InvitadoPor").
+		Order("created_at DESC")
+
+	estado := c.DefaultQuery("estado", string(models.InvitacionPendiente))
+	switch models.EstadoInvitacion(estado) {
+	case models.InvitacionPendiente, models.InvitacionAceptada, models.InvitacionRechazada:
+		query = query.Where("estado = ?", estado)
+	default:
+		if estado != "todas" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":           "Estado inválido",
+				"estados_validos": []string{"pendiente", "aceptada", "rechazada", "todas"},
+			})
+			return
+		}
+		// Las canceladas no le interesan al invitado
+		query = query.Where("estado <> ?", models.InvitacionCancelada)
+	}
+
+	invitaciones := []models.InvitacionColaborador{}
+	if err := query.Find(&invitaciones).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error obteniendo invitaciones"})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitaciones)
+}
+
+// AcceptInvitacion acepta la invitación: el usuario pasa a ser colaborador del material
+func AcceptInvitacion(c *gin.Context) {
+	responderInvitacion(c, models.InvitacionAceptada)
+}
+
+// DeclineInvitacion rechaza la invitación
+func DeclineInvitacion(c *gin.Context) {
+	responderInvitacion(c, models.InvitacionRechazada)
+}
+
+// responderInvitacion acepta o rechaza una invitación pendiente y le avisa a quien invitó, en una sola transacción
+func responderInvitacion(c *gin.Context, estado models.EstadoInvitacion) {
+	googleID, exists := middleware.GetUserGoogleID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	var usuario models.Usuario
+	if err := db.Where("google_id = ?", googleID).First(&usuario).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+		return
+	}
+
+	var inv models.InvitacionColaborador
+	if err := deUsuario(db, usuario).Preload("Material").First(&inv, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitación no encontrada"})
+		return
+	}
+	if inv.Estado != models.InvitacionPendiente {
+		c.JSON(http.StatusConflict, gin.H{"error": "La invitación ya está " + string(inv.Estado)})
+		return
+	}
+	if inv.Material == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "El material de la invitación ya no existe"})
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		// La condición sobre el estado evita responder dos veces (o responder una invitación cancelada)
+		res := tx.Model(&models.InvitacionColaborador{}).
+			Where("id = ? AND estado = ?", inv.ID, models.InvitacionPendiente).
+			Updates(map[string]interface{}{
+				"estado":        estado,
+				"usuario_id":    googleID,
+				"respondida_en": time.Now(),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errInvitacionResuelta
+		}
+
+		if estado == models.InvitacionAceptada {
+			// Si antes colaboraba y se le quitó con borrado lógico, la fila se reactiva
+			if err := asociarColaborador(tx, inv.MaterialID, googleID, inv.Rol); err != nil {
+				return err
+			}
+		}
+
+		titulo, verbo, tipo := "Invitación aceptada", "aceptó", "invitacion_aceptada"
+		if estado == models.InvitacionRechazada {
+			titulo, verbo, tipo = "Invitación rechazada", "rechazó", "invitacion_rechazada"
+		}
+		matID := inv.MaterialID
+		return tx.Create(&models.Notificacion{
+			UsuarioID:  inv.InvitadoPorID,
+			MaterialID: &matID,
+			Titulo:     titulo,
+			Mensaje:    fmt.Sprintf("%s %s tu invitación a colaborar en '%s'.", usuario.Nombre, verbo, inv.Material.Nombre),
+			Tipo:       tipo,
+			Link:       "/materials/" + inv.MaterialID.String(),
+		}).Error
+	}); err != nil {
+		if errors.Is(err, errInvitacionResuelta) {
+			c.JSON(http.StatusConflict, gin.H{"error": "La invitación ya no está pendiente"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error respondiendo la invitación: " + err.Error()})
+		return
+	}
+
+	db.First(&inv, "id = ?", inv.ID)
+
+	mensaje := "Invitación aceptada. Ya eres colaborador del material"
+	if estado == models.InvitacionRechazada {
+		mensaje = "Invitación rechazada"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": mensaje, "invitacion": inv})
+}
@@ -0,0 +1,255 @@
+package material
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"TT-SEM-2-BACK/api/database"
+	"TT-SEM-2-BACK/api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	profundidadLinaje    = 10
+	maxProfundidadLinaje = 50
+)
+
+// NodoLinaje es un material del linaje. Nivel negativo = ancestro, 0 = el material pedido, positivo = descendiente
+type NodoLinaje struct {
+	ID         uuid.UUID  `json:"id"`
+	Nombre     string     `json:"nombre"`
+	Nivel      int        `json:"nivel"`
+	DerivadoDe *uuid.UUID `json:"derivado_de,omitempty"`
+}
+
+// AristaLinaje une un material con uno derivado de él
+type AristaLinaje struct {
+	Desde uuid.UUID `json:"desde"` // Padre
+	Hacia uuid.UUID `json:"hacia"` // Derivado
+}
+
+// GrafoLinaje es el árbol completo de ancestros y descendientes de un material
+type GrafoLinaje struct {
+	Raiz           uuid.UUID      `json:"raiz"`
+	Profundidad    int            `json:"profundidad"`
+	Nodos          []NodoLinaje   `json:"nodos"`
+	Aristas        []AristaLinaje `json:"aristas"`
+	CicloDetectado bool           `json:"ciclo_detectado"`
+	Truncado       bool           `json:"truncado"` // Hay más generaciones más allá de la profundidad pedida
+}
+
+// filaLinaje es una fila de las consultas recursivas
+type filaLinaje struct {
+	ID         uuid.UUID
+	Nombre     string
+	DerivadoDe *uuid.UUID
+	Nivel      int
+	Ciclo      bool
+}
+
+// consultas recursivas: solo materiales aprobados; camino evita recorrer un ciclo y lo marca
+const (
+	sqlAncestros = `
+		WITH RECURSIVE ancestros AS (
+			SELECT m.id, m.nombre, m.derivado_de, 0 AS nivel, ARRAY[m.id] AS camino, false AS ciclo
+			FROM materials m
+			WHERE m.id = ?
+			UNION ALL
+			SELECT p.id, p.nombre, p.derivado_de, a.nivel + 1, a.camino || p.id, p.id = ANY(a.camino)
+			FROM materials p
+			JOIN ancestros a ON p.id = a.derivado_de
+			WHERE a.nivel < ? AND NOT a.ciclo AND p.estado = 'aprobado' AND p.deleted_at IS NULL
+		)
+		SELECT id, nombre, derivado_de, nivel, ciclo FROM ancestros`
+
+	sqlDescendientes = `
+		WITH RECURSIVE descendientes AS (
+			SELECT m.id, m.nombre, m.derivado_de, 0 AS nivel, ARRAY[m.id] AS camino, false AS ciclo
+			FROM materials m
+			WHERE m.id = ?
+			UNION ALL
+			SELECT h.id, h.nombre, h.derivado_de, d.nivel + 1, d.camino || h.id, h.id = ANY(d.camino)
+			FROM materials h
+			JOIN descendientes d ON h.derivado_de = d.id
+			WHERE d.nivel < ? AND NOT d.ciclo AND h.estado = 'aprobado' AND h.deleted_at IS NULL
+		)
+		SELECT id, nombre, derivado_de, nivel, ciclo FROM descendientes`
+)
+
+// construirLinaje arma el grafo de un material aprobado hasta profundidad generaciones hacia arriba y hacia abajo
+func construirLinaje(db *gorm.DB, raiz models.Material, profundidad int) (GrafoLinaje, error) {
+	grafo := GrafoLinaje{Raiz: raiz.ID, Profundidad: profundidad, Nodos: []NodoLinaje{}, Aristas: []AristaLinaje{}}
+
+	var ancestros, descendientes []filaLinaje
+	if err := db.Raw(sqlAncestros, raiz.ID, profundidad).Scan(&ancestros).Error; err != nil {
+		return grafo, err
+	}
+	if err := db.Raw(sqlDescendientes, raiz.ID, profundidad).Scan(&descendientes).Error; err != nil {
+		return grafo, err
+	}
+
+	vistos := make(map[uuid.UUID]bool)
+	agregar := func(f filaLinaje, nivel int) {
+		if f.Ciclo {
+			// La fila repetida solo indica que el recorrido volvió a un material ya visitado
+			grafo.CicloDetectado = true
+			return
+		}
+		if vistos[f.ID] {
+			return
+		}
+		vistos[f.ID] = true
+		nodo := NodoLinaje{ID: f.ID, Nombre: f.Nombre, Nivel: nivel}
+		if f.DerivadoDe != nil && *f.DerivadoDe != uuid.Nil {
+			nodo.DerivadoDe = f.DerivadoDe
+		}
+		grafo.Nodos = append(grafo.Nodos, nodo)
+	}
+	for _, f := range ancestros {
+		agregar(f, -f.Nivel)
+	}
+	for _, f := range descendientes {
+		agregar(f, f.Nivel)
+	}
+
+	// Aristas entre nodos presentes en el grafo
+	for _, n := range grafo.Nodos {
+		if n.DerivadoDe != nil && vistos[*n.DerivadoDe] {
+			grafo.Aristas = append(grafo.Aristas, AristaLinaje{Desde: *n.DerivadoDe, Hacia: n.ID})
+		}
+	}
+
+	// ¿Quedan generaciones fuera del límite?
+	var extremos []uuid.UUID
+	for _, n := range grafo.Nodos {
+		if n.Nivel == profundidad {
+			extremos = append(extremos, n.ID)
+		}
+		if n.Nivel == -profundidad && n.DerivadoDe != nil && !vistos[*n.DerivadoDe] {
+			grafo.Truncado = true
+		}
+	}
+	if len(extremos) > 0 && !grafo.Truncado {
+		var hijos int64
+		db.Model(&models.Material{}).
+			Where("derivado_de IN ? AND estado = ?", extremos, models.EstadoAprobado).
+			Count(&hijos)
+		grafo.Truncado = hijos > 0
+	}
+
+	return grafo, nil
+}
+
+// comillasDOT escapa un texto para usarlo entre comillas en Graphviz
+func comillasDOT(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// exportarDOT genera el grafo en formato Graphviz DOT
+func exportarDOT(g GrafoLinaje) []byte {
+	var b bytes.Buffer
+	b.WriteString("digraph linaje {\n")
+	b.WriteString("  rankdir=TB;\n  node [shape=box, style=rounded];\n")
+	for _, n := range g.Nodos {
+		attrs := "label=" + comillasDOT(n.Nombre)
+		if n.ID == g.Raiz {
+			attrs += ", style=\"rounded,bold\", color=\"#1f6feb\""
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", comillasDOT(n.ID.String()), attrs)
+	}
+	for _, a := range g.Aristas {
+		fmt.Fprintf(&b, "  %s -> %s;\n", comillasDOT(a.Desde.String()), comillasDOT(a.Hacia.String()))
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+// exportarGraphML genera el grafo en formato GraphML
+func exportarGraphML(g GrafoLinaje) []byte {
+	escapar := func(s string) string {
+		var b bytes.Buffer
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	b.WriteString(`  <key id="nombre" for="node" attr.name="nombre" attr.type="string"/>` + "\n")
+	b.WriteString(`  <key id="nivel" for="node" attr.name="nivel" attr.type="int"/>` + "\n")
+	fmt.Fprintf(&b, "  <graph id=%q edgedefault=\"directed\">\n", "linaje-"+g.Raiz.String())
+	for _, n := range g.Nodos {
+		fmt.Fprintf(&b, "    <node id=%q>\n", n.ID.String())
+		fmt.Fprintf(&b, "      <data key=\"nombre\">%s</data>\n", escapar(n.Nombre))
+		fmt.Fprintf(&b, "      <data key=\"nivel\">%d</data>\n", n.Nivel)
+		b.WriteString("    </node>\n")
+	}
+	for i, a := range g.Aristas {
+		fmt.Fprintf(&b, "    <edge id=\"e%d\" source=%q target=%q/>\n", i, a.Desde.String(), a.Hacia.String())
+	}
+	b.WriteString("  </graph>\n</graphml>\n")
+	return b.Bytes()
+}
+
+// GetMaterialLineage devuelve ancestros y descendientes aprobados de un material.
+// ?depth limita las generaciones en cada dirección (por defecto 10, máximo 50) y
+// ?format=json|dot|graphml elige el formato; dot y graphml se descargan como archivo
+func GetMaterialLineage(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	profundidad := profundidadLinaje
+	if v := c.Query("depth"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parámetro 'depth' inválido"})
+			return
+		}
+		profundidad = min(n, maxProfundidadLinaje)
+	}
+
+	formato := strings.ToLower(c.DefaultQuery("format", "json"))
+	if formato != "json" && formato != "dot" && formato != "graphml" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato inválido. Usa json, dot o graphml"})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	var raiz models.Material
+	if err := db.Where("id = ? AND estado = ?", id, models.EstadoAprobado).First(&raiz).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material no encontrado o no está aprobado"})
+		return
+	}
+
+	grafo, err := construirLinaje(db, raiz, profundidad)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error construyendo linaje: " + err.Error()})
+		return
+	}
+
+	switch formato {
+	case "dot":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="linaje-%s.dot"`, raiz.ID))
+		c.Data(http.StatusOK, "text/vnd.graphviz; charset=utf-8", exportarDOT(grafo))
+	case "graphml":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="linaje-%s.graphml"`, raiz.ID))
+		c.Data(http.StatusOK, "application/graphml+xml; charset=utf-8", exportarGraphML(grafo))
+	default:
+		c.JSON(http.StatusOK, grafo)
+	}
+}
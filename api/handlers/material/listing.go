@@ -0,0 +1,191 @@
+package material
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"TT-SEM-2-BACK/api/database"
+	"TT-SEM-2-BACK/api/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	limitePorDefecto = 20
+	limiteMaximo     = 100
+)
+
+// columnasOrden traduce los valores aceptados en ?sort a columnas de materials
+var columnasOrden = map[string]string{
+	"nombre":      "nombre",
+	"name":        "nombre",
+	"creado":      "created_at",
+	"created":     "created_at",
+	"created_at":  "created_at",
+	"actualizado": "updated_at",
+	"updated":     "updated_at",
+	"updated_at":  "updated_at",
+}
+
+// paginacion son los parámetros de página y orden de un listado
+type paginacion struct {
+	Page    int
+	Limit   int
+	Columna string
+	Desc    bool
+}
+
+// leerPaginacion lee ?page, ?limit, ?sort y ?order. sort acepta un "-" delante para orden descendente
+func leerPaginacion(c *gin.Context) (paginacion, error) {
+	p := paginacion{Page: 1, Limit: limitePorDefecto, Columna: "nombre"}
+
+	if v := c.Query("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return p, fmt.Errorf("Parámetro 'page' inválido")
+		}
+		p.Page = page
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return p, fmt.Errorf("Parámetro 'limit' inválido")
+		}
+		p.Limit = min(limit, limiteMaximo)
+	}
+
+	if v := strings.ToLower(strings.TrimSpace(c.Query("sort"))); v != "" {
+		if strings.HasPrefix(v, "-") {
+			p.Desc = true
+			v = v[1:]
+		}
+		columna, ok := columnasOrden[v]
+		if !ok {
+			return p, fmt.Errorf("Orden '%s' inválido. Usa nombre, creado o actualizado", v)
+		}
+		p.Columna = columna
+	}
+	switch strings.ToLower(c.Query("order")) {
+	case "":
+	case "asc":
+		p.Desc = false
+	case "desc":
+		p.Desc = true
+	default:
+		return p, fmt.Errorf("Parámetro 'order' inválido. Usa asc o desc")
+	}
+
+	return p, nil
+}
+
+// aplicar ordena y recorta la consulta. El id desempata para que las páginas sean estables
+func (p paginacion) aplicar(query *gorm.DB) *gorm.DB {
+	dir := "ASC"
+	if p.Desc {
+		dir = "DESC"
+	}
+	return query.
+		Order(fmt.Sprintf("materials.%s %s", p.Columna, dir)).
+		Order("materials.id ASC").
+		Offset((p.Page - 1) * p.Limit).
+		Limit(p.Limit)
+}
+
+// arregloJSON protege jsonb_array_elements de las columnas que quedaron en JSON null (slices vacíos)
+func arregloJSON(expr string) string {
+	return fmt.Sprintf("(CASE WHEN jsonb_typeof(%[1]s) = 'array' THEN %[1]s ELSE '[]'::jsonb END)", expr)
+}
+
+// valoresFiltro lee un filtro que puede venir repetido (?herramientas=a&herramientas=b) o separado por comas
+func valoresFiltro(c *gin.Context, nombre string) []string {
+	valores := []string{}
+	for _, v := range c.QueryArray(nombre) {
+		for _, parte := range strings.Split(v, ",") {
+			if parte = strings.TrimSpace(parte); parte != "" {
+				valores = append(valores, parte)
+			}
+		}
+	}
+	return valores
+}
+
+// filtrarCatalogo aplica los filtros de herramientas, composición, categoría y etiquetas. Herramientas y
+// composición se comparan sin mayúsculas ni acentos y contra todas las variantes del término en el
+// vocabulario, así que filtrar por "Glicerina" también encuentra los materiales que dicen "glicerol".
+// ?categoria (slug o id) incluye sus subcategorías. Con varios valores el material debe tenerlos todos
+func filtrarCatalogo(c *gin.Context, query *gorm.DB) *gorm.DB {
+	if categoria := strings.TrimSpace(c.Query("categoria")); categoria != "" {
+		query = query.Where(`materials.categoria_id IN (
+			WITH RECURSIVE sub AS (
+				SELECT id FROM categorias WHERE slug = ? OR id::text = ?
+				UNION
+				SELECT cat.id FROM categorias cat JOIN sub ON cat.parent_id = sub.id
+			) SELECT id FROM sub)`, categoria, categoria)
+	}
+	for _, e := range valoresFiltro(c, "etiquetas") {
+		etiqueta, _ := json.Marshal([]string{normalizarEtiqueta(e)})
+		query = query.Where(`materials.etiquetas @> ?::jsonb`, string(etiqueta))
+	}
+
+	herramientas, composicion := valoresFiltro(c, "herramientas"), valoresFiltro(c, "composicion")
+	if len(herramientas) == 0 && len(composicion) == 0 {
+		return query
+	}
+
+	var v vocabulario
+	if db, err := database.GetDB(); err == nil {
+		if v, err = cargarVocabulario(db); err != nil {
+			log.Printf("⚠️ Error leyendo el vocabulario, se filtra sin sinónimos: %v", err)
+		}
+	}
+
+	for _, h := range herramientas {
+		query = query.Where(`EXISTS (
+			SELECT 1 FROM jsonb_array_elements_text(`+arregloJSON("materials.herramientas")+`) AS h
+			WHERE unaccent(lower(btrim(h))) IN ?)`, v.variantes(models.TerminoHerramienta, h))
+	}
+	for _, e := range composicion {
+		query = query.Where(`EXISTS (
+			SELECT 1 FROM jsonb_array_elements(`+arregloJSON("materials.composicion")+`) AS e
+			WHERE unaccent(lower(btrim(e->>'elemento'))) IN ?)`, v.variantes(models.TerminoElemento, e))
+	}
+	return query
+}
+
+// enlacePagina arma la URL de la misma consulta apuntando a otra página
+func enlacePagina(c *gin.Context, page int) string {
+	q := c.Request.URL.Query()
+	q.Set("page", strconv.Itoa(page))
+	return c.Request.URL.Path + "?" + q.Encode()
+}
+
+// responderPagina responde el listado con el total y los enlaces a la página anterior y siguiente
+func responderPagina(c *gin.Context, data interface{}, total int64, p paginacion) {
+	totalPaginas := int(math.Ceil(float64(total) / float64(p.Limit)))
+
+	var next, prev *string
+	if p.Page < totalPaginas {
+		link := enlacePagina(c, p.Page+1)
+		next = &link
+	}
+	if p.Page > 1 {
+		link := enlacePagina(c, min(p.Page-1, max(totalPaginas, 1)))
+		prev = &link
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        data,
+		"total":       total,
+		"page":        p.Page,
+		"limit":       p.Limit,
+		"total_pages": totalPaginas,
+		"next":        next,
+		"prev":        prev,
+	})
+}
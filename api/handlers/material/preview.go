@@ -0,0 +1,174 @@
+package material
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"TT-SEM-2-BACK/api/database"
+	"TT-SEM-2-BACK/api/middleware"
+	"TT-SEM-2-BACK/api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Retroalimentacion es la última decisión de un revisor con su motivo
+type Retroalimentacion struct {
+	Tipo     string          `json:"tipo"` // "material" (cambio de estado) o "revision" (cambios a un publicado)
+	Estado   string          `json:"estado"`
+	Motivo   string          `json:"motivo,omitempty"`
+	Revision *int            `json:"revision,omitempty"` // Número de la revisión revisada
+	Revisor  *models.Usuario `json:"revisor,omitempty"`
+	Fecha    time.Time       `json:"fecha"`
+}
+
+// EstadoModeracion resume dónde está el material en la moderación
+type EstadoModeracion struct {
+	Estado              models.EstadoMaterial    `json:"estado"`
+	Publicado           bool                     `json:"publicado"`
+	Transiciones        []models.EstadoMaterial  `json:"transiciones"`
+	Historial           []models.HistorialEstado `json:"historial"`
+	Retroalimentacion   *Retroalimentacion       `json:"retroalimentacion"`
+	ComentariosAbiertos []ComentarioConEstado    `json:"comentarios_abiertos"`
+	RevisionPendiente   *models.RevisionMaterial `json:"revision_pendiente"` // Cambios en revisión de un material publicado
+}
+
+// ultimaRetroalimentacion busca la decisión más reciente de un revisor: la aprobación o el rechazo del
+// material o de una revisión de un material publicado. Devuelve nil si nunca lo revisaron
+func ultimaRetroalimentacion(db *gorm.DB, materialID uuid.UUID) (*Retroalimentacion, error) {
+	var ret *Retroalimentacion
+	revisorID := ""
+
+	var cambio models.HistorialEstado
+	err := db.Where("material_id = ? AND estado_nuevo IN ?", materialID,
+		[]models.EstadoMaterial{models.EstadoAprobado, models.EstadoRechazado}).
+		Order("created_at DESC").
+		First(&cambio).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		ret = &Retroalimentacion{Tipo: "material", Estado: string(cambio.EstadoNuevo), Motivo: cambio.Motivo, Fecha: cambio.CreatedAt}
+		revisorID = cambio.UsuarioID
+	}
+
+	var revisada models.RevisionMaterial
+	err = db.Select("numero", "estado", "revisor_id", "motivo", "revisada_en").
+		Where("material_id = ? AND estado IN ? AND revisada_en IS NOT NULL", materialID,
+			[]models.EstadoRevision{models.RevisionAprobada, models.RevisionRechazada}).
+		Order("revisada_en DESC").
+		First(&revisada).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil && (ret == nil || revisada.RevisadaEn.After(ret.Fecha)) {
+		numero := revisada.Numero
+		ret = &Retroalimentacion{Tipo: "revision", Estado: string(revisada.Estado), Motivo: revisada.Motivo,
+			Revision: &numero, Fecha: *revisada.RevisadaEn}
+		revisorID = revisada.RevisorID
+	}
+
+	if ret != nil && revisorID != "" {
+		var revisor models.Usuario
+		if db.Where("google_id = ?", revisorID).First(&revisor).Error == nil {
+			ret.Revisor = &revisor
+		}
+	}
+	return ret, nil
+}
+
+// cargarModeracion reúne el estado de moderación del material: historial de estados, última decisión de un
+// revisor, comentarios sin resolver y, si está publicado, la revisión pendiente
+func cargarModeracion(db *gorm.DB, material models.Material) (EstadoModeracion, error) {
+	moderacion := EstadoModeracion{
+		Estado:       material.Estado,
+		Publicado:    material.Estado == models.EstadoAprobado,
+		Transiciones: material.Estado.TransicionesPermitidas(),
+		Historial:    []models.HistorialEstado{},
+	}
+	if err := db.Where("material_id = ?", material.ID).Order("created_at DESC").
+		Find(&moderacion.Historial).Error; err != nil {
+		return moderacion, fmt.Errorf("historial: %w", err)
+	}
+
+	var err error
+	if moderacion.Retroalimentacion, err = ultimaRetroalimentacion(db, material.ID); err != nil {
+		return moderacion, fmt.Errorf("retroalimentación: %w", err)
+	}
+	abiertos := false
+	if moderacion.ComentariosAbiertos, err = comentariosConEstado(db, material.ID, &abiertos); err != nil {
+		return moderacion, fmt.Errorf("comentarios: %w", err)
+	}
+	if moderacion.Publicado {
+		if moderacion.RevisionPendiente, err = buscarRevisionPendiente(db, material.ID); err != nil {
+			return moderacion, fmt.Errorf("revisión pendiente: %w", err)
+		}
+		if moderacion.RevisionPendiente != nil {
+			db.Preload("Autor").First(moderacion.RevisionPendiente, "id = ?", moderacion.RevisionPendiente.ID)
+		}
+	}
+	return moderacion, nil
+}
+
+// GetMaterialPreview devuelve el detalle de un material en cualquier estado junto con su estado de
+// moderación - Propietario, colaboradores o Admin. Para los demás un material no publicado no existe (404);
+// los publicados los puede ver cualquiera, igual que en GET /materials/:id
+func GetMaterialPreview(c *gin.Context) {
+	googleID, exists := middleware.GetUserGoogleID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Datos de usuario incompletos"})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	// 1. Material en cualquier estado y permiso del usuario
+	var material models.Material
+	if err := db.Where("id = ?", id).
+		Preload("Creador").
+		Preload("Categoria").
+		Preload("Colaboradores").
+		Preload("Pasos").
+		Preload("Galeria").
+		First(&material).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material no encontrado o no está aprobado"})
+		return
+	}
+
+	rol := rolEnMaterial(db, material, googleID, middleware.IsAdmin(c))
+	if rol == "" && material.Estado != models.EstadoAprobado {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material no encontrado o no está aprobado"})
+		return
+	}
+	// Quien no colabora solo ve lo público, sin la moderación
+	if rol == "" {
+		c.JSON(http.StatusOK, gin.H{"material": material, "rol": nil, "moderacion": nil})
+		return
+	}
+
+	// 2. Estado de moderación
+	moderacion, err := cargarModeracion(db, material)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error obteniendo estado de moderación: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"material":   material,
+		"rol":        rol,
+		"moderacion": moderacion,
+	})
+}
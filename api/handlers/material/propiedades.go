@@ -0,0 +1,229 @@
+package material
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"TT-SEM-2-BACK/api/database"
+	"TT-SEM-2-BACK/api/models"
+	"TT-SEM-2-BACK/api/units"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AdvertenciaPropiedad es una propiedad mecánica cuyo valor no se pudo interpretar.
+// No impide guardar, pero esa propiedad no aparecerá en las búsquedas por rango
+type AdvertenciaPropiedad struct {
+	Propiedad string `json:"propiedad"`
+	Valor     string `json:"valor"`
+	Unidad    string `json:"unidad"`
+	Error     string `json:"error"`
+}
+
+// materialConAdvertencias agrega a la respuesta las propiedades que no se pudieron interpretar
+type materialConAdvertencias struct {
+	models.Material
+	Advertencias        []AdvertenciaPropiedad         `json:"advertencias,omitempty"`
+	Vocabulario         []AvisoVocabulario             `json:"vocabulario,omitempty"`           // Términos canonizados o sin catalogar
+	DiferenciasConPadre *DiffSnapshot                  `json:"diferencias_con_padre,omitempty"` // Solo materiales derivados
+	Invitaciones        []models.InvitacionColaborador `json:"invitaciones,omitempty"`          // Estado de cada colaborador invitado
+}
+
+// normalizarMecanicas calcula el valor normalizado de cada propiedad mecánica y devuelve las que fallaron
+func normalizarMecanicas(props models.JSONMecanicas) []AdvertenciaPropiedad {
+	var advertencias []AdvertenciaPropiedad
+	for i := range props {
+		valor, base, err := units.NormalizarPropiedad(props[i].Valor, props[i].Unidad)
+		props[i].ValorNormalizado = valor
+		props[i].UnidadNormalizada = base
+		if err != nil {
+			advertencias = append(advertencias, AdvertenciaPropiedad{
+				Propiedad: props[i].Nombre,
+				Valor:     props[i].Valor,
+				Unidad:    props[i].Unidad,
+				Error:     err.Error(),
+			})
+		}
+	}
+	return advertencias
+}
+
+// claveProp imita unaccent(lower(btrim(...))) de Postgres para comparar nombres de propiedades:
+// descompone cada letra (NFD) y descarta las marcas, así que quita cualquier acento, no solo los del español
+func claveProp(nombre string) string {
+	minusculas := strings.ToLower(strings.TrimSpace(nombre))
+	// La cadena de transformaciones guarda estado: se crea una por llamada para poder usarla desde varias peticiones
+	sinAcentos := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	clave, _, err := transform.String(sinAcentos, minusculas)
+	if err != nil {
+		return minusculas
+	}
+	return clave
+}
+
+// ResultadoPropiedad es un material encontrado por GET /materials/by-property
+type ResultadoPropiedad struct {
+	ID                   uuid.UUID                `json:"id"`
+	Nombre               string                   `json:"nombre"`
+	Descripcion          string                   `json:"descripcion"`
+	PrimeraImagenGaleria string                   `json:"primera_imagen_galeria,omitempty"`
+	Propiedad            models.PropiedadMecanica `json:"propiedad"`
+	Valor                float64                  `json:"valor"`  // En la unidad pedida (o en la base)
+	Unidad               string                   `json:"unidad"` // Unidad de Valor
+}
+
+// GetUnits devuelve el registro de unidades agrupado por magnitud
+func GetUnits(c *gin.Context) {
+	c.JSON(http.StatusOK, units.Registro())
+}
+
+// GetMaterialsByProperty filtra y ordena materiales aprobados por el valor de una propiedad mecánica.
+// ?propiedad=Resistencia a la tracción&min=10&max=50&unidad=MPa&order=desc. min y max se interpretan en
+// la unidad pedida y se comparan contra los valores normalizados, así que un material cargado en GPa o psi
+// también aparece. Acepta los mismos filtros y paginación que GET /materials
+func GetMaterialsByProperty(c *gin.Context) {
+	propiedad := strings.TrimSpace(c.Query("propiedad"))
+	if propiedad == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El parámetro 'propiedad' es requerido"})
+		return
+	}
+
+	// 1. Unidad y rango
+	var unidad *units.Unidad
+	if s := c.Query("unidad"); s != "" {
+		u, ok := units.Buscar(s)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unidad '%s' desconocida", s)})
+			return
+		}
+		unidad = &u
+	}
+
+	leerLimite := func(nombre string) (*float64, error) {
+		s := c.Query(nombre)
+		if s == "" {
+			return nil, nil
+		}
+		if unidad == nil {
+			return nil, fmt.Errorf("El parámetro 'unidad' es requerido para usar '%s'", nombre)
+		}
+		v, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+		if err != nil {
+			return nil, fmt.Errorf("Parámetro '%s' inválido", nombre)
+		}
+		base := unidad.ABase(v)
+		return &base, nil
+	}
+	minimo, err := leerLimite("min")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	maximo, err := leerLimite("max")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pag, err := leerPaginacion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	// 2. Condición sobre los elementos del JSON de propiedades mecánicas
+	condicion := `unaccent(lower(p->>'nombre')) = unaccent(lower(?)) AND p->>'valor_normalizado' IS NOT NULL`
+	vars := []interface{}{propiedad}
+	if unidad != nil {
+		condicion += ` AND p->>'unidad_normalizada' = ?`
+		vars = append(vars, units.BaseDe(unidad.Magnitud).Simbolo)
+	}
+	filtroValor := condicion
+	filtroVars := append([]interface{}{}, vars...)
+	if minimo != nil {
+		filtroValor += ` AND (p->>'valor_normalizado')::float8 >= ?`
+		filtroVars = append(filtroVars, *minimo)
+	}
+	if maximo != nil {
+		filtroValor += ` AND (p->>'valor_normalizado')::float8 <= ?`
+		filtroVars = append(filtroVars, *maximo)
+	}
+	elementos := "jsonb_array_elements(" + arregloJSON("materials.propiedades_mecanicas") + ") AS p"
+
+	query := filtrarCatalogo(c, db.Model(&models.Material{}).
+		Where("estado = ?", models.EstadoAprobado).
+		Where("EXISTS (SELECT 1 FROM "+elementos+" WHERE "+filtroValor+")", filtroVars...)).
+		Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error contando materiales: " + err.Error()})
+		return
+	}
+
+	// 3. Página ordenada por el valor de la propiedad (o por ?sort si se pide otro orden)
+	var materials []models.Material
+	pagina := query.Preload("Galeria", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") })
+	if c.Query("sort") == "" {
+		dir := "ASC"
+		if pag.Desc {
+			dir = "DESC"
+		}
+		pagina = pagina.
+			Order(clause.Expr{
+				SQL:                "(SELECT MIN((p->>'valor_normalizado')::float8) FROM " + elementos + " WHERE " + condicion + ") " + dir + " NULLS LAST",
+				Vars:               vars,
+				WithoutParentheses: true,
+			}).
+			Order("materials.id ASC").
+			Offset((pag.Page - 1) * pag.Limit).Limit(pag.Limit)
+	} else {
+		pagina = pag.aplicar(pagina)
+	}
+	if err := pagina.Find(&materials).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error listando materiales: " + err.Error()})
+		return
+	}
+
+	// 4. Valor de la propiedad en la unidad pedida
+	resultados := make([]ResultadoPropiedad, 0, len(materials))
+	for _, m := range materials {
+		r := ResultadoPropiedad{ID: m.ID, Nombre: m.Nombre, Descripcion: m.Descripcion}
+		if len(m.Galeria) > 0 {
+			r.PrimeraImagenGaleria = m.Galeria[0].URLImagen
+		}
+		for _, p := range m.PropiedadesMecanicas {
+			if claveProp(p.Nombre) != claveProp(propiedad) || p.ValorNormalizado == nil {
+				continue
+			}
+			base, ok := units.Buscar(p.UnidadNormalizada)
+			if unidad != nil && (!ok || base.Magnitud != unidad.Magnitud) {
+				continue
+			}
+			r.Propiedad = p
+			r.Valor, r.Unidad = *p.ValorNormalizado, p.UnidadNormalizada
+			if unidad != nil {
+				r.Valor, r.Unidad = unidad.DesdeBase(*p.ValorNormalizado), unidad.Simbolo
+			}
+			break
+		}
+		resultados = append(resultados, r)
+	}
+
+	responderPagina(c, resultados, total, pag)
+}
@@ -9,9 +9,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-// GetMaterials lista SOLO materiales aprobados
+// GetMaterials lista SOLO materiales aprobados, paginados (?page, ?limit), ordenados (?sort, ?order)
+// y filtrados por ?herramientas, ?composicion, ?categoria y ?etiquetas
 func GetMaterials(c *gin.Context) {
 	db, err := database.OpenGormDB()
 	if err != nil {
@@ -19,10 +21,26 @@ func GetMaterials(c *gin.Context) {
 		return
 	}
 
-	var materials []models.Material
+	pag, err := leerPaginacion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := filtrarCatalogo(c, db.Model(&models.Material{}).Where("estado = ?", models.EstadoAprobado)).
+		Session(&gorm.Session{}) // Se reutiliza para contar y para paginar
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error contando materiales: " + err.Error()})
+		return
+	}
+
+	materials := []models.Material{}
 	// NOTA: Ya no hacemos Preload de propiedades porque son columnas JSONB y se cargan solas.
-	if err := db.Where("estado = ?", true).
+	if err := pag.aplicar(query).
 		Preload("Creador").
+		Preload("Categoria").
 		Preload("Colaboradores").
 		Preload("Pasos").
 		Preload("Galeria").
@@ -31,7 +49,7 @@ func GetMaterials(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, materials)
+	responderPagina(c, materials, total, pag)
 }
 
 // GetMaterial obtiene un material por ID SOLO si está aprobado
@@ -50,8 +68,9 @@ func GetMaterial(c *gin.Context) {
 	}
 
 	var material models.Material
-	if err := db.Where("id = ? AND estado = ?", id, true).
+	if err := db.Where("id = ? AND estado = ?", id, models.EstadoAprobado).
 		Preload("Creador").
+		Preload("Categoria").
 		Preload("Colaboradores").
 		Preload("Pasos").
 		Preload("Galeria").
@@ -71,11 +90,20 @@ type SummaryMaterial struct {
 	Composicion          models.JSONComponentes `json:"composicion"`
 	Herramientas         models.StringArray     `json:"herramientas"`
 	DerivadoDe           uuid.UUID              `json:"derivado_de"`
-	Estado               bool                   `json:"estado"`
+	Estado               models.EstadoMaterial  `json:"estado"`
 	PrimeraImagenGaleria string                 `json:"primera_imagen_galeria,omitempty"`
+	Categoria            *CategoriaResumen      `json:"categoria"`
+	Etiquetas            models.StringArray     `json:"etiquetas"`
+}
+
+// CategoriaResumen es la categoría de un material en los listados
+type CategoriaResumen struct {
+	ID     uuid.UUID `json:"id"`
+	Nombre string    `json:"nombre"`
+	Slug   string    `json:"slug"`
 }
 
-// GetMaterialsSummary lista resumen SOLO de materiales aprobados
+// GetMaterialsSummary lista resumen SOLO de materiales aprobados, con la misma paginación y filtros que GetMaterials
 func GetMaterialsSummary(c *gin.Context) {
 	db, err := database.OpenGormDB()
 	if err != nil {
@@ -83,21 +111,45 @@ func GetMaterialsSummary(c *gin.Context) {
 		return
 	}
 
+	pag, err := leerPaginacion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := filtrarCatalogo(c, db.Model(&models.Material{}).Where("estado = ?", models.EstadoAprobado)).
+		Session(&gorm.Session{}) // Se reutiliza para contar y para paginar
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error contando materiales: " + err.Error()})
+		return
+	}
+
 	var materials []models.Material
 	// Solo necesitamos cargar Galería para la foto de portada
-	if err := db.Where("estado = ?", true).
-		Preload("Galeria").
+	if err := pag.aplicar(query).
+		Preload("Galeria", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Categoria").
 		Find(&materials).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error listando resumen: " + err.Error()})
 		return
 	}
 
-	var summaries []SummaryMaterial
+	summaries := []SummaryMaterial{}
 	for _, m := range materials {
 		primeraImagen := ""
 		if len(m.Galeria) > 0 {
 			primeraImagen = m.Galeria[0].URLImagen
 		}
+		var categoria *CategoriaResumen
+		if m.Categoria != nil {
+			categoria = &CategoriaResumen{ID: m.Categoria.ID, Nombre: m.Categoria.Nombre, Slug: m.Categoria.Slug}
+		}
+		etiquetas := m.Etiquetas
+		if etiquetas == nil {
+			etiquetas = models.StringArray{}
+		}
 
 		summaries = append(summaries, SummaryMaterial{
 			ID:                   m.ID,
@@ -108,63 +160,16 @@ func GetMaterialsSummary(c *gin.Context) {
 			DerivadoDe:           m.DerivadoDe,
 			Estado:               m.Estado,
 			PrimeraImagenGaleria: primeraImagen,
+			Categoria:            categoria,
+			Etiquetas:            etiquetas,
 		})
 	}
 
-	c.JSON(http.StatusOK, summaries)
+	responderPagina(c, summaries, total, pag)
 }
 
-// GetMaterialsAdmin lista TODOS los materiales - Solo Admin
-func GetMaterialsAdmin(c *gin.Context) {
-	db, err := database.OpenGormDB()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
-		return
-	}
-
-	var materials []models.Material
-	if err := db.Preload("Creador").
-		Preload("Colaboradores").
-		Preload("Pasos").
-		Preload("Galeria").
-		Find(&materials).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error listando materiales: " + err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, materials)
-}
-
-// GetMaterialAdmin obtiene un material por ID sin filtro de estado - Solo Admin
-func GetMaterialAdmin(c *gin.Context) {
-	db, err := database.OpenGormDB()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
-		return
-	}
-
-	idStr := c.Param("id")
-	id, err := uuid.Parse(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
-		return
-	}
-
-	var material models.Material
-	if err := db.Where("id = ?", id).
-		Preload("Creador").
-		Preload("Colaboradores").
-		Preload("Pasos").
-		Preload("Galeria").
-		First(&material).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Material no encontrado"})
-		return
-	}
-
-	c.JSON(http.StatusOK, material)
-}
-
-// GetMaterialsPendientes lista materiales pendientes de aprobación - Solo Admin
+// GetMaterialsPendientes lista materiales pendientes de aprobación y cambios pendientes
+// sobre materiales ya publicados - Solo Admin
 func GetMaterialsPendientes(c *gin.Context) {
 	db, err := database.OpenGormDB()
 	if err != nil {
@@ -173,7 +178,7 @@ func GetMaterialsPendientes(c *gin.Context) {
 	}
 
 	var materials []models.Material
-	if err := db.Where("estado = ?", false).
+	if err := db.Where("estado = ?", models.EstadoPendiente).
 		Preload("Creador").
 		Preload("Galeria").
 		Preload("Pasos").
@@ -204,7 +209,7 @@ func GetDerivedMaterials(c *gin.Context) {
 	}
 
 	var derivedMaterials []models.Material
-	if err := db.Where("derivado_de = ? AND estado = ?", parentID, true).
+	if err := db.Where("derivado_de = ? AND estado = ?", parentID, models.EstadoAprobado).
 		Preload("Creador").
 		Preload("Galeria").
 		Find(&derivedMaterials).Error; err != nil {
@@ -212,7 +217,13 @@ func GetDerivedMaterials(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, derivedMaterials)
+	// ?resumen=true agrega a cada derivado qué cambió respecto al padre
+	respuesta, ok := conResumenDerivados(c, parentID, derivedMaterials)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, respuesta)
 }
 
 // GetMaterialFilters obtiene filtros (Herramientas y Composición)
@@ -230,7 +241,7 @@ func GetMaterialFilters(c *gin.Context) {
 	err = db.Raw(`
         SELECT DISTINCT INITCAP(element)
         FROM materials, jsonb_array_elements_text(herramientas) AS element
-        WHERE estado = true
+        WHERE estado = 'aprobado'
         ORDER BY 1 ASC
     `).Scan(&herramientas).Error
 	if err != nil {
@@ -241,15 +252,63 @@ func GetMaterialFilters(c *gin.Context) {
 	err = db.Raw(`
         SELECT DISTINCT INITCAP(element->>'elemento')
         FROM materials, jsonb_array_elements(composicion) AS element
-        WHERE estado = true
+        WHERE estado = 'aprobado'
         ORDER BY 1 ASC
     `).Scan(&composiciones).Error
 	if err != nil {
 		log.Printf("Error obteniendo filtros composicion: %v", err)
 	}
 
+	// 3. Propiedades mecánicas con valores numéricos, para GET /materials/by-property
+	var propiedades []struct {
+		Nombre string `json:"nombre"`
+		Unidad string `json:"unidad"`
+	}
+	err = db.Raw(`
+        SELECT DISTINCT INITCAP(element->>'nombre') AS nombre, element->>'unidad_normalizada' AS unidad
+        FROM materials, jsonb_array_elements(` + arregloJSON("propiedades_mecanicas") + `) AS element
+        WHERE estado = 'aprobado' AND element->>'valor_normalizado' IS NOT NULL
+        ORDER BY 1 ASC
+    `).Scan(&propiedades).Error
+	if err != nil {
+		log.Printf("Error obteniendo filtros propiedades mecánicas: %v", err)
+	}
+
+	// 4. Etiquetas con la cantidad de materiales que las usan
+	var etiquetas []struct {
+		Etiqueta   string `json:"etiqueta"`
+		Materiales int64  `json:"materiales"`
+	}
+	err = db.Raw(`
+        SELECT element AS etiqueta, COUNT(*) AS materiales
+        FROM materials, jsonb_array_elements_text(` + arregloJSON("etiquetas") + `) AS element
+        WHERE estado = 'aprobado' AND deleted_at IS NULL
+        GROUP BY 1
+        ORDER BY 2 DESC, 1 ASC
+    `).Scan(&etiquetas).Error
+	if err != nil {
+		log.Printf("Error obteniendo filtros etiquetas: %v", err)
+	}
+
+	// 5. Árbol de categorías con conteos
+	categorias, err := cargarArbolCategorias(db)
+	if err != nil {
+		log.Printf("Error obteniendo filtros categorias: %v", err)
+	}
+
+	// 6. Los valores que están en el vocabulario se muestran una sola vez, con su nombre canónico
+	if v, err := cargarVocabulario(db); err != nil {
+		log.Printf("Error leyendo vocabulario para filtros: %v", err)
+	} else {
+		herramientas = canonizarFiltros(v, models.TerminoHerramienta, herramientas)
+		composiciones = canonizarFiltros(v, models.TerminoElemento, composiciones)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"herramientas": herramientas,
-		"composicion":  composiciones,
+		"herramientas":          herramientas,
+		"composicion":           composiciones,
+		"propiedades_mecanicas": propiedades,
+		"categorias":            categorias,
+		"etiquetas":             etiquetas,
 	})
 }
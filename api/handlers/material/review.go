@@ -0,0 +1,190 @@
+package material
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"TT-SEM-2-BACK/api/database"
+	"TT-SEM-2-BACK/api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// marcarRevisionAprobada marca como aprobada la revisión vigente (la última aplicada) del material
+func marcarRevisionAprobada(tx *gorm.DB, materialID uuid.UUID, revisorID string) error {
+	var vigente models.RevisionMaterial
+	err := tx.Where("material_id = ? AND estado IN ?", materialID,
+		[]models.EstadoRevision{models.RevisionAplicada, models.RevisionAprobada}).
+		Order("numero DESC").
+		First(&vigente).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && vigente.Estado == models.RevisionAprobada) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Model(&vigente).Updates(map[string]interface{}{
+		"estado":      models.RevisionAprobada,
+		"revisor_id":  revisorID,
+		"revisada_en": time.Now().UTC(),
+	}).Error
+}
+
+// ultimaRevisionAprobada devuelve la última versión aprobada del material, o nil si nunca se aprobó
+func ultimaRevisionAprobada(db *gorm.DB, materialID uuid.UUID) (*models.RevisionMaterial, error) {
+	var rev models.RevisionMaterial
+	err := db.Where("material_id = ? AND estado = ?", materialID, models.RevisionAprobada).
+		Order("numero DESC").
+		First(&rev).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// resumirDiff describe el diff en frases cortas para listados
+func resumirDiff(diff DiffSnapshot) []string {
+	resumen := []string{}
+	agregar := func(n int, singular, plural string) {
+		switch {
+		case n == 1:
+			resumen = append(resumen, "1 "+singular)
+		case n > 1:
+			resumen = append(resumen, fmt.Sprintf("%d %s", n, plural))
+		}
+	}
+
+	for _, campo := range diff.Campos {
+		resumen = append(resumen, "Cambió "+campo.Campo)
+	}
+	agregar(len(diff.Composicion.Agregados), "componente agregado", "componentes agregados")
+	agregar(len(diff.Composicion.Eliminados), "componente eliminado", "componentes eliminados")
+	agregar(len(diff.Composicion.Modificados), "componente modificado", "componentes modificados")
+	agregar(len(diff.PropMecanicas.Agregados)+len(diff.PropMecanicas.Eliminados)+len(diff.PropMecanicas.Modificados),
+		"cambio en propiedades mecánicas", "cambios en propiedades mecánicas")
+	agregar(len(diff.PropPerceptivas.Agregados)+len(diff.PropPerceptivas.Eliminados)+len(diff.PropPerceptivas.Modificados),
+		"cambio en propiedades perceptivas", "cambios en propiedades perceptivas")
+	agregar(len(diff.PropEmocionales.Agregados)+len(diff.PropEmocionales.Eliminados)+len(diff.PropEmocionales.Modificados),
+		"cambio en propiedades emocionales", "cambios en propiedades emocionales")
+	agregar(len(diff.Herramientas.Agregadas), "herramienta agregada", "herramientas agregadas")
+	agregar(len(diff.Herramientas.Eliminadas), "herramienta eliminada", "herramientas eliminadas")
+	agregar(len(diff.Etiquetas.Agregadas)+len(diff.Etiquetas.Eliminadas), "cambio en etiquetas", "cambios en etiquetas")
+	agregar(len(diff.Pasos.Agregados), "paso agregado", "pasos agregados")
+	agregar(len(diff.Pasos.Eliminados), "paso eliminado", "pasos eliminados")
+	agregar(len(diff.Pasos.Modificados), "paso modificado", "pasos modificados")
+	agregar(len(diff.Galeria.Agregados)+len(diff.Galeria.Eliminados)+len(diff.Galeria.Modificados),
+		"cambio en la galería", "cambios en la galería")
+
+	return resumen
+}
+
+// GetMaterialReview devuelve lo que cambió en un material pendiente respecto a su última versión aprobada - Solo Admin.
+// Sirve tanto para materiales en estado pendiente como para revisiones pendientes de materiales publicados
+func GetMaterialReview(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	material, err := cargarMaterialEditable(db, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material no encontrado"})
+		return
+	}
+	db.Preload("Creador").First(&material, "id = ?", id)
+
+	// 1. Qué se propone publicar
+	var propuesta models.SnapshotMaterial
+	var numeroPropuesta int
+	var autorID string
+	tipo := "material"
+
+	switch material.Estado {
+	case models.EstadoAprobado:
+		pendiente, err := buscarRevisionPendiente(db, material.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error buscando revisión pendiente: " + err.Error()})
+			return
+		}
+		if pendiente == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "El material no tiene cambios pendientes de revisión"})
+			return
+		}
+		propuesta, numeroPropuesta, autorID, tipo = pendiente.Contenido, pendiente.Numero, pendiente.AutorID, "revision"
+	case models.EstadoPendiente:
+		// El contenido propuesto es la versión actual de la fila
+		propuesta = models.SnapshotDe(material)
+		autorID = material.CreadorID
+		numeroPropuesta, _ = siguienteNumeroRevision(db, material.ID)
+		numeroPropuesta--
+		// Con editores el autor del cambio puede no ser el creador: es el de la última revisión guardada
+		var ultima models.RevisionMaterial
+		if err := db.Select("autor_id").Where("material_id = ? AND numero = ?", material.ID, numeroPropuesta).
+			First(&ultima).Error; err == nil {
+			autorID = ultima.AutorID
+		}
+	default:
+		c.JSON(http.StatusConflict, gin.H{
+			"error":         "El material no está pendiente de revisión",
+			"estado_actual": material.Estado,
+		})
+		return
+	}
+
+	// 2. Contra qué se compara: la última versión aprobada (si nunca se aprobó, es un material nuevo)
+	aprobada, err := ultimaRevisionAprobada(db, material.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error buscando versión aprobada: " + err.Error()})
+		return
+	}
+
+	esNuevo := aprobada == nil
+	base := models.SnapshotMaterial{}
+	var numeroAprobada *int
+	if !esNuevo {
+		base = aprobada.Contenido
+		numeroAprobada = &aprobada.Numero
+	}
+
+	diff := diffSnapshots(base, propuesta)
+
+	var autor models.Usuario
+	db.Where("google_id = ?", autorID).First(&autor)
+
+	// 3. Comentarios de moderaciones anteriores, marcando los que el autor ya atendió
+	comentarios, err := comentariosConEstado(db, material.ID, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error obteniendo comentarios: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"material_id":        material.ID,
+		"nombre":             propuesta.Nombre,
+		"tipo":               tipo,
+		"es_nuevo":           esNuevo,
+		"estado":             material.Estado,
+		"creador":            material.Creador,
+		"autor_cambios":      autor,
+		"revision_aprobada":  numeroAprobada,
+		"revision_propuesta": numeroPropuesta,
+		"resumen":            resumirDiff(diff),
+		"diff":               diff,
+		"comentarios":        comentarios,
+	})
+}
@@ -0,0 +1,632 @@
+package material
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"TT-SEM-2-BACK/api/database"
+	"TT-SEM-2-BACK/api/middleware"
+	"TT-SEM-2-BACK/api/models"
+	"TT-SEM-2-BACK/api/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errRevisionResuelta indica que la revisión dejó de estar pendiente (otra petición la aprobó, rechazó o reemplazó)
+var errRevisionResuelta = errors.New("la revisión ya no está pendiente")
+
+// errMaterialNoPublicado indica que el material dejó de estar aprobado mientras su revisión esperaba
+var errMaterialNoPublicado = errors.New("el material ya no está publicado")
+
+// siguienteNumeroRevision devuelve el número que le toca a la próxima revisión del material
+func siguienteNumeroRevision(db *gorm.DB, materialID uuid.UUID) (int, error) {
+	var ultimo int
+	err := db.Model(&models.RevisionMaterial{}).
+		Where("material_id = ?", materialID).
+		Select("COALESCE(MAX(numero), 0)").
+		Scan(&ultimo).Error
+	return ultimo + 1, err
+}
+
+// buscarRevisionPendiente devuelve la revisión pendiente del material, o nil si no tiene
+func buscarRevisionPendiente(db *gorm.DB, materialID uuid.UUID) (*models.RevisionMaterial, error) {
+	var rev models.RevisionMaterial
+	err := db.Where("material_id = ? AND estado = ?", materialID, models.RevisionPendiente).First(&rev).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// prefijoRevision es la carpeta donde se suben los archivos de una revisión, para no pisar los publicados
+func prefijoRevision(materialID uuid.UUID, numero int) string {
+	return fmt.Sprintf("%srevisiones/%d/", prefijoMaterial(materialID), numero)
+}
+
+// sinArchivosDescartados quita de una revisión rechazada o reemplazada los archivos que ya no usa nadie:
+// se borraron del storage al descartarla, así que restaurarla no debe volver a apuntar a ellos
+func sinArchivosDescartados(db *gorm.DB, rev models.RevisionMaterial) (models.SnapshotMaterial, error) {
+	snap := copiarSnapshot(rev.Contenido)
+	urls := snap.URLs()
+	if (rev.Estado != models.RevisionRechazada && rev.Estado != models.RevisionReemplazada) || len(urls) == 0 {
+		return snap, nil
+	}
+	referenciadas, err := urlsReferenciadas(db, urls)
+	if err != nil {
+		return snap, err
+	}
+
+	galeria := []models.SnapshotGaleria{}
+	for _, g := range snap.Galeria {
+		if referenciadas[g.URLImagen] {
+			galeria = append(galeria, g)
+		}
+	}
+	snap.Galeria = galeria
+	for i := range snap.Pasos {
+		if !referenciadas[snap.Pasos[i].URLImagen] {
+			snap.Pasos[i].URLImagen = ""
+		}
+		if !referenciadas[snap.Pasos[i].URLVideo] {
+			snap.Pasos[i].URLVideo = ""
+		}
+	}
+	return snap, nil
+}
+
+// guardarRevisionPendiente reemplaza la revisión pendiente anterior (si hay) por una nueva con el snapshot
+func guardarRevisionPendiente(db *gorm.DB, materialID uuid.UUID, numero int, autorID string, snap models.SnapshotMaterial, restauradaDe *int) (models.RevisionMaterial, error) {
+	rev := models.RevisionMaterial{
+		MaterialID:   materialID,
+		Numero:       numero,
+		Estado:       models.RevisionPendiente,
+		Contenido:    snap,
+		AutorID:      autorID,
+		RestauradaDe: restauradaDe,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.RevisionMaterial{}).
+			Where("material_id = ? AND estado = ?", materialID, models.RevisionPendiente).
+			Update("estado", models.RevisionReemplazada).Error; err != nil {
+			return err
+		}
+		return tx.Create(&rev).Error
+	})
+
+	return rev, err
+}
+
+// registrarRevisionAplicada guarda como nueva revisión el contenido que se acaba de aplicar al material
+func registrarRevisionAplicada(db *gorm.DB, materialID uuid.UUID, snap models.SnapshotMaterial, autorID string, restauradaDe *int) (models.RevisionMaterial, error) {
+	rev := models.RevisionMaterial{
+		MaterialID:   materialID,
+		Estado:       models.RevisionAplicada,
+		Contenido:    snap,
+		AutorID:      autorID,
+		RestauradaDe: restauradaDe,
+	}
+
+	numero, err := siguienteNumeroRevision(db, materialID)
+	if err != nil {
+		return rev, err
+	}
+	rev.Numero = numero
+
+	return rev, db.Create(&rev).Error
+}
+
+// cargarRevision busca la revisión :numero del material :id. Si falla ya respondió
+func cargarRevision(c *gin.Context, db *gorm.DB) (models.RevisionMaterial, bool) {
+	var rev models.RevisionMaterial
+
+	materialID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return rev, false
+	}
+	numero, err := strconv.Atoi(c.Param("numero"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Número de revisión inválido"})
+		return rev, false
+	}
+
+	if err := db.Preload("Autor").
+		Where("material_id = ? AND numero = ?", materialID, numero).
+		First(&rev).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revisión no encontrada"})
+		return rev, false
+	}
+
+	return rev, true
+}
+
+// GetRevision devuelve una revisión del material - Cualquier colaborador o Admin
+func GetRevision(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	material, _, ok := cargarMaterialConRol(c, db, models.RolLector)
+	if !ok {
+		return
+	}
+
+	rev, ok := cargarRevision(c, db)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"material_id":     material.ID,
+		"estado_material": material.Estado,
+		"revision":        rev,
+	})
+}
+
+// GetRevisions lista el historial de revisiones del material (sin el contenido) - Cualquier colaborador o Admin
+func GetRevisions(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	material, _, ok := cargarMaterialConRol(c, db, models.RolLector)
+	if !ok {
+		return
+	}
+
+	var revisiones []models.RevisionMaterial
+	if err := db.Select("id", "material_id", "numero", "estado", "autor_id", "restaurada_de", "revisor_id", "motivo", "revisada_en", "created_at", "updated_at").
+		Preload("Autor").
+		Where("material_id = ?", material.ID).
+		Order("numero DESC").
+		Find(&revisiones).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error listando revisiones: " + err.Error()})
+		return
+	}
+
+	// La versión pública/actual es la última revisión aplicada o aprobada
+	numeroActual := 0
+	for _, r := range revisiones {
+		if r.Estado == models.RevisionAplicada || r.Estado == models.RevisionAprobada {
+			numeroActual = r.Numero
+			break
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"material_id":     material.ID,
+		"estado_material": material.Estado,
+		"numero_actual":   numeroActual,
+		"total":           len(revisiones),
+		"revisiones":      revisiones,
+	})
+}
+
+// DiffRevisions compara dos revisiones del material (?desde=N&hasta=M) - Cualquier colaborador o Admin.
+// desde=0 compara contra un material vacío, como la primera revisión que no tiene anterior
+func DiffRevisions(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	material, _, ok := cargarMaterialConRol(c, db, models.RolLector)
+	if !ok {
+		return
+	}
+
+	// hasta: por defecto la última revisión; desde: por defecto la anterior a hasta (0 si hasta es la primera)
+	hasta, err := strconv.Atoi(c.Query("hasta"))
+	if c.Query("hasta") == "" {
+		hasta, err = siguienteNumeroRevision(db, material.ID)
+		hasta--
+	}
+	if err != nil || hasta < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parámetro 'hasta' inválido"})
+		return
+	}
+	desde, err := strconv.Atoi(c.DefaultQuery("desde", strconv.Itoa(hasta-1)))
+	if err != nil || desde < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parámetro 'desde' inválido"})
+		return
+	}
+
+	var revisiones []models.RevisionMaterial
+	if err := db.Where("material_id = ? AND numero IN ?", material.ID, []int{desde, hasta}).
+		Find(&revisiones).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error buscando revisiones: " + err.Error()})
+		return
+	}
+
+	porNumero := make(map[int]models.RevisionMaterial)
+	for _, r := range revisiones {
+		porNumero[r.Numero] = r
+	}
+	revDesde, okDesde := porNumero[desde]
+	if desde == 0 {
+		revDesde, okDesde = models.RevisionMaterial{Contenido: models.SnapshotMaterial{}}, true
+	}
+	revHasta, okHasta := porNumero[hasta]
+	if !okDesde || !okHasta {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revisión no encontrada", "desde": desde, "hasta": hasta})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"material_id": material.ID,
+		"desde":       desde,
+		"hasta":       hasta,
+		"diff":        diffSnapshots(revDesde.Contenido, revHasta.Contenido),
+	})
+}
+
+// RestoreRevision vuelve el material al contenido de una revisión anterior - Propietario, editores o Admin.
+// Si el material está publicado y quien restaura no es admin, la restauración queda pendiente de revisión
+func RestoreRevision(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	store, err := storage.GetStorage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error configurando el storage: " + err.Error()})
+		return
+	}
+
+	material, googleID, ok := cargarMaterialConRol(c, db, models.RolEditor)
+	if !ok {
+		return
+	}
+	if material.Estado == models.EstadoArchivado {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":         "El material está archivado",
+			"detail":        "Desarchívalo antes de restaurar una revisión",
+			"estado_actual": material.Estado,
+		})
+		return
+	}
+
+	rev, ok := cargarRevision(c, db)
+	if !ok {
+		return
+	}
+	origen := rev.Numero
+	contenido, err := sinArchivosDescartados(db, rev)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error verificando archivos de la revisión: " + err.Error()})
+		return
+	}
+
+	// 1. Material publicado y sin permisos de moderación: queda como revisión pendiente
+	if material.Estado == models.EstadoAprobado && !middleware.IsAdmin(c) {
+		anterior, err := buscarRevisionPendiente(db, material.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error buscando revisión pendiente: " + err.Error()})
+			return
+		}
+		numero, err := siguienteNumeroRevision(db, material.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error numerando revisión: " + err.Error()})
+			return
+		}
+		pendiente, err := guardarRevisionPendiente(db, material.ID, numero, googleID, contenido, &origen)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando revisión: " + err.Error()})
+			return
+		}
+
+		// Los archivos que solo usaba la revisión reemplazada se descartan
+		if anterior != nil {
+			eliminarArchivosSinUso(c.Request.Context(), db, store, urlsQuitadas(anterior.Contenido, contenido))
+		}
+
+		var editor models.Usuario
+		db.Where("google_id = ?", googleID).First(&editor)
+		notificarUpdate(material.ID, material.Nombre, editor.Nombre)
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":  fmt.Sprintf("Restauración de la revisión %d enviada a revisión", origen),
+			"revision": pendiente.Numero,
+		})
+		return
+	}
+
+	// 2. En cualquier otro caso se aplica directamente y queda registrada como nueva revisión
+	editable, err := cargarMaterialEditable(db, material.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material no encontrado"})
+		return
+	}
+	anterior := models.SnapshotDe(editable)
+
+	var nueva models.RevisionMaterial
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := aplicarSnapshot(tx, &editable, contenido); err != nil {
+			return err
+		}
+		var err error
+		nueva, err = registrarRevisionAplicada(tx, editable.ID, contenido, googleID, &origen)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error restaurando revisión: " + err.Error()})
+		return
+	}
+
+	eliminarArchivosSinUso(c.Request.Context(), db, store, urlsQuitadas(anterior, contenido))
+	log.Printf("⏪ Material %s (%s) restaurado a la revisión %d por %s", editable.Nombre, editable.ID, origen, googleID)
+
+	db.Preload("Creador").Preload("Colaboradores").Preload("Galeria").Preload("Pasos").First(&editable, "id = ?", editable.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  fmt.Sprintf("Material restaurado a la revisión %d", origen),
+		"material": editable,
+		"revision": nueva.Numero,
+	})
+}
+
+// GetPendingRevisions lista los cambios a materiales publicados que esperan moderación, los más antiguos
+// primero - Solo Admin
+func GetPendingRevisions(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	var revisiones []models.RevisionMaterial
+	if err := db.Where("estado = ?", models.RevisionPendiente).
+		Preload("Autor").
+		Order("created_at ASC").
+		Find(&revisiones).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error listando revisiones pendientes: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":      len(revisiones),
+		"revisiones": revisiones,
+	})
+}
+
+// ApproveRevision aplica una revisión pendiente a la versión pública del material - Solo Admin
+func ApproveRevision(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	rev, ok := cargarRevision(c, db)
+	if !ok {
+		return
+	}
+	if rev.Estado != models.RevisionPendiente {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":           "La revisión no está pendiente",
+			"estado_revision": rev.Estado,
+		})
+		return
+	}
+
+	material, err := cargarMaterialEditable(db, rev.MaterialID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material no encontrado"})
+		return
+	}
+	anterior := models.SnapshotDe(material)
+
+	adminGoogleID, _ := middleware.GetUserGoogleID(c)
+	ahora := time.Now().UTC()
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// La condición sobre el estado evita aplicar dos veces la revisión o aplicar una ya reemplazada
+		res := tx.Model(&models.RevisionMaterial{}).
+			Where("id = ? AND estado = ?", rev.ID, models.RevisionPendiente).
+			Updates(map[string]interface{}{
+				"estado":      models.RevisionAprobada,
+				"revisor_id":  adminGoogleID,
+				"revisada_en": ahora,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != 1 {
+			return errRevisionResuelta
+		}
+
+		// Una revisión que quedó pendiente sobre un material archivado no se publica
+		var actual models.Material
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "estado").
+			First(&actual, "id = ?", material.ID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errMaterialNoPublicado // Se eliminó
+		}
+		if err != nil {
+			return err
+		}
+		if actual.Estado != models.EstadoAprobado {
+			material.Estado = actual.Estado
+			return errMaterialNoPublicado
+		}
+
+		return aplicarSnapshot(tx, &material, rev.Contenido)
+	})
+	if errors.Is(err, errRevisionResuelta) {
+		c.JSON(http.StatusConflict, gin.H{"error": "La revisión ya no está pendiente"})
+		return
+	}
+	if errors.Is(err, errMaterialNoPublicado) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":         "El material ya no está publicado, la revisión no se puede aplicar",
+			"estado_actual": material.Estado,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error aprobando revisión: " + err.Error()})
+		return
+	}
+
+	// Los archivos de la versión anterior que ya nadie usa se borran del storage
+	if store, err := storage.GetStorage(); err == nil {
+		eliminarArchivosSinUso(c.Request.Context(), db, store, urlsQuitadas(anterior, rev.Contenido))
+	}
+
+	notificarRevision(rev.AutorID, material.ID, material.Nombre, true, "")
+	log.Printf("✅ Revisión %d de %s (%s) aprobada por admin: %s", rev.Numero, material.Nombre, material.ID, adminGoogleID)
+
+	db.Preload("Creador").Preload("Colaboradores").Preload("Galeria").Preload("Pasos").First(&material, "id = ?", material.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Revisión aprobada, los cambios ya son públicos",
+		"material": material,
+		"revision": rev.Numero,
+	})
+}
+
+// RejectRevision descarta una revisión pendiente; la versión pública no cambia - Solo Admin
+func RejectRevision(c *gin.Context) {
+	var req RechazoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Println("No se envió razón de rechazo o JSON inválido")
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	store, err := storage.GetStorage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error configurando el storage: " + err.Error()})
+		return
+	}
+
+	rev, ok := cargarRevision(c, db)
+	if !ok {
+		return
+	}
+	if rev.Estado != models.RevisionPendiente {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":           "La revisión no está pendiente",
+			"estado_revision": rev.Estado,
+		})
+		return
+	}
+
+	var material models.Material
+	if err := db.First(&material, "id = ?", rev.MaterialID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material no encontrado"})
+		return
+	}
+
+	for _, com := range req.Comentarios {
+		if err := validarComentario(rev.Contenido, com); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// El rechazo y sus comentarios se guardan juntos: un rechazo nunca queda sin su explicación
+	adminGoogleID, _ := middleware.GetUserGoogleID(c)
+	var comentarios []models.ComentarioRevision
+	err = db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.RevisionMaterial{}).
+			Where("id = ? AND estado = ?", rev.ID, models.RevisionPendiente).
+			Updates(map[string]interface{}{
+				"estado":      models.RevisionRechazada,
+				"revisor_id":  adminGoogleID,
+				"motivo":      req.Razon,
+				"revisada_en": time.Now().UTC(),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != 1 {
+			return errRevisionResuelta
+		}
+		var err error
+		if comentarios, err = crearComentarios(tx, material.ID, rev.Numero, adminGoogleID, req.Comentarios); err != nil {
+			return fmt.Errorf("error guardando comentarios de rechazo: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, errRevisionResuelta) {
+		c.JSON(http.StatusConflict, gin.H{"error": "La revisión ya no está pendiente"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error rechazando revisión: " + err.Error()})
+		return
+	}
+	db.Preload("Autor").First(&rev, "id = ?", rev.ID)
+
+	// Los archivos que solo subió esta revisión ya no los usa nadie
+	eliminarArchivosSinUso(c.Request.Context(), db, store, rev.Contenido.URLs())
+
+	notificarRevision(rev.AutorID, material.ID, material.Nombre, false, req.Razon)
+	log.Printf("❌ Revisión %d de %s (%s) rechazada por admin: %s. Razón: %s", rev.Numero, material.Nombre, material.ID, adminGoogleID, req.Razon)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Revisión rechazada, la versión pública se mantiene",
+		"revision":    rev,
+		"comentarios": comentarios,
+	})
+}
+
+// notificarRevision avisa al autor de una revisión si fue aprobada o rechazada
+func notificarRevision(usuarioID string, matID uuid.UUID, matNombre string, aprobada bool, motivo string) {
+	if usuarioID == "" {
+		return // El autor se eliminó
+	}
+	go func() {
+		db, err := database.GetDB()
+		if err != nil {
+			log.Printf("⚠️ Error conectando DB para notificación: %v", err)
+			return
+		}
+
+		notif := models.Notificacion{
+			ID:         uuid.New(),
+			UsuarioID:  usuarioID,
+			MaterialID: &matID,
+			Titulo:     "Cambios Aprobados",
+			Mensaje:    "Tus cambios a '" + matNombre + "' fueron aprobados y ya son públicos.",
+			Tipo:       "aprobado",
+			Link:       "/material/" + matID.String(),
+		}
+		if !aprobada {
+			notif.Titulo = "Cambios Rechazados"
+			notif.Mensaje = "Tus cambios a '" + matNombre + "' fueron rechazados. La versión publicada no cambió."
+			if motivo != "" {
+				notif.Mensaje += " Motivo: " + motivo
+			}
+			notif.Tipo = "rechazado"
+			notif.Link = "/notification/#" + notif.ID.String()
+		}
+
+		if err := db.Create(&notif).Error; err != nil {
+			log.Printf("⚠️ Error guardando notificación: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,114 @@
+package material
+
+import (
+	"net/http"
+	"strings"
+
+	"TT-SEM-2-BACK/api/database"
+	"TT-SEM-2-BACK/api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// opcionesResaltado son las opciones de ts_headline para los fragmentos
+const opcionesResaltado = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=25, MinWords=8, FragmentDelimiter=\" … \""
+
+// ResultadoBusqueda es un material encontrado por GET /materials/search
+type ResultadoBusqueda struct {
+	ID                   uuid.UUID              `json:"id"`
+	Nombre               string                 `json:"nombre"`
+	Descripcion          string                 `json:"descripcion"`
+	Composicion          models.JSONComponentes `json:"composicion"`
+	Herramientas         models.StringArray     `json:"herramientas"`
+	PrimeraImagenGaleria string                 `json:"primera_imagen_galeria,omitempty"`
+	Relevancia           float64                `json:"relevancia"`
+	NombreResaltado      string                 `json:"nombre_resaltado"`
+	Fragmento            string                 `json:"fragmento"`
+}
+
+// SearchMaterials busca materiales aprobados por nombre, descripción, pasos, composición y herramientas.
+// Usa la configuración es_unaccent, así que "celulosa" y "celulósa" encuentran lo mismo.
+// Acepta la sintaxis de websearch_to_tsquery ("frase exacta", -excluir, or) y los mismos filtros y paginación que GET /materials
+func SearchMaterials(c *gin.Context) {
+	texto := strings.TrimSpace(c.Query("q"))
+	if texto == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El parámetro 'q' es requerido"})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	pag, err := leerPaginacion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tsquery := gorm.Expr("websearch_to_tsquery('es_unaccent', ?)", texto)
+
+	// 1. Solo materiales aprobados que coincidan
+	query := filtrarCatalogo(c, db.Model(&models.Material{}).
+		Where("estado = ?", models.EstadoAprobado).
+		Where("busqueda @@ ?", tsquery)).
+		Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error contando resultados: " + err.Error()})
+		return
+	}
+
+	// 2. Página de resultados con relevancia y fragmentos resaltados.
+	// Por defecto se ordena por relevancia; ?sort permite ordenar como el listado
+	pagina := query.Select(`materials.id, materials.nombre, materials.descripcion,
+			materials.composicion, materials.herramientas,
+			ts_rank_cd(busqueda, ?) AS relevancia,
+			ts_headline('es_unaccent', materials.nombre, ?, 'HighlightAll=true, StartSel=<mark>, StopSel=</mark>') AS nombre_resaltado,
+			ts_headline('es_unaccent', concat_ws(' ', materials.descripcion, (
+				SELECT string_agg(p.descripcion, ' ' ORDER BY p.orden_paso)
+				FROM paso_materials p
+				WHERE p.material_id = materials.id AND p.deleted_at IS NULL
+			)), ?, ?) AS fragmento`,
+		tsquery, tsquery, tsquery, opcionesResaltado)
+
+	if c.Query("sort") == "" {
+		pagina = pagina.Order("relevancia DESC").Order("materials.id ASC").
+			Offset((pag.Page - 1) * pag.Limit).Limit(pag.Limit)
+	} else {
+		pagina = pag.aplicar(pagina)
+	}
+
+	resultados := []ResultadoBusqueda{}
+	if err := pagina.Scan(&resultados).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error buscando materiales: " + err.Error()})
+		return
+	}
+
+	// 3. Foto de portada de cada resultado
+	if len(resultados) > 0 {
+		ids := make([]uuid.UUID, len(resultados))
+		for i, r := range resultados {
+			ids[i] = r.ID
+		}
+		var galeria []models.GaleriaMaterial
+		db.Where("material_id IN ?", ids).Order("id ASC").Find(&galeria)
+
+		portadas := make(map[uuid.UUID]string)
+		for _, g := range galeria {
+			if _, ok := portadas[g.MaterialID]; !ok {
+				portadas[g.MaterialID] = g.URLImagen
+			}
+		}
+		for i := range resultados {
+			resultados[i].PrimeraImagenGaleria = portadas[resultados[i].ID]
+		}
+	}
+
+	responderPagina(c, resultados, total, pag)
+}
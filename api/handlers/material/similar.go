@@ -0,0 +1,306 @@
+package material
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"TT-SEM-2-BACK/api/database"
+	"TT-SEM-2-BACK/api/models"
+	"TT-SEM-2-BACK/api/units"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Peso de cada criterio en el puntaje. Si el material de origen no tiene datos de un criterio,
+// ese peso se reparte entre los demás
+const (
+	pesoComposicion  = 0.35
+	pesoHerramientas = 0.20
+	pesoPropiedades  = 0.20 // Perceptivas y emocionales
+	pesoMecanicas    = 0.25
+
+	maxCandidatosSimilares = 500
+	maxRazones             = 3
+)
+
+// MaterialSimilar es un material recomendado con su puntaje (0 a 1) y los motivos principales
+type MaterialSimilar struct {
+	ID                   uuid.UUID `json:"id"`
+	Nombre               string    `json:"nombre"`
+	PrimeraImagenGaleria string    `json:"primera_imagen_galeria,omitempty"`
+	Puntaje              float64   `json:"puntaje"`
+	Razones              []string  `json:"razones"`
+}
+
+// razonSimilitud es un criterio que aportó al puntaje
+type razonSimilitud struct {
+	aporte float64
+	texto  string
+}
+
+// conjuntoClaves indexa por clave normalizada conservando el primer nombre visto
+func conjuntoClaves(valores []string) map[string]string {
+	set := make(map[string]string, len(valores))
+	for _, v := range valores {
+		if k := claveProp(v); k != "" {
+			if _, ok := set[k]; !ok {
+				set[k] = strings.TrimSpace(v)
+			}
+		}
+	}
+	return set
+}
+
+// jaccard devuelve |A∩B|/|A∪B| y los nombres en común
+func jaccard(a, b map[string]string) (float64, []string) {
+	if len(a) == 0 || len(b) == 0 {
+		return 0, nil
+	}
+	comunes := []string{}
+	for k, nombre := range a {
+		if _, ok := b[k]; ok {
+			comunes = append(comunes, nombre)
+		}
+	}
+	sort.Strings(comunes)
+	union := len(a) + len(b) - len(comunes)
+	return float64(len(comunes)) / float64(union), comunes
+}
+
+// paresPropiedades arma "nombre: valor" de perceptivas y emocionales
+func paresPropiedades(m models.Material) []string {
+	pares := []string{}
+	for _, lista := range []models.JSONGenerales{m.PropiedadesPerceptivas, m.PropiedadesEmocionales} {
+		for _, p := range lista {
+			if strings.TrimSpace(p.Nombre) != "" && strings.TrimSpace(p.Valor) != "" {
+				pares = append(pares, p.Nombre+": "+p.Valor)
+			}
+		}
+	}
+	return pares
+}
+
+// listarComunes resume una lista de nombres para mostrarla en una razón
+func listarComunes(nombres []string) string {
+	if len(nombres) > 3 {
+		return strings.Join(nombres[:3], ", ") + fmt.Sprintf(" y %d más", len(nombres)-3)
+	}
+	return strings.Join(nombres, ", ")
+}
+
+// similitudMecanica compara las propiedades numéricas con el mismo nombre y magnitud.
+// Cada par aporta 1 - |a-b|/max(|a|,|b|); el promedio se pondera por cuántas propiedades del origen se pudieron comparar
+func similitudMecanica(origen, otro models.JSONMecanicas) (float64, string) {
+	comparables := 0
+	suma := 0.0
+	mejor, mejorSim := "", -1.0
+	for _, p := range origen {
+		if p.ValorNormalizado == nil {
+			continue
+		}
+		comparables++
+		q := buscarPropiedad(otro, p.Nombre)
+		if q == nil || q.ValorNormalizado == nil || q.UnidadNormalizada != p.UnidadNormalizada {
+			continue
+		}
+		a, b := *p.ValorNormalizado, *q.ValorNormalizado
+		sim := 1.0
+		if escala := math.Max(math.Abs(a), math.Abs(b)); escala > 0 {
+			sim = math.Max(0, 1-math.Abs(a-b)/escala)
+		}
+		suma += sim
+		if sim > mejorSim {
+			mejorSim = sim
+			mejor = fmt.Sprintf("%s cercana (%s %s vs %s %s)", p.Nombre, p.Valor, p.Unidad, q.Valor, q.Unidad)
+			if u, ok := units.Buscar(p.Unidad); ok {
+				mejor = fmt.Sprintf("%s cercana (%s vs %s %s)", p.Nombre,
+					strconv.FormatFloat(u.DesdeBase(a), 'g', 4, 64),
+					strconv.FormatFloat(u.DesdeBase(b), 'g', 4, 64), u.Simbolo)
+			}
+		}
+	}
+	if comparables == 0 {
+		return 0, ""
+	}
+	return suma / float64(comparables), mejor
+}
+
+// puntuarSimilitud calcula el puntaje de otro respecto al origen y las razones ordenadas por aporte
+func puntuarSimilitud(origen, otro models.Material) (float64, []razonSimilitud) {
+	pesoTotal := 0.0
+	razones := []razonSimilitud{}
+
+	componentes := func(m models.Material) []string {
+		nombres := make([]string, len(m.Composicion))
+		for i, comp := range m.Composicion {
+			nombres[i] = comp.Elemento
+		}
+		return nombres
+	}
+
+	// 1. Composición
+	if compOrigen := conjuntoClaves(componentes(origen)); len(compOrigen) > 0 {
+		pesoTotal += pesoComposicion
+		sim, comunes := jaccard(compOrigen, conjuntoClaves(componentes(otro)))
+		if sim > 0 {
+			razones = append(razones, razonSimilitud{sim * pesoComposicion,
+				fmt.Sprintf("Comparte %d elemento(s) de composición: %s", len(comunes), listarComunes(comunes))})
+		}
+	}
+
+	// 2. Herramientas
+	if hOrigen := conjuntoClaves(origen.Herramientas); len(hOrigen) > 0 {
+		pesoTotal += pesoHerramientas
+		sim, comunes := jaccard(hOrigen, conjuntoClaves(otro.Herramientas))
+		if sim > 0 {
+			razones = append(razones, razonSimilitud{sim * pesoHerramientas,
+				fmt.Sprintf("Usa las mismas herramientas: %s", listarComunes(comunes))})
+		}
+	}
+
+	// 3. Propiedades perceptivas y emocionales (mismo nombre y mismo valor)
+	if pOrigen := conjuntoClaves(paresPropiedades(origen)); len(pOrigen) > 0 {
+		pesoTotal += pesoPropiedades
+		sim, comunes := jaccard(pOrigen, conjuntoClaves(paresPropiedades(otro)))
+		if sim > 0 {
+			razones = append(razones, razonSimilitud{sim * pesoPropiedades,
+				fmt.Sprintf("Propiedades percibidas iguales: %s", listarComunes(comunes))})
+		}
+	}
+
+	// 4. Propiedades mecánicas numéricas
+	tieneMecanicas := false
+	for _, p := range origen.PropiedadesMecanicas {
+		if p.ValorNormalizado != nil {
+			tieneMecanicas = true
+			break
+		}
+	}
+	if tieneMecanicas {
+		pesoTotal += pesoMecanicas
+		sim, mejor := similitudMecanica(origen.PropiedadesMecanicas, otro.PropiedadesMecanicas)
+		if sim > 0 {
+			razones = append(razones, razonSimilitud{sim * pesoMecanicas, mejor})
+		}
+	}
+
+	if pesoTotal == 0 {
+		return 0, nil
+	}
+
+	puntaje := 0.0
+	for _, r := range razones {
+		puntaje += r.aporte
+	}
+	sort.SliceStable(razones, func(i, j int) bool { return razones[i].aporte > razones[j].aporte })
+	return puntaje / pesoTotal, razones
+}
+
+// GetSimilarMaterials recomienda materiales aprobados parecidos por composición, herramientas,
+// propiedades percibidas y cercanía de propiedades mecánicas. ?limit (por defecto 10, máximo 50)
+func GetSimilarMaterials(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	limite := 10
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parámetro 'limit' inválido"})
+			return
+		}
+		limite = min(n, 50)
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	var origen models.Material
+	if err := db.Where("id = ? AND estado = ?", id, models.EstadoAprobado).First(&origen).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material no encontrado o no está aprobado"})
+		return
+	}
+
+	// 1. Candidatos: aprobados que comparten al menos un elemento, herramienta o nombre de propiedad
+	claves := func(set map[string]string) []string {
+		lista := []string{}
+		for k := range set {
+			lista = append(lista, k)
+		}
+		return lista
+	}
+	compOrigen := make([]string, len(origen.Composicion))
+	for i, comp := range origen.Composicion {
+		compOrigen[i] = comp.Elemento
+	}
+	nombresProps := []string{}
+	for _, p := range origen.PropiedadesMecanicas {
+		nombresProps = append(nombresProps, p.Nombre)
+	}
+	for _, p := range append(append(models.JSONGenerales{}, origen.PropiedadesPerceptivas...), origen.PropiedadesEmocionales...) {
+		nombresProps = append(nombresProps, p.Nombre)
+	}
+
+	existe := func(columna, campo string) string {
+		return "EXISTS (SELECT 1 FROM jsonb_array_elements(" + arregloJSON("materials."+columna) + ") AS x " +
+			"WHERE unaccent(lower(TRIM(x->>'" + campo + "'))) IN ?)"
+	}
+	var candidatos []models.Material
+	if err := db.Where("estado = ? AND id <> ?", models.EstadoAprobado, origen.ID).
+		Where(db.Where(existe("composicion", "elemento"), claves(conjuntoClaves(compOrigen))).
+			Or("EXISTS (SELECT 1 FROM jsonb_array_elements_text("+arregloJSON("materials.herramientas")+") AS h WHERE unaccent(lower(TRIM(h))) IN ?)",
+				claves(conjuntoClaves(origen.Herramientas))).
+			Or(existe("propiedades_mecanicas", "nombre"), claves(conjuntoClaves(nombresProps))).
+			Or(existe("propiedades_perceptivas", "nombre"), claves(conjuntoClaves(nombresProps))).
+			Or(existe("propiedades_emocionales", "nombre"), claves(conjuntoClaves(nombresProps)))).
+		Preload("Galeria", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Order("updated_at DESC").
+		Limit(maxCandidatosSimilares).
+		Find(&candidatos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error buscando candidatos: " + err.Error()})
+		return
+	}
+
+	// 2. Puntuar y ordenar
+	similares := []MaterialSimilar{}
+	for _, cand := range candidatos {
+		puntaje, razones := puntuarSimilitud(origen, cand)
+		if puntaje <= 0 {
+			continue
+		}
+		s := MaterialSimilar{
+			ID:      cand.ID,
+			Nombre:  cand.Nombre,
+			Puntaje: math.Round(puntaje*1000) / 1000,
+			Razones: []string{},
+		}
+		if len(cand.Galeria) > 0 {
+			s.PrimeraImagenGaleria = cand.Galeria[0].URLImagen
+		}
+		for i := 0; i < len(razones) && i < maxRazones; i++ {
+			s.Razones = append(s.Razones, razones[i].texto)
+		}
+		similares = append(similares, s)
+	}
+	sort.SliceStable(similares, func(i, j int) bool { return similares[i].Puntaje > similares[j].Puntaje })
+	if len(similares) > limite {
+		similares = similares[:limite]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"material_id": origen.ID,
+		"similares":   similares,
+	})
+}
@@ -0,0 +1,345 @@
+package material
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"TT-SEM-2-BACK/api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// formPaso es un paso tal como llega en el campo "pasos" del form
+type formPaso struct {
+	OrdenPaso   int    `json:"orden_paso"`
+	Descripcion string `json:"descripcion"`
+}
+
+// copiarSnapshot duplica los slices de pasos y galería para poder editarlos sin tocar el original
+func copiarSnapshot(snap models.SnapshotMaterial) models.SnapshotMaterial {
+	copia := snap
+	copia.Pasos = append([]models.SnapshotPaso{}, snap.Pasos...)
+	copia.Galeria = append([]models.SnapshotGaleria{}, snap.Galeria...)
+	return copia
+}
+
+// aplicarCamposForm sobrescribe en el snapshot los campos de texto y JSON que vengan en el form.
+// Devuelve todos los campos inválidos, no solo el primero
+func aplicarCamposForm(c *gin.Context, snap *models.SnapshotMaterial) []ErrorCampo {
+	errores := []ErrorCampo{}
+
+	// Textos Simples
+	if val := c.PostForm("nombre"); val != "" {
+		snap.Nombre = val
+	}
+	if val := c.PostForm("descripcion"); val != "" {
+		snap.Descripcion = val
+	}
+
+	// Herramientas (Array String)
+	if str := c.PostForm("herramientas"); str != "" {
+		var h models.StringArray
+		if err := json.Unmarshal([]byte(str), &h); err != nil {
+			errores = append(errores, ErrorCampo{Campo: "herramientas", Error: "Formato de herramientas inválido"})
+		} else {
+			snap.Herramientas = h
+		}
+	}
+
+	// Composición (JSONComponentes)
+	if str := c.PostForm("composicion"); str != "" {
+		var comp models.JSONComponentes
+		if err := json.Unmarshal([]byte(str), &comp); err != nil {
+			errores = append(errores, ErrorCampo{Campo: "composicion", Error: "JSON Composición inválido"})
+		} else {
+			errores = append(errores, normalizarComposicion(comp)...)
+			snap.Composicion = comp
+		}
+	}
+
+	// Propiedades Mecánicas (JSONMecanicas)
+	if str := c.PostForm("prop_mecanicas"); str != "" {
+		var pm models.JSONMecanicas
+		if err := json.Unmarshal([]byte(str), &pm); err != nil {
+			errores = append(errores, ErrorCampo{Campo: "prop_mecanicas", Error: "JSON Prop. Mecánicas inválido"})
+		} else {
+			snap.PropiedadesMecanicas = pm
+		}
+	}
+
+	// Propiedades Perceptivas (JSONGenerales)
+	if str := c.PostForm("prop_perceptivas"); str != "" {
+		var pp models.JSONGenerales
+		if err := json.Unmarshal([]byte(str), &pp); err != nil {
+			errores = append(errores, ErrorCampo{Campo: "prop_perceptivas", Error: "JSON Prop. Perceptivas inválido"})
+		} else {
+			snap.PropiedadesPerceptivas = pp
+		}
+	}
+
+	// Propiedades Emocionales (JSONGenerales)
+	if str := c.PostForm("prop_emocionales"); str != "" {
+		var pe models.JSONGenerales
+		if err := json.Unmarshal([]byte(str), &pe); err != nil {
+			errores = append(errores, ErrorCampo{Campo: "prop_emocionales", Error: "JSON Prop. Emocionales inválido"})
+		} else {
+			snap.PropiedadesEmocionales = pe
+		}
+	}
+
+	// Derivado De
+	if str := c.PostForm("derivado_de"); str != "" {
+		if uid, err := uuid.Parse(str); err != nil {
+			errores = append(errores, ErrorCampo{Campo: "derivado_de", Error: "UUID de derivado_de inválido"})
+		} else {
+			snap.DerivadoDe = uid
+		}
+	}
+
+	// Categoría ("" no la cambia, "null" la quita). Que exista se comprueba contra la DB en validarCategoria
+	if str := c.PostForm("categoria_id"); str != "" {
+		if str == "null" {
+			snap.CategoriaID = nil
+		} else if uid, err := uuid.Parse(str); err != nil {
+			errores = append(errores, ErrorCampo{Campo: "categoria_id", Error: "UUID de categoria_id inválido"})
+		} else {
+			snap.CategoriaID = &uid
+		}
+	}
+
+	// Etiquetas: arreglo JSON o lista separada por comas. Si el campo viene vacío se quitan todas
+	if _, ok := c.GetPostForm("etiquetas"); ok {
+		etiquetas, err := leerEtiquetas(c.PostForm("etiquetas"))
+		if err != nil {
+			errores = append(errores, ErrorCampo{Campo: "etiquetas", Error: err.Error()})
+		} else {
+			snap.Etiquetas = etiquetas
+		}
+	}
+
+	// Galería y pasos: se validan aquí para no subir archivos si el form viene mal
+	if str := c.PostForm("galeria_captions"); str != "" {
+		var captions []string
+		if err := json.Unmarshal([]byte(str), &captions); err != nil {
+			errores = append(errores, ErrorCampo{Campo: "galeria_captions", Error: "Formato de galeria_captions inválido"})
+		}
+	}
+	if str := c.PostForm("pasos"); str != "" {
+		var pasos []formPaso
+		if err := json.Unmarshal([]byte(str), &pasos); err != nil {
+			errores = append(errores, ErrorCampo{Campo: "pasos", Error: "Formato de pasos inválido"})
+		}
+	}
+
+	return errores
+}
+
+// aplicarArchivosForm aplica galería y pasos del form al snapshot, subiendo los archivos nuevos bajo prefijo.
+// Devuelve los archivos que no se pudieron subir; lo que sí se subió queda registrado en sub
+func aplicarArchivosForm(c *gin.Context, sub *subidas, snap *models.SnapshotMaterial, prefijo string) []ErrorCampo {
+	ctx := c.Request.Context()
+	errores := []ErrorCampo{}
+
+	// 1. Galería
+	var galeriaCaptions []string
+	if str := c.PostForm("galeria_captions"); str != "" {
+		json.Unmarshal([]byte(str), &galeriaCaptions)
+	}
+
+	files := c.Request.MultipartForm.File["galeria_images[]"]
+	if len(files) > 0 {
+		// Si suben nuevas fotos, reemplazamos todo (Estrategia simple)
+		snap.Galeria = []models.SnapshotGaleria{}
+		for i, fileHeader := range files {
+			url, err := sub.subir(ctx, fileHeader, prefijo)
+			if err != nil {
+				errores = append(errores, ErrorCampo{Campo: "galeria_images[]", Archivo: fileHeader.Filename, Error: err.Error()})
+				continue
+			}
+
+			caption := ""
+			if i < len(galeriaCaptions) {
+				caption = galeriaCaptions[i]
+			}
+			snap.Galeria = append(snap.Galeria, models.SnapshotGaleria{URLImagen: url, Caption: caption})
+		}
+	} else {
+		// Si solo actualizan textos de galería existente
+		for i, caption := range galeriaCaptions {
+			if i < len(snap.Galeria) {
+				snap.Galeria[i].Caption = caption
+			}
+		}
+	}
+
+	// 2. Pasos
+	pasosStr := c.PostForm("pasos")
+	if pasosStr == "" {
+		return errores
+	}
+	var newPasos []formPaso
+	if err := json.Unmarshal([]byte(pasosStr), &newPasos); err != nil {
+		return append(errores, ErrorCampo{Campo: "pasos", Error: "Formato de pasos inválido"})
+	}
+
+	// Mapa de pasos existentes
+	existingPasos := make(map[int]models.SnapshotPaso)
+	for _, p := range snap.Pasos {
+		existingPasos[p.OrdenPaso] = p
+	}
+
+	snap.Pasos = []models.SnapshotPaso{}
+	for i, newPaso := range newPasos {
+		paso := models.SnapshotPaso{OrdenPaso: newPaso.OrdenPaso}
+		if exist, ok := existingPasos[newPaso.OrdenPaso]; ok {
+			paso = exist
+		}
+		paso.Descripcion = newPaso.Descripcion
+
+		// Uploads (Imagen/Video) para este paso
+		campoImg := fmt.Sprintf("paso_images[%d]", i)
+		if headers := c.Request.MultipartForm.File[campoImg]; len(headers) > 0 {
+			carpeta := fmt.Sprintf("%spasos/%d/", prefijo, newPaso.OrdenPaso)
+			if url, err := sub.subir(ctx, headers[0], carpeta); err != nil {
+				errores = append(errores, ErrorCampo{Campo: campoImg, Archivo: headers[0].Filename, Error: err.Error()})
+			} else {
+				paso.URLImagen = url
+			}
+		}
+		campoVid := fmt.Sprintf("paso_videos[%d]", i)
+		if headers := c.Request.MultipartForm.File[campoVid]; len(headers) > 0 {
+			carpeta := fmt.Sprintf("%spasos/%d/", prefijo, newPaso.OrdenPaso)
+			if url, err := sub.subir(ctx, headers[0], carpeta); err != nil {
+				errores = append(errores, ErrorCampo{Campo: campoVid, Archivo: headers[0].Filename, Error: err.Error()})
+			} else {
+				paso.URLVideo = url
+			}
+		}
+
+		snap.Pasos = append(snap.Pasos, paso)
+	}
+
+	return errores
+}
+
+// aplicarSnapshot escribe el snapshot en la versión pública del material (fila, pasos y galería).
+// El material debe venir con Pasos y Galeria precargados. No toca el estado
+func aplicarSnapshot(tx *gorm.DB, material *models.Material, snap models.SnapshotMaterial) error {
+	material.Nombre = snap.Nombre
+	material.Descripcion = snap.Descripcion
+	material.DerivadoDe = snap.DerivadoDe
+	material.Composicion = snap.Composicion
+	normalizarComposicion(material.Composicion) // Igual que las mecánicas: las revisiones antiguas no traen las cantidades
+	material.PropiedadesMecanicas = snap.PropiedadesMecanicas
+	normalizarMecanicas(material.PropiedadesMecanicas) // Las revisiones antiguas no traen los valores normalizados
+	material.PropiedadesPerceptivas = snap.PropiedadesPerceptivas
+	material.PropiedadesEmocionales = snap.PropiedadesEmocionales
+	material.Herramientas = snap.Herramientas
+	material.CategoriaID = snap.CategoriaID
+	if material.CategoriaID != nil && len(validarCategoria(tx, snap)) > 0 {
+		material.CategoriaID = nil // La categoría se borró después de guardar la revisión
+	}
+	material.Etiquetas = snap.Etiquetas
+
+	if err := tx.Omit(clause.Associations, "estado").Save(material).Error; err != nil {
+		return fmt.Errorf("error guardando material: %w", err)
+	}
+
+	// 1. Pasos: se sincronizan por número de orden
+	existentes := make(map[int]models.PasoMaterial)
+	for _, p := range material.Pasos {
+		existentes[p.OrdenPaso] = p
+	}
+	vistos := make(map[int]bool)
+	for _, p := range snap.Pasos {
+		vistos[p.OrdenPaso] = true
+		paso, ok := existentes[p.OrdenPaso]
+		if !ok {
+			paso = models.PasoMaterial{MaterialID: material.ID, OrdenPaso: p.OrdenPaso}
+		}
+		paso.Descripcion = p.Descripcion
+		paso.URLImagen = p.URLImagen
+		paso.URLVideo = p.URLVideo
+
+		if err := tx.Save(&paso).Error; err != nil {
+			return fmt.Errorf("error guardando paso %d: %w", p.OrdenPaso, err)
+		}
+	}
+	for orden, paso := range existentes {
+		if !vistos[orden] {
+			if err := tx.Delete(&paso).Error; err != nil {
+				return fmt.Errorf("error eliminando paso %d: %w", orden, err)
+			}
+		}
+	}
+
+	// 2. Galería: si son las mismas imágenes solo cambian los textos, si no se reemplaza entera
+	if mismasImagenes(material.Galeria, snap.Galeria) {
+		for i := range material.Galeria {
+			if material.Galeria[i].Caption == snap.Galeria[i].Caption {
+				continue
+			}
+			material.Galeria[i].Caption = snap.Galeria[i].Caption
+			if err := tx.Save(&material.Galeria[i]).Error; err != nil {
+				return fmt.Errorf("error guardando galería: %w", err)
+			}
+		}
+		return nil
+	}
+
+	if err := tx.Where("material_id = ?", material.ID).Delete(&models.GaleriaMaterial{}).Error; err != nil {
+		return fmt.Errorf("error limpiando galería: %w", err)
+	}
+	for _, g := range snap.Galeria {
+		if err := tx.Create(&models.GaleriaMaterial{
+			MaterialID: material.ID,
+			URLImagen:  g.URLImagen,
+			Caption:    g.Caption,
+		}).Error; err != nil {
+			return fmt.Errorf("error guardando galería: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// mismasImagenes indica si la galería actual y la del snapshot tienen las mismas imágenes en el mismo orden
+func mismasImagenes(actual []models.GaleriaMaterial, nueva []models.SnapshotGaleria) bool {
+	if len(actual) != len(nueva) {
+		return false
+	}
+	for i := range actual {
+		if actual[i].URLImagen != nueva[i].URLImagen {
+			return false
+		}
+	}
+	return true
+}
+
+// urlsQuitadas devuelve las URLs de antes que ya no aparecen en despues
+func urlsQuitadas(antes, despues models.SnapshotMaterial) []string {
+	siguen := make(map[string]bool)
+	for _, u := range despues.URLs() {
+		siguen[u] = true
+	}
+	var quitadas []string
+	for _, u := range antes.URLs() {
+		if !siguen[u] {
+			quitadas = append(quitadas, u)
+		}
+	}
+	return quitadas
+}
+
+// cargarMaterialEditable carga el material con pasos y galería ordenados, listo para snapshot
+func cargarMaterialEditable(db *gorm.DB, id uuid.UUID) (models.Material, error) {
+	var material models.Material
+	err := db.
+		Preload("Pasos", func(db *gorm.DB) *gorm.DB { return db.Order("orden_paso ASC") }).
+		Preload("Galeria", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		First(&material, "id = ?", id).Error
+	return material, err
+}
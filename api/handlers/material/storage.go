@@ -0,0 +1,250 @@
+package material
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"TT-SEM-2-BACK/api/database"
+	"TT-SEM-2-BACK/api/middleware"
+	"TT-SEM-2-BACK/api/models"
+	"TT-SEM-2-BACK/api/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// prefijoMateriales es la carpeta del bucket donde viven todos los archivos de materiales
+const prefijoMateriales = "materials/"
+
+// ReporteLimpieza resume una reconciliación del bucket contra la base de datos
+type ReporteLimpieza struct {
+	DryRun            bool                 `json:"dry_run"`
+	Prefijo           string               `json:"prefijo"`
+	Revisados         int                  `json:"revisados"`
+	Referenciados     int                  `json:"referenciados"`
+	RecientesOmitidos int                  `json:"recientes_omitidos"`
+	Huerfanos         []storage.ObjectInfo `json:"huerfanos"`
+	Eliminados        int                  `json:"eliminados"`
+	Errores           []string             `json:"errores,omitempty"`
+}
+
+// prefijoMaterial devuelve la carpeta del bucket de un material
+func prefijoMaterial(materialID uuid.UUID) string {
+	return fmt.Sprintf("%s%s/", prefijoMateriales, materialID.String())
+}
+
+// urlsArchivosMaterial junta las URLs de galería y pasos de un material
+func urlsArchivosMaterial(galeria []models.GaleriaMaterial, pasos []models.PasoMaterial) []string {
+	var urls []string
+	for _, g := range galeria {
+		urls = append(urls, g.URLImagen)
+	}
+	for _, p := range pasos {
+		urls = append(urls, p.URLImagen, p.URLVideo)
+	}
+	return urls
+}
+
+// urlsReferenciadas devuelve, de las URLs dadas (o de todas si urls es nil), las que alguna fila
+// o revisión sigue usando. Las revisiones rechazadas o reemplazadas no cuentan: sus archivos se descartan
+func urlsReferenciadas(db *gorm.DB, urls []string) (map[string]bool, error) {
+	referenciadas := make(map[string]bool)
+
+	consultas := []struct {
+		model   interface{}
+		columna string
+	}{
+		{&models.GaleriaMaterial{}, "url_imagen"},
+		{&models.PasoMaterial{}, "url_imagen"},
+		{&models.PasoMaterial{}, "url_video"},
+	}
+
+	for _, q := range consultas {
+		var encontradas []string
+		query := db.Model(q.model).Where(q.columna + " <> ''")
+		if urls != nil {
+			query = query.Where(q.columna+" IN ?", urls)
+		}
+		if err := query.Distinct().Pluck(q.columna, &encontradas).Error; err != nil {
+			return nil, fmt.Errorf("error buscando archivos referenciados: %w", err)
+		}
+		for _, u := range encontradas {
+			referenciadas[u] = true
+		}
+	}
+
+	// Las revisiones guardan sus archivos dentro del snapshot JSONB
+	var enRevisiones []string
+	descartadas := []models.EstadoRevision{models.RevisionRechazada, models.RevisionReemplazada}
+	query := db.Table("(?) AS r", db.Raw(`
+		SELECT g->>'url_imagen' AS url
+		FROM material_revisiones, jsonb_array_elements(`+arregloJSON("contenido->'galeria'")+`) AS g
+		WHERE estado NOT IN ?
+		UNION
+		SELECT p->>'url_imagen' FROM material_revisiones, jsonb_array_elements(`+arregloJSON("contenido->'pasos'")+`) AS p
+		WHERE estado NOT IN ?
+		UNION
+		SELECT p->>'url_video' FROM material_revisiones, jsonb_array_elements(`+arregloJSON("contenido->'pasos'")+`) AS p
+		WHERE estado NOT IN ?
+	`, descartadas, descartadas, descartadas)).Where("url <> ''")
+	if urls != nil {
+		query = query.Where("url IN ?", urls)
+	}
+	if err := query.Pluck("url", &enRevisiones).Error; err != nil {
+		return nil, fmt.Errorf("error buscando archivos de revisiones: %w", err)
+	}
+	for _, u := range enRevisiones {
+		referenciadas[u] = true
+	}
+
+	return referenciadas, nil
+}
+
+// eliminarArchivosSinUso borra del storage las URLs dadas que ya no referencia ninguna fila.
+// Los errores solo se registran: la limpieza nunca debe hacer fallar la operación principal
+func eliminarArchivosSinUso(ctx context.Context, db *gorm.DB, store storage.Storage, urls []string) {
+	var candidatas []string
+	for _, u := range urls {
+		if u != "" {
+			candidatas = append(candidatas, u)
+		}
+	}
+	if len(candidatas) == 0 {
+		return
+	}
+
+	referenciadas, err := urlsReferenciadas(db, candidatas)
+	if err != nil {
+		log.Printf("⚠️ No se pudo verificar archivos antes de borrarlos: %v", err)
+		return
+	}
+
+	for _, u := range candidatas {
+		if referenciadas[u] {
+			continue
+		}
+		path, ok := store.ObjectPath(storage.BucketPasos, u)
+		if !ok || !strings.HasPrefix(path, prefijoMateriales) {
+			continue
+		}
+		if err := store.Delete(ctx, storage.BucketPasos, path); err != nil {
+			log.Printf("⚠️ Error eliminando archivo %s: %v", path, err)
+			continue
+		}
+		log.Printf("🗑️ Archivo eliminado del storage: %s", path)
+	}
+}
+
+// reconciliarStorage lista los objetos bajo prefijo y elimina los que ninguna fila referencia.
+// Los objetos más nuevos que minAge se omiten para no borrar subidas en curso
+func reconciliarStorage(ctx context.Context, db *gorm.DB, store storage.Storage, prefijo string, minAge time.Duration, dryRun bool) (ReporteLimpieza, error) {
+	reporte := ReporteLimpieza{
+		DryRun:    dryRun,
+		Prefijo:   prefijo,
+		Huerfanos: []storage.ObjectInfo{},
+	}
+
+	objetos, err := store.List(ctx, storage.BucketPasos, prefijo)
+	if err != nil {
+		return reporte, err
+	}
+	reporte.Revisados = len(objetos)
+
+	referenciadas, err := urlsReferenciadas(db, nil)
+	if err != nil {
+		return reporte, err
+	}
+
+	rutasEnUso := make(map[string]bool)
+	for u := range referenciadas {
+		if path, ok := store.ObjectPath(storage.BucketPasos, u); ok {
+			rutasEnUso[path] = true
+		}
+	}
+
+	limite := time.Now().Add(-minAge)
+	for _, obj := range objetos {
+		if rutasEnUso[obj.Path] {
+			reporte.Referenciados++
+			continue
+		}
+		if minAge > 0 && obj.UpdatedAt.After(limite) {
+			reporte.RecientesOmitidos++
+			continue
+		}
+
+		reporte.Huerfanos = append(reporte.Huerfanos, obj)
+		if dryRun {
+			continue
+		}
+
+		if err := store.Delete(ctx, storage.BucketPasos, obj.Path); err != nil {
+			reporte.Errores = append(reporte.Errores, fmt.Sprintf("%s: %v", obj.Path, err))
+			continue
+		}
+		reporte.Eliminados++
+	}
+
+	return reporte, nil
+}
+
+// ReconcileStorage elimina (o solo reporta con dry_run) los archivos huérfanos del bucket - Solo Admin
+func ReconcileStorage(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	store, err := storage.GetStorage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error configurando el storage: " + err.Error()})
+		return
+	}
+
+	// Por seguridad el modo por defecto es solo reportar
+	dryRun := true
+	if val := c.Query("dry_run"); val != "" {
+		parsed, err := strconv.ParseBool(val)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run debe ser true o false"})
+			return
+		}
+		dryRun = parsed
+	}
+
+	// Solo se permite reconciliar dentro de materials/
+	prefijo := strings.TrimPrefix(c.DefaultQuery("prefijo", prefijoMateriales), "/")
+	if !strings.HasPrefix(prefijo, prefijoMateriales) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El prefijo debe estar dentro de '" + prefijoMateriales + "'"})
+		return
+	}
+
+	minAgeHoras := 1.0
+	if val := c.Query("min_age_horas"); val != "" {
+		parsed, err := strconv.ParseFloat(val, 64)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_age_horas inválido"})
+			return
+		}
+		minAgeHoras = parsed
+	}
+
+	reporte, err := reconciliarStorage(c.Request.Context(), db, store, prefijo, time.Duration(minAgeHoras*float64(time.Hour)), dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reconciliando storage: " + err.Error()})
+		return
+	}
+
+	adminGoogleID, _ := middleware.GetUserGoogleID(c)
+	log.Printf("🧹 Reconciliación de storage (dry_run=%t) por admin %s: %d revisados, %d huérfanos, %d eliminados",
+		dryRun, adminGoogleID, reporte.Revisados, len(reporte.Huerfanos), reporte.Eliminados)
+
+	c.JSON(http.StatusOK, reporte)
+}
@@ -0,0 +1,61 @@
+package material
+
+import (
+	"context"
+	"log"
+	"mime/multipart"
+
+	"TT-SEM-2-BACK/api/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ErrorCampo indica qué campo o archivo del form impidió guardar el material
+type ErrorCampo struct {
+	Campo   string `json:"campo"`
+	Archivo string `json:"archivo,omitempty"`
+	Error   string `json:"error"`
+}
+
+// responderErroresCampos responde con el detalle de todos los campos/archivos que fallaron
+func responderErroresCampos(c *gin.Context, status int, mensaje string, errores []ErrorCampo) {
+	c.JSON(status, gin.H{
+		"error":    mensaje,
+		"detalles": errores,
+	})
+}
+
+// subidas registra los archivos subidos en una petición para borrarlos si el guardado no se completa
+type subidas struct {
+	store storage.Storage
+	rutas []string
+}
+
+func nuevasSubidas(store storage.Storage) *subidas {
+	return &subidas{store: store}
+}
+
+// subir sube el archivo dentro de carpeta y recuerda su ruta para poder compensar. Cada subida lleva un
+// nombre único: si se reutilizara el nombre original, un archivo con el mismo nombre pisaría al que ya
+// apunta la DB (o una revisión anterior) y compensar lo borraría
+func (s *subidas) subir(ctx context.Context, fileHeader *multipart.FileHeader, carpeta string) (string, error) {
+	path := carpeta + uuid.NewString() + "_" + storage.SafeFilename(fileHeader.Filename)
+	url, err := storage.UploadFile(ctx, s.store, fileHeader, storage.BucketPasos, path)
+	if err != nil {
+		return "", err
+	}
+	s.rutas = append(s.rutas, path)
+	return url, nil
+}
+
+// compensar borra solo lo que subió esta petición. Usa un contexto propio para que se ejecute aunque la petición se haya cancelado
+func (s *subidas) compensar() {
+	ctx := context.Background()
+	for _, path := range s.rutas {
+		if err := s.store.Delete(ctx, storage.BucketPasos, path); err != nil {
+			log.Printf("⚠️ Error borrando archivo huérfano %s: %v", path, err)
+		}
+	}
+	s.rutas = nil
+}
@@ -1,22 +1,23 @@
 package material
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"sort"
-	"strings"
 
 	"TT-SEM-2-BACK/api/database"
 	"TT-SEM-2-BACK/api/middleware"
 	"TT-SEM-2-BACK/api/models"
+	"TT-SEM-2-BACK/api/storage"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-// UpdateMaterial maneja la actualización de un material
+// UpdateMaterial maneja la actualización de un material.
+// Si el material ya está aprobado los cambios quedan como revisión pendiente y la versión pública no cambia
 func UpdateMaterial(c *gin.Context) {
 	// 1. Obtener usuario autenticado
 	googleID, exists := middleware.GetUserGoogleID(c)
@@ -31,6 +32,12 @@ func UpdateMaterial(c *gin.Context) {
 		return
 	}
 
+	store, err := storage.GetStorage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error configurando el storage: " + err.Error()})
+		return
+	}
+
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
@@ -39,20 +46,29 @@ func UpdateMaterial(c *gin.Context) {
 	}
 
 	// 2. Verificar existencia y permisos
-	var material models.Material
 	// Cargamos Pasos y Galeria para poder editarlos.
-	if err := db.Preload("Pasos").Preload("Galeria").First(&material, "id = ?", id).Error; err != nil {
+	material, err := cargarMaterialEditable(db, id)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Material no encontrado"})
 		return
 	}
 
-	isAdmin := middleware.IsAdmin(c)
-	isOwner := material.CreadorID == googleID
-
-	if !isAdmin && !isOwner {
+	// El propietario y los editores editan; los lectores solo ven
+	rol := rolEnMaterial(db, material, googleID, middleware.IsAdmin(c))
+	if !rol.Incluye(models.RolEditor) {
 		c.JSON(http.StatusForbidden, gin.H{
 			"error":  "No tienes permiso",
-			"detail": "Solo puedes editar tus propios materiales",
+			"detail": "Solo el propietario y los editores del material pueden editarlo",
+			"rol":    rol,
+		})
+		return
+	}
+
+	if material.Estado == models.EstadoArchivado {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":         "El material está archivado",
+			"detail":        "Desarchívalo antes de editarlo",
+			"estado_actual": material.Estado,
 		})
 		return
 	}
@@ -63,223 +79,189 @@ func UpdateMaterial(c *gin.Context) {
 		return
 	}
 
-	// 4. Actualizar Campos de Texto y JSONs
-
-	// Textos Simples
-	if val := c.PostForm("nombre"); val != "" {
-		material.Nombre = val
-	}
-	if val := c.PostForm("descripcion"); val != "" {
-		material.Descripcion = val
-	}
-
-	// Herramientas (Array String)
-	if str := c.PostForm("herramientas"); str != "" {
-		var h models.StringArray
-		if err := json.Unmarshal([]byte(str), &h); err == nil {
-			material.Herramientas = h
-		}
-	}
-
-	// Composición (JSONComponentes)
-	if str := c.PostForm("composicion"); str != "" {
-		var comp models.JSONComponentes
-		if err := json.Unmarshal([]byte(str), &comp); err == nil {
-			material.Composicion = comp
-		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "JSON Composición inválido"})
+	// 4. Punto de partida: la versión pública o, si es un material aprobado con
+	// cambios en revisión, la última revisión pendiente (las ediciones se acumulan)
+	comoRevision := material.Estado == models.EstadoAprobado
+	base := models.SnapshotDe(material)
+	prefijo := prefijoMaterial(material.ID)
+	numeroRevision := 0
+
+	if comoRevision {
+		pendiente, err := buscarRevisionPendiente(db, material.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error buscando revisión pendiente: " + err.Error()})
 			return
 		}
-	}
-
-	// Propiedades Mecánicas (JSONMecanicas)
-	if str := c.PostForm("prop_mecanicas"); str != "" {
-		var pm models.JSONMecanicas
-		if err := json.Unmarshal([]byte(str), &pm); err == nil {
-			material.PropiedadesMecanicas = pm
-		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "JSON Prop. Mecánicas inválido"})
-			return
+		if pendiente != nil {
+			base = pendiente.Contenido
 		}
-	}
 
-	// Propiedades Perceptivas (JSONGenerales)
-	if str := c.PostForm("prop_perceptivas"); str != "" {
-		var pp models.JSONGenerales
-		if err := json.Unmarshal([]byte(str), &pp); err == nil {
-			material.PropiedadesPerceptivas = pp
-		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "JSON Prop. Perceptivas inválido"})
+		numeroRevision, err = siguienteNumeroRevision(db, material.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error numerando revisión: " + err.Error()})
 			return
 		}
+		prefijo = prefijoRevision(material.ID, numeroRevision)
 	}
 
-	// Propiedades Emocionales (Nuevo Tipo: JSONGenerales)
-	if str := c.PostForm("prop_emocionales"); str != "" {
-		var pe models.JSONGenerales
-		if err := json.Unmarshal([]byte(str), &pe); err == nil {
-			material.PropiedadesEmocionales = pe
+	// 5. Validar Campos de Texto, JSONs y Colaboradores antes de subir nada
+	snap := copiarSnapshot(base)
+	errores := aplicarCamposForm(c, &snap)
+	advertencias := normalizarMecanicas(snap.PropiedadesMecanicas)
+	avisosVocabulario := revisarVocabulario(db, &snap)
+	errores = append(errores, validarIngredientes(db, snap.Composicion)...)
+	errores = append(errores, validarCategoria(db, snap)...)
+
+	var colaboradores []colaboradorPedido
+	colaboradoresStr := c.PostForm("colaboradores")
+	if colaboradoresStr != "" {
+		if !rol.Incluye(models.RolPropietario) {
+			errores = append(errores, ErrorCampo{Campo: "colaboradores", Error: "Solo el propietario del material puede gestionar sus colaboradores"})
+		} else if pedidos, errCampo := leerColaboradores("colaboradores", colaboradoresStr); errCampo != nil {
+			errores = append(errores, *errCampo)
 		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "JSON Prop. Emocionales inválido"})
-			return
+			colaboradores = pedidos
 		}
 	}
 
-	// Derivado De
-	if str := c.PostForm("derivado_de"); str != "" {
-		if uid, err := uuid.Parse(str); err == nil {
-			material.DerivadoDe = uid
-		}
+	if len(errores) > 0 {
+		responderErroresCampos(c, http.StatusBadRequest, "Datos del material inválidos", errores)
+		return
 	}
 
-	// Resetear estado al editar
-	material.Estado = false
-
-	// Guardar Cambios en Material (Actualiza columnas JSON automáticamente)
-	if err := db.Save(&material).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando actualización: " + err.Error()})
+	// 6. Subir Galería y Pasos. Si alguno falla se borra lo ya subido y no se guarda nada
+	sub := nuevasSubidas(store)
+	if errores := aplicarArchivosForm(c, sub, &snap, prefijo); len(errores) > 0 {
+		sub.compensar()
+		responderErroresCampos(c, http.StatusInternalServerError, "Error subiendo archivos, no se guardaron los cambios", errores)
 		return
 	}
 
-	// 5. Actualizar Colaboradores (Manual y Seguro)
-	colaboradoresStr := c.PostForm("colaboradores")
-	if colaboradoresStr != "" {
-		// A. Borrar anteriores usando nombre de tabla explícito
-		if err := db.Table("material_colaboradores").Where("material_id = ?", material.ID).Delete(nil).Error; err != nil {
-			log.Printf("⚠️ Error limpiando colaboradores antiguos: %v", err)
+	// Los derivados muestran en qué se diferencian ya de su padre
+	diferencias, err := diffConPadre(db, snap, googleID, middleware.IsAdmin(c))
+	if err != nil {
+		log.Printf("⚠️ Error comparando %s con su padre: %v", material.ID, err)
+	}
+
+	// guardarColaboradoresSiVienen sincroniza las invitaciones solo si el form trae colaboradores.
+	// No forman parte del contenido revisable, así que se aplican incluso como revisión
+	guardarColaboradoresSiVienen := func(tx *gorm.DB) error {
+		if colaboradoresStr == "" {
+			return nil
 		}
+		return sincronizarInvitaciones(tx, material, googleID, colaboradores)
+	}
 
-		// B. Insertar nuevos
-		var emails []string
-		if err := json.Unmarshal([]byte(colaboradoresStr), &emails); err == nil && len(emails) > 0 {
-			var usuarios []models.Usuario
-			db.Where("email IN ?", emails).Find(&usuarios)
-
-			for _, u := range usuarios {
-				link := models.ColaboradorMaterial{
-					MaterialID: material.ID,
-					UsuarioID:  u.GoogleID,
-				}
-				// Insertar uno por uno
-				if err := db.Create(&link).Error; err != nil {
-					log.Printf("⚠️ Error agregando colaborador %s: %v", u.Email, err)
-				}
+	// 7a. Material publicado: guardar como revisión pendiente
+	if comoRevision {
+		var rev models.RevisionMaterial
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := guardarColaboradoresSiVienen(tx); err != nil {
+				return err
 			}
+			var err error
+			rev, err = guardarRevisionPendiente(tx, material.ID, numeroRevision, googleID, snap, nil)
+			return err
+		}); err != nil {
+			sub.compensar()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando revisión: " + err.Error()})
+			return
 		}
-	}
 
-	// 6. Actualizar Galería
-	galeriaCaptionsStr := c.PostForm("galeria_captions")
-	var galeriaCaptions []string
-	if galeriaCaptionsStr != "" {
-		json.Unmarshal([]byte(galeriaCaptionsStr), &galeriaCaptions)
+		// Si reemplazó a otra revisión pendiente, los archivos que solo usaba esa se descartan
+		eliminarArchivosSinUso(c.Request.Context(), db, store, urlsQuitadas(base, snap))
+
+		// La revisión registra qué colaborador hizo el cambio; los avisos también lo nombran
+		var editor models.Usuario
+		db.Where("google_id = ?", googleID).First(&editor)
+		notificarUpdate(material.ID, material.Nombre, editor.Nombre)
+		notificarEdicionAlPropietario(material, editor)
+
+		db.Preload("Creador").Preload("Colaboradores").Preload("Galeria").Preload("Pasos").Find(&material)
+		invitaciones, _ := ultimasInvitaciones(db, material.ID)
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":               "Cambios enviados a revisión. La versión publicada se mantiene hasta que un administrador los apruebe",
+			"material":              material,
+			"revision":              rev,
+			"advertencias":          advertencias,
+			"vocabulario":           avisosVocabulario,
+			"diferencias_con_padre": diferencias,
+			"invitaciones":          invitaciones,
+		})
+		return
 	}
 
-	files := c.Request.MultipartForm.File["galeria_images[]"]
-	if len(files) > 0 {
-		// Si suben nuevas fotos, reemplazamos todo (Estrategia simple)
-		db.Where("material_id = ?", material.ID).Delete(&models.GaleriaMaterial{})
-
-		for i, fileHeader := range files {
-			safeFilename := strings.ReplaceAll(fileHeader.Filename, " ", "_")
-			filePath := fmt.Sprintf("materials/%s/%s", material.ID.String(), safeFilename)
-
-			url, err := database.SubirAStorageSupabase(fileHeader, "pasos-bucket", filePath)
-			if err != nil {
-				continue
-			}
-
-			caption := ""
-			if i < len(galeriaCaptions) {
-				caption = galeriaCaptions[i]
-			}
+	// 7b. Material no publicado: los cambios y el cambio de estado se aplican en una sola transacción
+	destino := material.Estado
+	switch {
+	case enviarARevision(c):
+		destino = models.EstadoPendiente
+	case material.Estado == models.EstadoRechazado:
+		// El autor lo está corrigiendo: queda como borrador hasta que lo reenvíe
+		destino = models.EstadoBorrador
+	}
 
-			db.Create(&models.GaleriaMaterial{
-				MaterialID: material.ID,
-				URLImagen:  url,
-				Caption:    caption,
-			})
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := aplicarSnapshot(tx, &material, snap); err != nil {
+			return err
 		}
-	} else if len(galeriaCaptions) > 0 {
-		// Si solo actualizan textos de galería existente
-		sort.Slice(material.Galeria, func(i, j int) bool {
-			return material.Galeria[i].ID < material.Galeria[j].ID // Asume ID autoincremental o ordenable
-		})
-		for i, caption := range galeriaCaptions {
-			if i < len(material.Galeria) {
-				material.Galeria[i].Caption = caption
-				db.Save(&material.Galeria[i])
-			}
+		if err := guardarColaboradoresSiVienen(tx); err != nil {
+			return err
+		}
+		if _, err := registrarRevisionAplicada(tx, material.ID, snap, googleID, nil); err != nil {
+			return err
+		}
+		if destino != material.Estado {
+			return cambiarEstado(tx, &material, destino, googleID, "")
+		}
+		return nil
+	}); err != nil {
+		sub.compensar()
+		if errors.Is(err, models.ErrTransicionInvalida) || errors.Is(err, errEstadoCambiado) {
+			responderErrorEstado(c, material, err)
+			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando actualización: " + err.Error()})
+		return
 	}
 
-	// 7. Actualizar Pasos
-	pasosStr := c.PostForm("pasos")
-	if pasosStr != "" {
-		var newPasos []struct {
-			OrdenPaso   int    `json:"orden_paso"`
-			Descripcion string `json:"descripcion"`
-		}
-		if err := json.Unmarshal([]byte(pasosStr), &newPasos); err == nil {
-			// Mapa de pasos existentes
-			existingPasos := make(map[int]models.PasoMaterial)
-			for _, p := range material.Pasos {
-				existingPasos[p.OrdenPaso] = p
-			}
+	// 8. Borrar del storage los archivos que ya no se usan
+	eliminarArchivosSinUso(c.Request.Context(), db, store, urlsQuitadas(base, snap))
 
-			for i, newPaso := range newPasos {
-				var pasoModel models.PasoMaterial
-				if exist, ok := existingPasos[newPaso.OrdenPaso]; ok {
-					pasoModel = exist
-					pasoModel.Descripcion = newPaso.Descripcion
-				} else {
-					pasoModel = models.PasoMaterial{
-						MaterialID:  material.ID,
-						OrdenPaso:   newPaso.OrdenPaso,
-						Descripcion: newPaso.Descripcion,
-					}
-				}
-
-				// Uploads (Imagen/Video) para este paso
-				fileKeyImg := fmt.Sprintf("paso_images[%d]", i)
-				if headers := c.Request.MultipartForm.File[fileKeyImg]; len(headers) > 0 {
-					safeName := strings.ReplaceAll(headers[0].Filename, " ", "_")
-					path := fmt.Sprintf("materials/%s/pasos/%d/%s", material.ID.String(), newPaso.OrdenPaso, safeName)
-					if url, err := database.SubirAStorageSupabase(headers[0], "pasos-bucket", path); err == nil {
-						pasoModel.URLImagen = url
-					}
-				}
-				// (Repetir lógica para video si es necesario...)
-
-				if pasoModel.ID == 0 {
-					db.Create(&pasoModel)
-				} else {
-					db.Save(&pasoModel)
-				}
-			}
+	// 9. Respuesta Final
+	db.Preload("Creador").Preload("Colaboradores").Preload("Galeria").Preload("Pasos").Find(&material)
 
-			// Limpiar pasos viejos
-			newOrdens := make(map[int]bool)
-			for _, np := range newPasos {
-				newOrdens[np.OrdenPaso] = true
-			}
-			for orden, exist := range existingPasos {
-				if !newOrdens[orden] {
-					db.Delete(&exist)
-				}
-			}
-		}
+	// Notificar a los admins solo si quedó en la cola de revisión
+	var editor models.Usuario
+	db.Where("google_id = ?", googleID).First(&editor)
+	if material.Estado == models.EstadoPendiente {
+		notificarUpdate(material.ID, material.Nombre, editor.Nombre)
 	}
+	notificarEdicionAlPropietario(material, editor)
 
-	// 8. Respuesta Final
-	db.Preload("Creador").Preload("Colaboradores").Preload("Galeria").Preload("Pasos").Find(&material)
+	invitaciones, _ := ultimasInvitaciones(db, material.ID)
 
-	// Notificar
-	var creador models.Usuario
-	db.Where("google_id = ?", material.CreadorID).First(&creador)
-	notificarUpdate(material.ID, material.Nombre, creador.Nombre)
+	c.JSON(http.StatusOK, materialConAdvertencias{Material: material, Advertencias: advertencias, Vocabulario: avisosVocabulario, DiferenciasConPadre: diferencias, Invitaciones: invitaciones})
+}
 
-	c.JSON(http.StatusOK, material)
+// notificarEdicionAlPropietario avisa al propietario cuando un editor cambia su material
+func notificarEdicionAlPropietario(material models.Material, editor models.Usuario) {
+	if editor.GoogleID == "" || editor.GoogleID == material.CreadorID {
+		return
+	}
+	go func() {
+		db, _ := database.GetDB()
+		matID := material.ID
+		db.Create(&models.Notificacion{
+			UsuarioID:  material.CreadorID,
+			MaterialID: &matID,
+			Titulo:     "Material editado por un colaborador",
+			Mensaje:    fmt.Sprintf("%s editó tu material '%s'.", editor.Nombre, material.Nombre),
+			Tipo:       "info",
+			Link:       "/materials/" + matID.String() + "/revisions",
+		})
+	}()
 }
 
 // Función auxiliar para notificaciones
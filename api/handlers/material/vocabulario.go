@@ -0,0 +1,663 @@
+package material
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"TT-SEM-2-BACK/api/database"
+	"TT-SEM-2-BACK/api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const maxSugerencias = 3
+
+// vocabulario indexa los términos por tipo y por la clave (sin mayúsculas ni acentos) de su nombre
+// canónico y de cada sinónimo
+type vocabulario struct {
+	terminos map[models.TipoTermino][]models.Termino
+	claves   map[models.TipoTermino]map[string]*models.Termino
+}
+
+// cargarVocabulario lee el catálogo completo. Es pequeño, así que se carga en cada petición que lo usa
+func cargarVocabulario(db *gorm.DB) (vocabulario, error) {
+	var terminos []models.Termino
+	if err := db.Order("nombre ASC").Find(&terminos).Error; err != nil {
+		return vocabulario{}, err
+	}
+
+	v := vocabulario{
+		terminos: make(map[models.TipoTermino][]models.Termino),
+		claves:   make(map[models.TipoTermino]map[string]*models.Termino),
+	}
+	for _, t := range terminos {
+		v.terminos[t.Tipo] = append(v.terminos[t.Tipo], t)
+	}
+	for tipo, lista := range v.terminos {
+		v.claves[tipo] = make(map[string]*models.Termino)
+		for i := range lista {
+			for _, k := range clavesTermino(lista[i]) {
+				v.claves[tipo][k] = &lista[i]
+			}
+		}
+	}
+	return v, nil
+}
+
+// clavesTermino devuelve las claves del nombre canónico y de los sinónimos
+func clavesTermino(t models.Termino) []string {
+	claves := []string{claveProp(t.Nombre)}
+	for _, s := range t.Sinonimos {
+		if k := claveProp(s); k != "" {
+			claves = append(claves, k)
+		}
+	}
+	return claves
+}
+
+// buscar devuelve el término al que corresponde un valor escrito por el autor
+func (v vocabulario) buscar(tipo models.TipoTermino, valor string) *models.Termino {
+	return v.claves[tipo][claveProp(valor)]
+}
+
+// canonico devuelve el nombre canónico del valor, o el valor tal cual si no está en el catálogo
+func (v vocabulario) canonico(tipo models.TipoTermino, valor string) string {
+	if t := v.buscar(tipo, valor); t != nil {
+		return t.Nombre
+	}
+	return valor
+}
+
+// variantes devuelve todas las claves que se consideran el mismo término que valor
+func (v vocabulario) variantes(tipo models.TipoTermino, valor string) []string {
+	if t := v.buscar(tipo, valor); t != nil {
+		return clavesTermino(*t)
+	}
+	return []string{claveProp(valor)}
+}
+
+// sugerir busca los términos más parecidos a un valor que no está en el catálogo: los que lo
+// contienen (o están contenidos en él) y los que están a pocas letras de distancia
+func (v vocabulario) sugerir(tipo models.TipoTermino, valor string) []string {
+	clave := claveProp(valor)
+	if clave == "" {
+		return nil
+	}
+
+	type candidato struct {
+		nombre    string
+		distancia int
+	}
+	mejores := make(map[string]int)
+	for k, t := range v.claves[tipo] {
+		d := distanciaEdicion(clave, k)
+		if strings.Contains(k, clave) || strings.Contains(clave, k) {
+			d = 0
+		}
+		if d > max(2, len([]rune(clave))/4) {
+			continue
+		}
+		if actual, ok := mejores[t.Nombre]; !ok || d < actual {
+			mejores[t.Nombre] = d
+		}
+	}
+
+	candidatos := make([]candidato, 0, len(mejores))
+	for nombre, d := range mejores {
+		candidatos = append(candidatos, candidato{nombre, d})
+	}
+	sort.Slice(candidatos, func(i, j int) bool {
+		if candidatos[i].distancia != candidatos[j].distancia {
+			return candidatos[i].distancia < candidatos[j].distancia
+		}
+		return candidatos[i].nombre < candidatos[j].nombre
+	})
+
+	sugerencias := []string{}
+	for i := 0; i < len(candidatos) && i < maxSugerencias; i++ {
+		sugerencias = append(sugerencias, candidatos[i].nombre)
+	}
+	return sugerencias
+}
+
+// distanciaEdicion es la distancia de Levenshtein entre dos cadenas
+func distanciaEdicion(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		actual := make([]int, len(rb)+1)
+		actual[0] = i
+		for j := 1; j <= len(rb); j++ {
+			costo := 1
+			if ra[i-1] == rb[j-1] {
+				costo = 0
+			}
+			actual[j] = min(prev[j]+1, actual[j-1]+1, prev[j-1]+costo)
+		}
+		prev = actual
+	}
+	return prev[len(rb)]
+}
+
+// canonizarFiltros reemplaza cada valor por su nombre canónico y quita los repetidos, manteniendo el orden alfabético
+func canonizarFiltros(v vocabulario, tipo models.TipoTermino, valores []string) []string {
+	resultado := []string{}
+	vistos := make(map[string]bool)
+	for _, valor := range valores {
+		canonico := v.canonico(tipo, valor)
+		if k := claveProp(canonico); !vistos[k] {
+			vistos[k] = true
+			resultado = append(resultado, canonico)
+		}
+	}
+	sort.Strings(resultado)
+	return resultado
+}
+
+// AvisoVocabulario informa que un elemento o herramienta se reemplazó por su nombre canónico
+// o que no está en el catálogo (con los términos parecidos, si los hay)
+type AvisoVocabulario struct {
+	Tipo        models.TipoTermino `json:"tipo"`
+	Valor       string             `json:"valor"`
+	Canonico    string             `json:"canonico,omitempty"`    // Nombre con el que se guardó
+	Sugerencias []string           `json:"sugerencias,omitempty"` // Solo si no está en el catálogo
+}
+
+// canonizarSnapshot reemplaza los elementos y herramientas que coinciden con el catálogo por su
+// nombre canónico (quitando herramientas repetidas) y avisa de los que no están catalogados.
+// No bloquea el guardado: el catálogo crece a partir de lo que cargan los autores
+func canonizarSnapshot(v vocabulario, snap *models.SnapshotMaterial) []AvisoVocabulario {
+	var avisos []AvisoVocabulario
+
+	revisar := func(tipo models.TipoTermino, valor string) string {
+		if strings.TrimSpace(valor) == "" {
+			return valor
+		}
+		if t := v.buscar(tipo, valor); t != nil {
+			if t.Nombre != valor {
+				avisos = append(avisos, AvisoVocabulario{Tipo: tipo, Valor: valor, Canonico: t.Nombre})
+			}
+			return t.Nombre
+		}
+		if len(v.terminos[tipo]) > 0 {
+			avisos = append(avisos, AvisoVocabulario{Tipo: tipo, Valor: valor, Sugerencias: v.sugerir(tipo, valor)})
+		}
+		return valor
+	}
+
+	for i := range snap.Composicion {
+		snap.Composicion[i].Elemento = revisar(models.TerminoElemento, snap.Composicion[i].Elemento)
+	}
+
+	herramientas := models.StringArray{}
+	vistas := make(map[string]bool)
+	for _, h := range snap.Herramientas {
+		h = revisar(models.TerminoHerramienta, h)
+		if k := claveProp(h); !vistas[k] {
+			vistas[k] = true
+			herramientas = append(herramientas, h)
+		}
+	}
+	if snap.Herramientas != nil {
+		snap.Herramientas = herramientas
+	}
+
+	return avisos
+}
+
+// revisarVocabulario canoniza el snapshot contra el catálogo. Si el catálogo no se puede leer el material
+// se guarda tal cual: el vocabulario ayuda a mantener los datos limpios pero no es imprescindible
+func revisarVocabulario(db *gorm.DB, snap *models.SnapshotMaterial) []AvisoVocabulario {
+	v, err := cargarVocabulario(db)
+	if err != nil {
+		log.Printf("⚠️ Error leyendo el vocabulario: %v", err)
+		return nil
+	}
+	return canonizarSnapshot(v, snap)
+}
+
+// TerminoRequest es el cuerpo para crear o editar un término
+type TerminoRequest struct {
+	Tipo        models.TipoTermino `json:"tipo"`
+	Nombre      string             `json:"nombre"`
+	Descripcion string             `json:"descripcion"`
+	Sinonimos   []string           `json:"sinonimos"`
+}
+
+// validarTermino limpia el request y comprueba que ningún nombre o sinónimo pertenezca ya a otro término
+func validarTermino(db *gorm.DB, req *TerminoRequest, excluir uuid.UUID) (int, error) {
+	req.Nombre = strings.TrimSpace(req.Nombre)
+	if !req.Tipo.Valido() {
+		return http.StatusBadRequest, fmt.Errorf("Tipo '%s' inválido. Usa 'elemento' o 'herramienta'", req.Tipo)
+	}
+	if req.Nombre == "" {
+		return http.StatusBadRequest, errors.New("El nombre es requerido")
+	}
+
+	// Sinónimos sin repetir y distintos del nombre
+	sinonimos := []string{}
+	vistos := map[string]bool{claveProp(req.Nombre): true}
+	for _, s := range req.Sinonimos {
+		s = strings.TrimSpace(s)
+		if k := claveProp(s); k != "" && !vistos[k] {
+			vistos[k] = true
+			sinonimos = append(sinonimos, s)
+		}
+	}
+	req.Sinonimos = sinonimos
+
+	v, err := cargarVocabulario(db)
+	if err != nil {
+		return http.StatusInternalServerError, errors.New("Error leyendo el vocabulario")
+	}
+	for k := range vistos {
+		if t, ok := v.claves[req.Tipo][k]; ok && t.ID != excluir {
+			return http.StatusConflict, fmt.Errorf("'%s' ya pertenece al término '%s'", k, t.Nombre)
+		}
+	}
+	return 0, nil
+}
+
+// GetTerminos lista el vocabulario. ?tipo=elemento|herramienta filtra por catálogo y ?q= busca en
+// nombres y sinónimos, así sirve también para autocompletar en el formulario
+func GetTerminos(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	query := db.Order("tipo ASC, nombre ASC")
+	if tipo := models.TipoTermino(c.Query("tipo")); tipo != "" {
+		if !tipo.Valido() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tipo inválido. Usa 'elemento' o 'herramienta'"})
+			return
+		}
+		query = query.Where("tipo = ?", tipo)
+	}
+
+	var terminos []models.Termino
+	if err := query.Find(&terminos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error obteniendo vocabulario"})
+		return
+	}
+
+	if q := claveProp(c.Query("q")); q != "" {
+		filtrados := []models.Termino{}
+		for _, t := range terminos {
+			for _, k := range clavesTermino(t) {
+				if strings.Contains(k, q) {
+					filtrados = append(filtrados, t)
+					break
+				}
+			}
+		}
+		terminos = filtrados
+	}
+
+	c.JSON(http.StatusOK, terminos)
+}
+
+// GetTerminosSinCatalogar lista los elementos y herramientas usados en materiales que no corresponden
+// a ningún término, con cuántos materiales los usan y sugerencias. Es el punto de partida para fusionar
+func GetTerminosSinCatalogar(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	v, err := cargarVocabulario(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error leyendo el vocabulario"})
+		return
+	}
+
+	var usos []struct {
+		Tipo       models.TipoTermino
+		Valor      string
+		Materiales int
+	}
+	err = db.Raw(`
+        SELECT 'herramienta' AS tipo, btrim(h) AS valor, COUNT(DISTINCT m.id) AS materiales
+        FROM materials m, jsonb_array_elements_text(` + arregloJSON("m.herramientas") + `) AS h
+        WHERE m.deleted_at IS NULL AND btrim(h) <> ''
+        GROUP BY 1, 2
+        UNION ALL
+        SELECT 'elemento', btrim(e->>'elemento'), COUNT(DISTINCT m.id)
+        FROM materials m, jsonb_array_elements(` + arregloJSON("m.composicion") + `) AS e
+        WHERE m.deleted_at IS NULL AND btrim(e->>'elemento') <> ''
+        GROUP BY 1, 2
+        ORDER BY 3 DESC, 2 ASC
+    `).Scan(&usos).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error obteniendo valores usados"})
+		return
+	}
+
+	tipo := models.TipoTermino(c.Query("tipo"))
+	resultado := []gin.H{}
+	for _, u := range usos {
+		if (tipo != "" && u.Tipo != tipo) || v.buscar(u.Tipo, u.Valor) != nil {
+			continue
+		}
+		resultado = append(resultado, gin.H{
+			"tipo":        u.Tipo,
+			"valor":       u.Valor,
+			"materiales":  u.Materiales,
+			"sugerencias": v.sugerir(u.Tipo, u.Valor),
+		})
+	}
+
+	c.JSON(http.StatusOK, resultado)
+}
+
+// CreateTermino agrega un término al vocabulario - Solo Admin
+func CreateTermino(c *gin.Context) {
+	var req TerminoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos: " + err.Error()})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	if status, err := validarTermino(db, &req, uuid.Nil); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	termino := models.Termino{
+		Tipo:        req.Tipo,
+		Nombre:      req.Nombre,
+		Descripcion: strings.TrimSpace(req.Descripcion),
+		Sinonimos:   req.Sinonimos,
+	}
+	if err := db.Create(&termino).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando término: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, termino)
+}
+
+// UpdateTermino edita nombre, descripción y sinónimos de un término - Solo Admin.
+// No reescribe los materiales: para eso está POST /vocabulary/:id/merge
+func UpdateTermino(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req TerminoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos: " + err.Error()})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	var termino models.Termino
+	if err := db.First(&termino, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Término no encontrado"})
+		return
+	}
+
+	if req.Tipo == "" {
+		req.Tipo = termino.Tipo
+	}
+	if status, err := validarTermino(db, &req, termino.ID); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	termino.Tipo = req.Tipo
+	termino.Nombre = req.Nombre
+	termino.Descripcion = strings.TrimSpace(req.Descripcion)
+	termino.Sinonimos = req.Sinonimos
+	if err := db.Save(&termino).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando término: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, termino)
+}
+
+// DeleteTermino quita un término del vocabulario. Los materiales conservan el texto que tengan - Solo Admin
+func DeleteTermino(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	res := db.Delete(&models.Termino{}, "id = ?", id)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error eliminando término"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Término no encontrado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Término eliminado"})
+}
+
+// MergeRequest es el cuerpo de POST /vocabulary/:id/merge
+type MergeRequest struct {
+	Variantes []string `json:"variantes"` // Se agregan como sinónimos del término
+	DryRun    bool     `json:"dry_run"`   // Solo informa qué materiales cambiarían
+}
+
+// MergeTermino reescribe en todos los materiales (y en las revisiones pendientes de moderación)
+// los elementos y herramientas que coinciden con el término o sus variantes por el nombre canónico.
+// Las variantes nuevas quedan como sinónimos para que los próximos guardados ya se canonicen - Solo Admin
+func MergeTermino(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req MergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos: " + err.Error()})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error conectando a la DB"})
+		return
+	}
+
+	var termino models.Termino
+	if err := db.First(&termino, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Término no encontrado"})
+		return
+	}
+
+	// 1. Las variantes no pueden pertenecer a otro término
+	actualizado := TerminoRequest{
+		Tipo:        termino.Tipo,
+		Nombre:      termino.Nombre,
+		Descripcion: termino.Descripcion,
+		Sinonimos:   append(append([]string{}, termino.Sinonimos...), req.Variantes...),
+	}
+	if status, err := validarTermino(db, &actualizado, termino.ID); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	termino.Sinonimos = actualizado.Sinonimos
+
+	// Nombres del término en JSON: la clave de cada uno se calcula en SQL con unaccent, igual que la de
+	// los valores guardados, para que Go y Postgres nunca discrepen sobre qué coincide
+	nombres, _ := json.Marshal(append([]string{termino.Nombre}, termino.Sinonimos...))
+	clavesSQL := `(SELECT unaccent(lower(btrim(n))) FROM jsonb_array_elements_text(?::jsonb) AS n)`
+	coincide := map[string]bool{}
+
+	// reemplazar aplica el término a la composición y herramientas; devuelve si cambió algo
+	reemplazar := func(comp models.JSONComponentes, herramientas *models.StringArray) bool {
+		cambio := false
+		if termino.Tipo == models.TerminoElemento {
+			for i := range comp {
+				if coincide[comp[i].Elemento] && comp[i].Elemento != termino.Nombre {
+					comp[i].Elemento = termino.Nombre
+					cambio = true
+				}
+			}
+			return cambio
+		}
+
+		nuevas := models.StringArray{}
+		puesto := false
+		for _, h := range *herramientas {
+			if !coincide[h] {
+				nuevas = append(nuevas, h)
+				continue
+			}
+			if h != termino.Nombre || puesto {
+				cambio = true
+			}
+			if !puesto {
+				nuevas = append(nuevas, termino.Nombre)
+				puesto = true
+			}
+		}
+		if cambio {
+			*herramientas = nuevas
+		}
+		return cambio
+	}
+
+	// 2. Candidatos: se filtran en SQL por la clave sin acentos
+	filtro := `EXISTS (SELECT 1 FROM jsonb_array_elements(` + arregloJSON("composicion") + `) AS e
+		WHERE unaccent(lower(btrim(e->>'elemento'))) IN ` + clavesSQL + `)`
+	if termino.Tipo == models.TerminoHerramienta {
+		filtro = `EXISTS (SELECT 1 FROM jsonb_array_elements_text(` + arregloJSON("herramientas") + `) AS h
+			WHERE unaccent(lower(btrim(h))) IN ` + clavesSQL + `)`
+	}
+
+	var materiales []models.Material
+	if err := db.Unscoped().Select("id", "nombre", "composicion", "herramientas").
+		Where(filtro, string(nombres)).Find(&materiales).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error buscando materiales: " + err.Error()})
+		return
+	}
+
+	var revisiones []models.RevisionMaterial
+	if err := db.Where("estado = ?", models.RevisionPendiente).Find(&revisiones).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error buscando revisiones pendientes: " + err.Error()})
+		return
+	}
+
+	// 3. Qué valores escritos coinciden con el término: también lo decide Postgres
+	valores := []string{}
+	agregar := func(comp models.JSONComponentes, herramientas models.StringArray) {
+		if termino.Tipo == models.TerminoElemento {
+			for _, e := range comp {
+				valores = append(valores, e.Elemento)
+			}
+			return
+		}
+		valores = append(valores, herramientas...)
+	}
+	for _, m := range materiales {
+		agregar(m.Composicion, m.Herramientas)
+	}
+	for _, r := range revisiones {
+		agregar(r.Contenido.Composicion, r.Contenido.Herramientas)
+	}
+	if len(valores) > 0 {
+		escritos, _ := json.Marshal(valores)
+		var coincidentes []string
+		if err := db.Raw(`SELECT DISTINCT v FROM jsonb_array_elements_text(?::jsonb) AS v
+			WHERE unaccent(lower(btrim(v))) IN `+clavesSQL, string(escritos), string(nombres)).
+			Scan(&coincidentes).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error comparando variantes: " + err.Error()})
+			return
+		}
+		for _, v := range coincidentes {
+			coincide[v] = true
+		}
+	}
+
+	afectados := []gin.H{}
+	var cambiados []models.Material
+	for _, m := range materiales {
+		if reemplazar(m.Composicion, &m.Herramientas) {
+			cambiados = append(cambiados, m)
+			afectados = append(afectados, gin.H{"id": m.ID, "nombre": m.Nombre})
+		}
+	}
+	var revisionesCambiadas []models.RevisionMaterial
+	for _, r := range revisiones {
+		if reemplazar(r.Contenido.Composicion, &r.Contenido.Herramientas) {
+			revisionesCambiadas = append(revisionesCambiadas, r)
+		}
+	}
+
+	respuesta := gin.H{
+		"termino":                 termino,
+		"materiales_actualizados": len(cambiados),
+		"revisiones_actualizadas": len(revisionesCambiadas),
+		"materiales":              afectados,
+		"dry_run":                 req.DryRun,
+	}
+	if req.DryRun {
+		c.JSON(http.StatusOK, respuesta)
+		return
+	}
+
+	// 4. Aplicar todo en una transacción. UpdateColumn no toca updated_at: es una corrección de datos
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&termino).Update("sinonimos", termino.Sinonimos).Error; err != nil {
+			return err
+		}
+		for _, m := range cambiados {
+			if err := tx.Unscoped().Model(&models.Material{}).Where("id = ?", m.ID).
+				UpdateColumns(map[string]interface{}{"composicion": m.Composicion, "herramientas": m.Herramientas}).Error; err != nil {
+				return err
+			}
+		}
+		for _, r := range revisionesCambiadas {
+			if err := tx.Model(&models.RevisionMaterial{}).Where("id = ?", r.ID).
+				UpdateColumn("contenido", r.Contenido).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fusionando término: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, respuesta)
+}
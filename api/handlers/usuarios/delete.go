@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
@@ -10,6 +11,7 @@ import (
 	"TT-SEM-2-BACK/api/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // DeleteUsuario elimina un usuario (solo admin)
@@ -117,21 +119,53 @@ func HardDeleteUsuario(c *gin.Context) {
 		return
 	}
 
-	// Eliminar colaboraciones si existen
-	if countColaboraciones > 0 {
-		log.Printf("⚠️ Eliminando %d colaboraciones del usuario %s", countColaboraciones, googleID)
-		if err := db.Where("usuario_id = ?", googleID).Delete(&models.ColaboradorMaterial{}).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error eliminando colaboraciones: " + err.Error()})
-			return
-		}
-	}
-
 	// ADVERTENCIA
 	log.Printf("⚠️⚠️⚠️ HARD DELETE: Eliminando permanentemente usuario %s (%s) - GoogleID: %s",
 		usuario.Nombre, usuario.Email, usuario.GoogleID)
 
-	// Hard delete
-	if err := db.Unscoped().Delete(&usuario).Error; err != nil {
+	// Todo en una transacción: si el borrado del usuario falla no se pierde nada de lo anterior
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Eliminar colaboraciones si existen
+		if countColaboraciones > 0 {
+			log.Printf("⚠️ Eliminando %d colaboraciones del usuario %s", countColaboraciones, googleID)
+			if err := tx.Where("usuario_id = ?", googleID).Delete(&models.ColaboradorMaterial{}).Error; err != nil {
+				return fmt.Errorf("error eliminando colaboraciones: %w", err)
+			}
+		}
+
+		// Las revisiones que guardó o revisó se conservan sin autor ni revisor
+		if err := tx.Model(&models.RevisionMaterial{}).Where("autor_id = ?", googleID).Update("autor_id", nil).Error; err != nil {
+			return fmt.Errorf("error actualizando revisiones: %w", err)
+		}
+		if err := tx.Model(&models.RevisionMaterial{}).Where("revisor_id = ?", googleID).Update("revisor_id", nil).Error; err != nil {
+			return fmt.Errorf("error actualizando revisiones: %w", err)
+		}
+
+		// Sus comentarios de moderación también se conservan, sin autor
+		if err := tx.Model(&models.ComentarioRevision{}).Where("autor_id = ?", googleID).Update("autor_id", nil).Error; err != nil {
+			return fmt.Errorf("error actualizando comentarios: %w", err)
+		}
+		if err := tx.Model(&models.ComentarioRevision{}).Where("resuelto_por_id = ?", googleID).Update("resuelto_por_id", nil).Error; err != nil {
+			return fmt.Errorf("error actualizando comentarios: %w", err)
+		}
+
+		// Sus solicitudes de rol se borran; las que resolvió quedan sin revisor
+		if err := tx.Where("usuario_id = ?", googleID).Delete(&models.SolicitudRol{}).Error; err != nil {
+			return fmt.Errorf("error eliminando solicitudes de rol: %w", err)
+		}
+		if err := tx.Model(&models.SolicitudRol{}).Where("revisor_id = ?", googleID).Update("revisor_id", nil).Error; err != nil {
+			return fmt.Errorf("error actualizando solicitudes de rol: %w", err)
+		}
+
+		// Las invitaciones que recibió o envió se borran con él
+		if err := tx.Where("usuario_id = ? OR invitado_por_id = ?", googleID, googleID).Delete(&models.InvitacionColaborador{}).Error; err != nil {
+			return fmt.Errorf("error eliminando invitaciones: %w", err)
+		}
+
+		// Hard delete
+		return tx.Unscoped().Delete(&usuario).Error
+	})
+	if err != nil {
 		if strings.Contains(err.Error(), "foreign key") || strings.Contains(err.Error(), "violates foreign key constraint") {
 			c.JSON(http.StatusConflict, gin.H{
 				"error":  "No se puede eliminar el usuario debido a restricciones de integridad referencial",
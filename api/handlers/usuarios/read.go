@@ -94,9 +94,11 @@ func GetMe(c *gin.Context) {
 	}
 
 	// Contar materiales por estado
-	var materialesAprobados, materialesPendientes int64
-	db.Model(&models.Material{}).Where("creador_id = ? AND estado = ?", googleID, true).Count(&materialesAprobados)
-	db.Model(&models.Material{}).Where("creador_id = ? AND estado = ?", googleID, false).Count(&materialesPendientes)
+	var materialesAprobados, materialesPendientes, materialesBorrador, materialesRechazados int64
+	db.Model(&models.Material{}).Where("creador_id = ? AND estado = ?", googleID, models.EstadoAprobado).Count(&materialesAprobados)
+	db.Model(&models.Material{}).Where("creador_id = ? AND estado = ?", googleID, models.EstadoPendiente).Count(&materialesPendientes)
+	db.Model(&models.Material{}).Where("creador_id = ? AND estado = ?", googleID, models.EstadoBorrador).Count(&materialesBorrador)
+	db.Model(&models.Material{}).Where("creador_id = ? AND estado = ?", googleID, models.EstadoRechazado).Count(&materialesRechazados)
 
 	c.JSON(http.StatusOK, gin.H{
 		"usuario": gin.H{
@@ -108,6 +110,8 @@ func GetMe(c *gin.Context) {
 			"materiales_creados":    len(materialesCreados),
 			"materiales_aprobados":  materialesAprobados,
 			"materiales_pendientes": materialesPendientes,
+			"materiales_borrador":   materialesBorrador,
+			"materiales_rechazados": materialesRechazados,
 			"colaboraciones":        len(materialesColaboracion),
 		},
 		"materiales_creados":     materialesCreados,
@@ -174,7 +178,7 @@ func GetPublicUserProfile(c *gin.Context) {
 
 	// Obtener SOLO materiales APROBADOS creados por el usuario (públicos)
 	var materialesCreados []models.Material
-	if err := db.Where("creador_id = ? AND estado = ?", googleID, true).
+	if err := db.Where("creador_id = ? AND estado = ?", googleID, models.EstadoAprobado).
 		Preload("Galeria").
 		Preload("Colaboradores").
 		// Propiedades se cargan solas
@@ -188,7 +192,7 @@ func GetPublicUserProfile(c *gin.Context) {
 	// NOTA: Ajusté también el nombre de la tabla JOIN a 'material_colaboradores' por si acaso,
 	// asegúrate de que coincida con lo que definimos en update.go
 	if err := db.Joins("JOIN material_colaboradores ON material_colaboradores.material_id = materials.id").
-		Where("material_colaboradores.usuario_id = ? AND materials.estado = ?", googleID, true).
+		Where("material_colaboradores.usuario_id = ? AND materials.estado = ?", googleID, models.EstadoAprobado).
 		Preload("Creador").
 		Preload("Galeria").
 		Preload("Colaboradores").
@@ -87,7 +87,8 @@ func RegisterUserFromGoogle(c *gin.Context) {
 			usuario.Nombre = req.Nombre
 			updated = true
 		}
-		if usuario.Email != req.Email {
+		emailCambiado := usuario.Email != req.Email
+		if emailCambiado {
 			usuario.Email = req.Email
 			updated = true
 		}
@@ -105,6 +106,9 @@ func RegisterUserFromGoogle(c *gin.Context) {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error actualizando datos"})
 				return
 			}
+			if emailCambiado {
+				vincularInvitaciones(db, usuario)
+			}
 			c.JSON(http.StatusOK, gin.H{"message": "Usuario actualizado", "usuario": usuario})
 			return
 		}
@@ -137,5 +141,47 @@ func RegisterUserFromGoogle(c *gin.Context) {
 	}
 
 	log.Printf("✅ Nuevo usuario registrado: %s (SupabaseID: %s)", usuario.Email, usuario.SupabaseID)
+
+	// 6. Invitaciones a colaborar que recibió antes de tener cuenta
+	vincularInvitaciones(db, usuario)
+
 	c.JSON(http.StatusCreated, gin.H{"message": "Usuario registrado exitosamente", "usuario": usuario})
 }
+
+// vincularInvitaciones asocia al usuario las invitaciones pendientes enviadas a su email antes de que
+// tuviera cuenta y le avisa de cada una. Un error aquí no impide el registro
+func vincularInvitaciones(db *gorm.DB, usuario models.Usuario) {
+	var invitaciones []models.InvitacionColaborador
+	if err := db.Preload("Material").
+		Where("usuario_id IS NULL AND estado = ? AND email = ?", models.InvitacionPendiente, strings.ToLower(usuario.Email)).
+		Find(&invitaciones).Error; err != nil {
+		log.Printf("⚠️ Error buscando invitaciones de %s: %v", usuario.Email, err)
+		return
+	}
+
+	for _, inv := range invitaciones {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&inv).Update("usuario_id", usuario.GoogleID).Error; err != nil {
+				return err
+			}
+			nombre := ""
+			if inv.Material != nil {
+				nombre = inv.Material.Nombre
+			}
+			matID := inv.MaterialID
+			return tx.Create(&models.Notificacion{
+				UsuarioID:  usuario.GoogleID,
+				MaterialID: &matID,
+				Titulo:     "Invitación a colaborar",
+				Mensaje:    "Te invitaron a colaborar en el material '" + nombre + "'.",
+				Tipo:       "invitacion_colaborador",
+				Link:       "/invitations",
+			}).Error
+		}); err != nil {
+			log.Printf("⚠️ Error vinculando invitación %s: %v", inv.ID, err)
+		}
+	}
+	if len(invitaciones) > 0 {
+		log.Printf("🔔 %d invitaciones pendientes vinculadas a %s", len(invitaciones), usuario.Email)
+	}
+}
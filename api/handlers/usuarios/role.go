@@ -1,17 +1,34 @@
 package auth
 
 import (
+	"errors"
+	"io"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"TT-SEM-2-BACK/api/database"
 	"TT-SEM-2-BACK/api/middleware"
 	"TT-SEM-2-BACK/api/models"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-// RequestCollaboratorRole: Usuario solicita ser colaborador
+// SolicitudRolRequest es el cuerpo opcional de POST /users/request-role
+type SolicitudRolRequest struct {
+	Mensaje string `json:"mensaje"`
+}
+
+// RespuestaSolicitudRequest es el cuerpo opcional al aprobar o rechazar una solicitud
+type RespuestaSolicitudRequest struct {
+	Motivo string `json:"motivo"`
+}
+
+// RequestCollaboratorRole: Usuario solicita ser colaborador. La solicitud queda registrada y
+// no se acepta otra mientras haya una pendiente
 func RequestCollaboratorRole(c *gin.Context) {
 	// 1. Obtener ID del usuario que solicita
 	googleID, exists := middleware.GetUserGoogleID(c)
@@ -20,6 +37,12 @@ func RequestCollaboratorRole(c *gin.Context) {
 		return
 	}
 
+	var req SolicitudRolRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request inválido: " + err.Error()})
+		return
+	}
+
 	db, err := database.OpenGormDB()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error de conexión a BD"})
@@ -39,7 +62,34 @@ func RequestCollaboratorRole(c *gin.Context) {
 		return
 	}
 
-	// 3. Notificar a los Administradores (Asíncrono)
+	// 3. Solo una solicitud pendiente por usuario
+	var pendiente models.SolicitudRol
+	if err := db.Where("usuario_id = ? AND estado = ?", googleID, models.SolicitudPendiente).
+		First(&pendiente).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":     "Ya tienes una solicitud pendiente. Un administrador la revisará pronto",
+			"solicitud": pendiente,
+		})
+		return
+	}
+
+	solicitud := models.SolicitudRol{
+		UsuarioID:     googleID,
+		RolSolicitado: "colaborador",
+		Mensaje:       strings.TrimSpace(req.Mensaje),
+		Estado:        models.SolicitudPendiente,
+	}
+	if err := db.Create(&solicitud).Error; err != nil {
+		// El índice único parcial atrapa dos solicitudes simultáneas
+		if errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "idx_solicitudes_rol_pendiente") {
+			c.JSON(http.StatusConflict, gin.H{"error": "Ya tienes una solicitud pendiente. Un administrador la revisará pronto"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error guardando la solicitud"})
+		return
+	}
+
+	// 4. Notificar a los Administradores (Asíncrono)
 	go func() {
 		// Buscar todos los admins
 		var admins []models.Usuario
@@ -48,6 +98,11 @@ func RequestCollaboratorRole(c *gin.Context) {
 			return
 		}
 
+		mensaje := "El usuario " + solicitante.Nombre + " (" + solicitante.Email + ") solicita ser Colaborador."
+		if solicitud.Mensaje != "" {
+			mensaje += " Motivo: " + solicitud.Mensaje
+		}
+
 		// Crear notificación para cada uno
 		for _, admin := range admins {
 			notif := models.Notificacion{
@@ -55,9 +110,9 @@ func RequestCollaboratorRole(c *gin.Context) {
 				// No asociamos MaterialID porque es una solicitud de usuario
 				MaterialID: nil,
 				Titulo:     "Solicitud de Rol: Colaborador",
-				Mensaje:    "El usuario " + solicitante.Nombre + " (" + solicitante.Email + ") solicita ser Colaborador.",
-				Tipo:       "solicitud_rol", // Tipo especial para manejar íconos en el front
-				Link:       "/admin",        // Link a la gestión de usuarios
+				Mensaje:    mensaje,
+				Tipo:       "solicitud_rol",                                 // Tipo especial para manejar íconos en el front
+				Link:       "/admin/role-requests/" + solicitud.ID.String(), // Aprobación en un clic
 				Leido:      false,
 			}
 			if err := db.Create(&notif).Error; err != nil {
@@ -67,7 +122,179 @@ func RequestCollaboratorRole(c *gin.Context) {
 		log.Printf("🔔 Solicitud de rol enviada a %d administradores.", len(admins))
 	}()
 
+	c.JSON(http.StatusCreated, gin.H{
+		"message":   "Solicitud enviada exitosamente. Un administrador revisará tu petición.",
+		"solicitud": solicitud,
+	})
+}
+
+// GetMyRoleRequests: El usuario consulta sus solicitudes de rol (la más reciente primero).
+// Los lectores no tienen acceso a las notificaciones, así es como se enteran de un rechazo
+func GetMyRoleRequests(c *gin.Context) {
+	googleID, exists := middleware.GetUserGoogleID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+		return
+	}
+
+	db, err := database.OpenGormDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error de conexión a BD"})
+		return
+	}
+
+	solicitudes := []models.SolicitudRol{}
+	if err := db.Where("usuario_id = ?", googleID).
+		Preload("Revisor").
+		Order("created_at DESC").
+		Find(&solicitudes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error obteniendo solicitudes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, solicitudes)
+}
+
+// GetRoleRequests lista las solicitudes de rol - Solo Admin.
+// ?estado=pendiente (por defecto), aprobada, rechazada o todas
+func GetRoleRequests(c *gin.Context) {
+	db, err := database.OpenGormDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error de conexión a BD"})
+		return
+	}
+
+	query := db.Preload("Usuario").Preload("Revisor").Order("created_at ASC")
+
+	estado := c.DefaultQuery("estado", string(models.SolicitudPendiente))
+	switch models.EstadoSolicitud(estado) {
+	case models.SolicitudPendiente, models.SolicitudAprobada, models.SolicitudRechazada:
+		query = query.Where("estado = ?", estado)
+	default:
+		if estado != "todas" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":           "Estado inválido",
+				"estados_validos": []string{"pendiente", "aprobada", "rechazada", "todas"},
+			})
+			return
+		}
+	}
+
+	solicitudes := []models.SolicitudRol{}
+	if err := query.Find(&solicitudes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error obteniendo solicitudes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, solicitudes)
+}
+
+// ApproveRoleRequest aprueba una solicitud: cambia el rol del usuario y le avisa - Solo Admin
+func ApproveRoleRequest(c *gin.Context) {
+	resolverSolicitud(c, models.SolicitudAprobada)
+}
+
+// DenyRoleRequest rechaza una solicitud y le avisa al usuario con el motivo - Solo Admin
+func DenyRoleRequest(c *gin.Context) {
+	resolverSolicitud(c, models.SolicitudRechazada)
+}
+
+// errSolicitudResuelta indica que otra petición resolvió la solicitud antes
+var errSolicitudResuelta = errors.New("la solicitud ya fue resuelta")
+
+// resolverSolicitud aprueba o rechaza una solicitud pendiente en una sola transacción
+func resolverSolicitud(c *gin.Context, estado models.EstadoSolicitud) {
+	adminGoogleID, _ := middleware.GetUserGoogleID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req RespuestaSolicitudRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request inválido: " + err.Error()})
+		return
+	}
+
+	db, err := database.OpenGormDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error de conexión a BD"})
+		return
+	}
+
+	var solicitud models.SolicitudRol
+	if err := db.Preload("Usuario").First(&solicitud, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Solicitud no encontrada"})
+		return
+	}
+	if solicitud.Estado != models.SolicitudPendiente {
+		c.JSON(http.StatusConflict, gin.H{"error": "La solicitud ya fue " + string(solicitud.Estado)})
+		return
+	}
+	if solicitud.Usuario == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "El usuario de la solicitud ya no existe"})
+		return
+	}
+
+	ahora := time.Now()
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		// La condición sobre el estado evita resolver dos veces la misma solicitud
+		res := tx.Model(&models.SolicitudRol{}).
+			Where("id = ? AND estado = ?", solicitud.ID, models.SolicitudPendiente).
+			Updates(map[string]interface{}{
+				"estado":      estado,
+				"revisor_id":  adminGoogleID,
+				"respuesta":   strings.TrimSpace(req.Motivo),
+				"revisada_en": ahora,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errSolicitudResuelta
+		}
+
+		// Un administrador que pidió el rol no baja a colaborador
+		if estado == models.SolicitudAprobada && solicitud.Usuario.Rol != "administrador" {
+			if err := tx.Model(&models.Usuario{}).Where("google_id = ?", solicitud.UsuarioID).
+				Update("rol", solicitud.RolSolicitado).Error; err != nil {
+				return err
+			}
+		}
+
+		titulo, mensaje, tipo := "Solicitud de rol aprobada", "Ahora eres Colaborador: ya puedes crear y editar materiales.", "solicitud_rol_aprobada"
+		if estado == models.SolicitudRechazada {
+			titulo, mensaje, tipo = "Solicitud de rol rechazada", "Tu solicitud para ser Colaborador fue rechazada.", "solicitud_rol_rechazada"
+		}
+		if motivo := strings.TrimSpace(req.Motivo); motivo != "" {
+			mensaje += " Motivo: " + motivo
+		}
+		return tx.Create(&models.Notificacion{
+			UsuarioID: solicitud.UsuarioID,
+			Titulo:    titulo,
+			Mensaje:   mensaje,
+			Tipo:      tipo,
+			Link:      "/profile",
+		}).Error
+	}); err != nil {
+		if errors.Is(err, errSolicitudResuelta) {
+			c.JSON(http.StatusConflict, gin.H{"error": "La solicitud ya fue resuelta por otro administrador"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error resolviendo la solicitud: " + err.Error()})
+		return
+	}
+
+	db.Preload("Usuario").Preload("Revisor").First(&solicitud, "id = ?", solicitud.ID)
+
+	mensaje := "Solicitud aprobada. El usuario ahora es colaborador"
+	if estado == models.SolicitudRechazada {
+		mensaje = "Solicitud rechazada"
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Solicitud enviada exitosamente. Un administrador revisará tu petición.",
+		"message":   mensaje,
+		"solicitud": solicitud,
 	})
 }
@@ -9,9 +9,13 @@ import (
 )
 
 type DashboardStats struct {
-	Pendientes int64 `json:"pendientes"`
-	Aprobados  int64 `json:"aprobados"`
-	Usuarios   int64 `json:"usuarios"`
+	Pendientes           int64 `json:"pendientes"`
+	RevisionesPendientes int64 `json:"revisiones_pendientes"` // Cambios a materiales publicados en moderación
+	Aprobados            int64 `json:"aprobados"`
+	Rechazados           int64 `json:"rechazados"`
+	Borradores           int64 `json:"borradores"`
+	Archivados           int64 `json:"archivados"`
+	Usuarios             int64 `json:"usuarios"`
 }
 
 func GetDashboardStats(c *gin.Context) {
@@ -25,12 +29,18 @@ func GetDashboardStats(c *gin.Context) {
 
 	// Hacemos los conteos directamente en la BD (Es mil veces más rápido)
 	// 1. Contar pendientes
-	db.Model(&models.Material{}).Where("estado = ?", false).Count(&stats.Pendientes)
+	db.Model(&models.Material{}).Where("estado = ?", models.EstadoPendiente).Count(&stats.Pendientes)
+	db.Model(&models.RevisionMaterial{}).Where("estado = ?", models.RevisionPendiente).Count(&stats.RevisionesPendientes)
 
 	// 2. Contar aprobados
-	db.Model(&models.Material{}).Where("estado = ?", true).Count(&stats.Aprobados)
+	db.Model(&models.Material{}).Where("estado = ?", models.EstadoAprobado).Count(&stats.Aprobados)
 
-	// 3. Contar usuarios
+	// 3. Contar el resto de estados
+	db.Model(&models.Material{}).Where("estado = ?", models.EstadoRechazado).Count(&stats.Rechazados)
+	db.Model(&models.Material{}).Where("estado = ?", models.EstadoBorrador).Count(&stats.Borradores)
+	db.Model(&models.Material{}).Where("estado = ?", models.EstadoArchivado).Count(&stats.Archivados)
+
+	// 4. Contar usuarios
 	db.Model(&models.Usuario{}).Count(&stats.Usuarios)
 
 	c.JSON(http.StatusOK, stats)
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Categoria es un nodo del árbol de categorías que administran los admins
+// (por ejemplo Bioplásticos > Almidón). Un material pertenece a una sola categoría
+type Categoria struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Nombre      string     `gorm:"type:text;not null" json:"nombre"`
+	Slug        string     `gorm:"type:text;not null;uniqueIndex" json:"slug"`
+	Descripcion string     `gorm:"type:text" json:"descripcion"`
+	ParentID    *uuid.UUID `gorm:"type:uuid;index" json:"parent_id"`
+	Orden       int        `gorm:"not null;default:0" json:"orden"` // Posición entre sus hermanas
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Categoria) TableName() string {
+	return "categorias"
+}
@@ -7,10 +7,31 @@ import (
 	"gorm.io/gorm"
 )
 
+// RolColaborador es el permiso de un usuario sobre un material concreto
+type RolColaborador string
+
+const (
+	RolPropietario RolColaborador = "propietario" // El creador (o a quien se le transfirió): todo, incluso gestionar colaboradores
+	RolEditor      RolColaborador = "editor"      // Edita contenido y pasos y lo envía a revisión
+	RolLector      RolColaborador = "lector"      // Solo ve el material aunque no esté publicado, sus revisiones y comentarios
+)
+
+// Valido indica si el rol se puede asignar a un colaborador (el propietario solo se transfiere)
+func (r RolColaborador) Valido() bool {
+	return r == RolEditor || r == RolLector
+}
+
+// Incluye indica si el rol tiene al menos los permisos de otro
+func (r RolColaborador) Incluye(otro RolColaborador) bool {
+	nivel := map[RolColaborador]int{RolLector: 1, RolEditor: 2, RolPropietario: 3}
+	return nivel[r] >= nivel[otro] && nivel[otro] > 0
+}
+
 // Definición explícita de la tabla intermedia
 type ColaboradorMaterial struct {
-	MaterialID uuid.UUID `gorm:"type:uuid;primaryKey" json:"material_id"`
-	UsuarioID  string    `gorm:"type:text;primaryKey" json:"usuario_id"`
+	MaterialID uuid.UUID      `gorm:"type:uuid;primaryKey" json:"material_id"`
+	UsuarioID  string         `gorm:"type:text;primaryKey" json:"usuario_id"`
+	Rol        RolColaborador `gorm:"type:text;not null;default:'editor'" json:"rol"`
 
 	CreatedAt time.Time      `json:"created_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
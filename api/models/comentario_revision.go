@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SeccionComentario indica a qué parte del material apunta un comentario de moderación
+type SeccionComentario string
+
+const (
+	SeccionGeneral         SeccionComentario = "general"          // El material completo
+	SeccionCampo           SeccionComentario = "campo"            // nombre, descripcion, derivado_de, categoria_id o etiquetas
+	SeccionComposicion     SeccionComentario = "composicion"      // Referencia: nombre del elemento
+	SeccionPropMecanicas   SeccionComentario = "prop_mecanicas"   // Referencia: nombre de la propiedad
+	SeccionPropPerceptivas SeccionComentario = "prop_perceptivas" // Referencia: nombre de la propiedad
+	SeccionPropEmocionales SeccionComentario = "prop_emocionales" // Referencia: nombre de la propiedad
+	SeccionHerramientas    SeccionComentario = "herramientas"     // Referencia: nombre de la herramienta
+	SeccionPaso            SeccionComentario = "paso"             // Referencia: orden del paso
+	SeccionGaleria         SeccionComentario = "galeria"          // Referencia: URL de la imagen
+)
+
+var SeccionesComentario = []SeccionComentario{
+	SeccionGeneral, SeccionCampo, SeccionComposicion, SeccionPropMecanicas, SeccionPropPerceptivas,
+	SeccionPropEmocionales, SeccionHerramientas, SeccionPaso, SeccionGaleria,
+}
+
+// Valida indica si la sección es una de las conocidas
+func (s SeccionComentario) Valida() bool {
+	for _, sec := range SeccionesComentario {
+		if s == sec {
+			return true
+		}
+	}
+	return false
+}
+
+// ComentarioRevision es un comentario de moderación sobre una parte concreta de un material.
+// Los hilos se guardan por material (no por revisión) para que sigan visibles en los reenvíos
+type ComentarioRevision struct {
+	ID         uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	MaterialID uuid.UUID `gorm:"type:uuid;not null;index" json:"material_id"`
+	Revision   int       `gorm:"not null" json:"revision"` // Número de revisión sobre la que se comentó
+
+	Seccion    SeccionComentario `gorm:"type:text;not null" json:"seccion"`
+	Referencia string            `gorm:"type:text" json:"referencia,omitempty"`
+
+	// Respuestas del hilo: solo el comentario raíz lleva sección y estado de resolución
+	ParentID   *uuid.UUID           `gorm:"type:uuid;index" json:"parent_id,omitempty"`
+	Respuestas []ComentarioRevision `gorm:"foreignKey:ParentID" json:"respuestas,omitempty"`
+
+	AutorID string   `gorm:"type:text" json:"autor_id"` // Vacío si el autor se eliminó definitivamente
+	Autor   *Usuario `gorm:"foreignKey:AutorID;references:GoogleID" json:"autor,omitempty"`
+	Mensaje string   `gorm:"type:text;not null" json:"mensaje"`
+
+	Resuelto           bool       `gorm:"default:false;not null" json:"resuelto"`
+	ResueltoPorID      string     `gorm:"type:text" json:"resuelto_por_id,omitempty"`
+	ResueltoEn         *time.Time `json:"resuelto_en,omitempty"`
+	ResueltoEnRevision *int       `json:"resuelto_en_revision,omitempty"` // Revisión en la que se atendió
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (ComentarioRevision) TableName() string {
+	return "material_comentarios"
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EstadoMaterial es el estado del ciclo de vida de un material
+type EstadoMaterial string
+
+const (
+	EstadoBorrador  EstadoMaterial = "borrador"  // En edición por su autor, no visible para admins
+	EstadoPendiente EstadoMaterial = "pendiente" // Enviado a revisión
+	EstadoAprobado  EstadoMaterial = "aprobado"  // Público
+	EstadoRechazado EstadoMaterial = "rechazado" // Revisado y rechazado, el autor puede corregirlo
+	EstadoArchivado EstadoMaterial = "archivado" // Retirado por su autor o un admin
+)
+
+// EstadosMaterial lista todos los estados válidos
+var EstadosMaterial = []EstadoMaterial{EstadoBorrador, EstadoPendiente, EstadoAprobado, EstadoRechazado, EstadoArchivado}
+
+// transicionesMaterial define el ÚNICO lugar donde se decide qué cambios de estado son válidos
+var transicionesMaterial = map[EstadoMaterial][]EstadoMaterial{
+	EstadoBorrador:  {EstadoPendiente, EstadoArchivado},
+	EstadoPendiente: {EstadoAprobado, EstadoRechazado, EstadoBorrador},
+	EstadoRechazado: {EstadoPendiente, EstadoBorrador, EstadoArchivado},
+	EstadoAprobado:  {EstadoRechazado, EstadoArchivado}, // Las ediciones de un aprobado van como revisión
+	EstadoArchivado: {EstadoBorrador},
+}
+
+// ErrTransicionInvalida se devuelve cuando se intenta un cambio de estado no permitido
+var ErrTransicionInvalida = errors.New("transición de estado no permitida")
+
+// Valido indica si el estado es uno de los conocidos
+func (e EstadoMaterial) Valido() bool {
+	_, ok := transicionesMaterial[e]
+	return ok
+}
+
+// TransicionesPermitidas devuelve los estados a los que se puede pasar desde e
+func (e EstadoMaterial) TransicionesPermitidas() []EstadoMaterial {
+	return transicionesMaterial[e]
+}
+
+// PuedeTransicionar indica si se puede pasar de e a destino
+func (e EstadoMaterial) PuedeTransicionar(destino EstadoMaterial) bool {
+	for _, permitido := range transicionesMaterial[e] {
+		if permitido == destino {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidarTransicion devuelve ErrTransicionInvalida (envuelto) si el cambio no está permitido
+func (e EstadoMaterial) ValidarTransicion(destino EstadoMaterial) error {
+	if !destino.Valido() {
+		return fmt.Errorf("%w: estado desconocido '%s'", ErrTransicionInvalida, destino)
+	}
+	if !e.PuedeTransicionar(destino) {
+		return fmt.Errorf("%w: de '%s' a '%s'", ErrTransicionInvalida, e, destino)
+	}
+	return nil
+}
+
+// HistorialEstado registra cada cambio de estado de un material
+type HistorialEstado struct {
+	ID             uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	MaterialID     uuid.UUID      `gorm:"type:uuid;not null;index" json:"material_id"`
+	EstadoAnterior EstadoMaterial `gorm:"type:text" json:"estado_anterior"`
+	EstadoNuevo    EstadoMaterial `gorm:"type:text;not null" json:"estado_nuevo"`
+	UsuarioID      string         `gorm:"type:text" json:"usuario_id"`
+	Motivo         string         `gorm:"type:text" json:"motivo"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (HistorialEstado) TableName() string {
+	return "material_historial_estados"
+}
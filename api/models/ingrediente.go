@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Ingrediente es un insumo que se puede comprar, con su proveedor y precio. Los componentes de la
+// composición se vinculan por IngredienteID o, si no lo traen, por nombre
+type Ingrediente struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Nombre    string    `gorm:"type:text;not null;index" json:"nombre"`
+	Proveedor string    `gorm:"type:text" json:"proveedor"`
+
+	// Precio por una Unidad (por ejemplo 85.50 MXN por kg). nil si todavía no se conoce
+	PrecioUnitario *float64 `json:"precio_unitario"`
+	Moneda         string   `gorm:"type:text;not null;default:'MXN'" json:"moneda"`
+	Unidad         string   `gorm:"type:text;not null" json:"unidad"` // Masa, volumen o conteo (ver api/units)
+
+	Enlace string `gorm:"type:text" json:"enlace"`
+	Notas  string `gorm:"type:text" json:"notas"`
+
+	CreadoPorID string    `gorm:"type:text" json:"creado_por_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (Ingrediente) TableName() string {
+	return "ingredientes"
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EstadoInvitacion es el estado de una invitación a colaborar en un material
+type EstadoInvitacion string
+
+const (
+	InvitacionPendiente EstadoInvitacion = "pendiente"
+	InvitacionAceptada  EstadoInvitacion = "aceptada"
+	InvitacionRechazada EstadoInvitacion = "rechazada"
+	InvitacionCancelada EstadoInvitacion = "cancelada" // El dueño quitó al invitado de la lista
+)
+
+// InvitacionColaborador es la invitación del dueño de un material a un email. El invitado solo
+// queda como colaborador (ColaboradorMaterial) cuando la acepta. Si el email todavía no tiene
+// cuenta, UsuarioID queda vacío hasta que esa persona se registre
+type InvitacionColaborador struct {
+	ID            uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	MaterialID    uuid.UUID `gorm:"type:uuid;not null;index" json:"material_id"`
+	Material      *Material `gorm:"foreignKey:MaterialID" json:"material,omitempty"`
+	Email         string    `gorm:"type:text;not null;index" json:"email"` // Siempre en minúsculas
+	UsuarioID     *string   `gorm:"type:text;index" json:"usuario_id,omitempty"`
+	Usuario       *Usuario  `gorm:"foreignKey:UsuarioID;references:GoogleID" json:"usuario,omitempty"`
+	InvitadoPorID string    `gorm:"type:text;not null" json:"invitado_por_id"`
+	InvitadoPor   *Usuario  `gorm:"foreignKey:InvitadoPorID;references:GoogleID" json:"invitado_por,omitempty"`
+
+	Rol RolColaborador `gorm:"type:text;not null;default:'editor'" json:"rol"` // Rol que tendrá al aceptar
+
+	Estado       EstadoInvitacion `gorm:"type:text;not null;default:'pendiente';index" json:"estado"`
+	RespondidaEn *time.Time       `json:"respondida_en,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (InvitacionColaborador) TableName() string {
+	return "invitaciones_colaborador"
+}
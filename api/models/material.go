@@ -16,6 +16,13 @@ import (
 type Componente struct {
 	Elemento string `json:"elemento"`
 	Cantidad string `json:"cantidad"`
+
+	// Ingrediente del catálogo con proveedor y precio (opcional, si falta se busca por nombre)
+	IngredienteID *uuid.UUID `json:"ingrediente_id,omitempty"`
+
+	// Calculados al guardar a partir de Cantidad, en la unidad base de su magnitud (g, mL, u o %)
+	CantidadValor  *float64 `json:"cantidad_valor,omitempty" diff:"-"`
+	CantidadUnidad string   `json:"cantidad_unidad,omitempty" diff:"-"`
 }
 type JSONComponentes []Componente
 
@@ -33,6 +40,10 @@ type PropiedadMecanica struct {
 	Nombre string `json:"nombre"`
 	Valor  string `json:"valor"`
 	Unidad string `json:"unidad"`
+
+	// Calculados al guardar a partir de Valor y Unidad, en la unidad base de su magnitud (ver api/units)
+	ValorNormalizado  *float64 `json:"valor_normalizado,omitempty" diff:"-"`
+	UnidadNormalizada string   `json:"unidad_normalizada,omitempty" diff:"-"`
 }
 type JSONMecanicas []PropiedadMecanica
 
@@ -75,12 +86,17 @@ type Material struct {
 
 	Herramientas StringArray `gorm:"type:jsonb" json:"herramientas"`
 
+	// Clasificación: una categoría del árbol y etiquetas libres
+	CategoriaID *uuid.UUID  `gorm:"type:uuid;index" json:"categoria_id"`
+	Categoria   *Categoria  `gorm:"foreignKey:CategoriaID;constraint:OnDelete:SET NULL" json:"categoria,omitempty"`
+	Etiquetas   StringArray `gorm:"type:jsonb;not null;default:'[]'::jsonb" json:"etiquetas"`
+
 	// Relaciones
 	CreadorID string  `gorm:"type:text;not null" json:"creador_id"`
 	Creador   Usuario `gorm:"foreignKey:CreadorID;references:GoogleID" json:"creador"`
 
-	DerivadoDe uuid.UUID `gorm:"type:uuid;default:null" json:"derivado_de"`
-	Estado     bool      `gorm:"default:false" json:"estado"`
+	DerivadoDe uuid.UUID      `gorm:"type:uuid;default:null" json:"derivado_de"`
+	Estado     EstadoMaterial `gorm:"type:text;not null;default:'borrador';index" json:"estado"`
 
 	Colaboradores []Usuario `gorm:"many2many:material_colaboradores;joinForeignKey:MaterialID;joinReferences:UsuarioID;references:GoogleID" json:"colaboradores"`
 
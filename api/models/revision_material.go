@@ -0,0 +1,144 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- SNAPSHOT DEL CONTENIDO ---
+
+// SnapshotPaso es un paso dentro de un snapshot
+type SnapshotPaso struct {
+	OrdenPaso   int    `json:"orden_paso"`
+	Descripcion string `json:"descripcion"`
+	URLImagen   string `json:"url_imagen"`
+	URLVideo    string `json:"url_video"`
+}
+
+// SnapshotGaleria es una imagen de galería dentro de un snapshot
+type SnapshotGaleria struct {
+	URLImagen string `json:"url_imagen"`
+	Caption   string `json:"caption"`
+}
+
+// SnapshotMaterial es una copia completa del contenido editable de un material
+type SnapshotMaterial struct {
+	Nombre      string    `json:"nombre"`
+	Descripcion string    `json:"descripcion"`
+	DerivadoDe  uuid.UUID `json:"derivado_de"`
+
+	Composicion            JSONComponentes `json:"composicion"`
+	PropiedadesMecanicas   JSONMecanicas   `json:"prop_mecanicas"`
+	PropiedadesPerceptivas JSONGenerales   `json:"prop_perceptivas"`
+	PropiedadesEmocionales JSONGenerales   `json:"prop_emocionales"`
+	Herramientas           StringArray     `json:"herramientas"`
+
+	CategoriaID *uuid.UUID  `json:"categoria_id,omitempty"`
+	Etiquetas   StringArray `json:"etiquetas"`
+
+	Pasos   []SnapshotPaso    `json:"pasos"`
+	Galeria []SnapshotGaleria `json:"galeria"`
+}
+
+func (s SnapshotMaterial) Value() (driver.Value, error) { return json.Marshal(s) }
+func (s *SnapshotMaterial) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+// SnapshotDe copia el contenido actual del material (requiere Pasos y Galeria precargados)
+func SnapshotDe(m Material) SnapshotMaterial {
+	snap := SnapshotMaterial{
+		Nombre:                 m.Nombre,
+		Descripcion:            m.Descripcion,
+		DerivadoDe:             m.DerivadoDe,
+		Composicion:            m.Composicion,
+		PropiedadesMecanicas:   m.PropiedadesMecanicas,
+		PropiedadesPerceptivas: m.PropiedadesPerceptivas,
+		PropiedadesEmocionales: m.PropiedadesEmocionales,
+		Herramientas:           m.Herramientas,
+		CategoriaID:            m.CategoriaID,
+		Etiquetas:              m.Etiquetas,
+		Pasos:                  []SnapshotPaso{},
+		Galeria:                []SnapshotGaleria{},
+	}
+
+	for _, p := range m.Pasos {
+		snap.Pasos = append(snap.Pasos, SnapshotPaso{
+			OrdenPaso:   p.OrdenPaso,
+			Descripcion: p.Descripcion,
+			URLImagen:   p.URLImagen,
+			URLVideo:    p.URLVideo,
+		})
+	}
+	for _, g := range m.Galeria {
+		snap.Galeria = append(snap.Galeria, SnapshotGaleria{URLImagen: g.URLImagen, Caption: g.Caption})
+	}
+
+	return snap
+}
+
+// URLs devuelve todas las URLs de archivos que usa el snapshot
+func (s SnapshotMaterial) URLs() []string {
+	var urls []string
+	for _, g := range s.Galeria {
+		if g.URLImagen != "" {
+			urls = append(urls, g.URLImagen)
+		}
+	}
+	for _, p := range s.Pasos {
+		if p.URLImagen != "" {
+			urls = append(urls, p.URLImagen)
+		}
+		if p.URLVideo != "" {
+			urls = append(urls, p.URLVideo)
+		}
+	}
+	return urls
+}
+
+// --- REVISIONES ---
+
+// EstadoRevision es el estado de una revisión del material
+type EstadoRevision string
+
+const (
+	RevisionAplicada    EstadoRevision = "aplicada"    // Guardada directamente (material no publicado)
+	RevisionPendiente   EstadoRevision = "pendiente"   // Esperando moderación
+	RevisionAprobada    EstadoRevision = "aprobada"    // Aplicada a la versión pública
+	RevisionRechazada   EstadoRevision = "rechazada"   // Descartada por un admin
+	RevisionReemplazada EstadoRevision = "reemplazada" // El autor envió una revisión más nueva
+)
+
+// RevisionMaterial es una versión numerada del contenido de un material. Cada guardado crea una;
+// sobre un material aprobado quedan pendientes sin tocar la versión pública
+type RevisionMaterial struct {
+	ID         uuid.UUID        `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	MaterialID uuid.UUID        `gorm:"type:uuid;not null;uniqueIndex:idx_revision_material_numero" json:"material_id"`
+	Numero     int              `gorm:"not null;uniqueIndex:idx_revision_material_numero" json:"numero"`
+	Estado     EstadoRevision   `gorm:"type:text;not null;index" json:"estado"`
+	Contenido  SnapshotMaterial `gorm:"type:jsonb;not null" json:"contenido"`
+
+	AutorID string   `gorm:"type:text" json:"autor_id"` // Vacío si el autor se eliminó definitivamente
+	Autor   *Usuario `gorm:"foreignKey:AutorID;references:GoogleID" json:"autor,omitempty"`
+
+	RestauradaDe *int `json:"restaurada_de,omitempty"` // Número de la revisión de la que se restauró
+
+	RevisorID  string     `gorm:"type:text" json:"revisor_id,omitempty"`
+	Motivo     string     `gorm:"type:text" json:"motivo,omitempty"`
+	RevisadaEn *time.Time `json:"revisada_en,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (RevisionMaterial) TableName() string {
+	return "material_revisiones"
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EstadoSolicitud es el estado de una solicitud de cambio de rol
+type EstadoSolicitud string
+
+const (
+	SolicitudPendiente EstadoSolicitud = "pendiente"
+	SolicitudAprobada  EstadoSolicitud = "aprobada"
+	SolicitudRechazada EstadoSolicitud = "rechazada"
+)
+
+// SolicitudRol registra que un usuario pidió un rol (hoy solo colaborador) y cómo se resolvió.
+// Un usuario solo puede tener una solicitud pendiente a la vez
+type SolicitudRol struct {
+	ID            uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UsuarioID     string    `gorm:"type:text;not null;index" json:"usuario_id"`
+	Usuario       *Usuario  `gorm:"foreignKey:UsuarioID;references:GoogleID" json:"usuario,omitempty"`
+	RolSolicitado string    `gorm:"type:text;not null;default:'colaborador'" json:"rol_solicitado"`
+	Mensaje       string    `gorm:"type:text" json:"mensaje"` // Por qué lo pide
+
+	Estado     EstadoSolicitud `gorm:"type:text;not null;default:'pendiente';index" json:"estado"`
+	RevisorID  *string         `gorm:"type:text" json:"revisor_id,omitempty"`
+	Revisor    *Usuario        `gorm:"foreignKey:RevisorID;references:GoogleID" json:"revisor,omitempty"`
+	Respuesta  string          `gorm:"type:text" json:"respuesta,omitempty"` // Motivo del admin al resolver
+	RevisadaEn *time.Time      `json:"revisada_en,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (SolicitudRol) TableName() string {
+	return "solicitudes_rol"
+}
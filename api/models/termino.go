@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TipoTermino indica a qué catálogo pertenece un término del vocabulario controlado
+type TipoTermino string
+
+const (
+	TerminoElemento    TipoTermino = "elemento"    // Elementos de la composición
+	TerminoHerramienta TipoTermino = "herramienta" // Herramientas
+)
+
+var TiposTermino = []TipoTermino{TerminoElemento, TerminoHerramienta}
+
+// Valido indica si el tipo es uno de los catálogos conocidos
+func (t TipoTermino) Valido() bool {
+	for _, tipo := range TiposTermino {
+		if t == tipo {
+			return true
+		}
+	}
+	return false
+}
+
+// Termino es una entrada del vocabulario controlado: el nombre canónico de un elemento o herramienta
+// y las variantes que se consideran la misma cosa ("glicerol" -> "Glicerina")
+type Termino struct {
+	ID          uuid.UUID   `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Tipo        TipoTermino `gorm:"type:text;not null;uniqueIndex:idx_termino_tipo_nombre" json:"tipo"`
+	Nombre      string      `gorm:"type:text;not null;uniqueIndex:idx_termino_tipo_nombre" json:"nombre"` // Nombre canónico
+	Descripcion string      `gorm:"type:text" json:"descripcion"`
+	Sinonimos   StringArray `gorm:"type:jsonb" json:"sinonimos"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Termino) TableName() string {
+	return "vocabulario_terminos"
+}
@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Rutas bajo las que el propio router de Gin sirve los archivos locales
+const (
+	localPublicPrefix = "/storage/public"
+	localSignedPrefix = "/storage/sign"
+)
+
+// LocalStorage guarda los archivos en disco. Pensado para desarrollo, tests y equipos sin Supabase
+type LocalStorage struct {
+	root       string
+	baseURL    string
+	signingKey []byte
+}
+
+// NewLocalStorage crea el backend local. Si no hay signingKey se genera una aleatoria
+// (las URLs firmadas dejan de ser válidas al reiniciar el servidor)
+func NewLocalStorage(root, baseURL, signingKey string) (*LocalStorage, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("ruta de storage local inválida: %v", err)
+	}
+	if err := os.MkdirAll(absRoot, 0o755); err != nil {
+		return nil, fmt.Errorf("no se pudo crear el directorio de storage local: %v", err)
+	}
+
+	key := []byte(signingKey)
+	if len(key) == 0 {
+		log.Println("⚠️ STORAGE_SIGNING_KEY no configurada, usando una clave temporal para URLs firmadas")
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("error generando clave de firma: %v", err)
+		}
+	}
+
+	return &LocalStorage{
+		root:       absRoot,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		signingKey: key,
+	}, nil
+}
+
+// Put escribe el archivo en <root>/<bucket>/<path>
+func (s *LocalStorage) Put(ctx context.Context, bucket, path string, content io.Reader, contentType string) (string, error) {
+	fullPath, path, err := s.resolve(bucket, path)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+		return "", fmt.Errorf("error creando directorio: %v", err)
+	}
+
+	// Escribimos a un temporal y renombramos para no dejar archivos a medias
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), ".upload-*")
+	if err != nil {
+		return "", fmt.Errorf("error creando archivo temporal: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, content); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("error escribiendo archivo: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("error cerrando archivo: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), fullPath); err != nil {
+		return "", fmt.Errorf("error moviendo archivo: %v", err)
+	}
+
+	return s.PublicURL(bucket, path), nil
+}
+
+// Delete borra el archivo del disco
+func (s *LocalStorage) Delete(ctx context.Context, bucket, path string) error {
+	fullPath, _, err := s.resolve(bucket, path)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(fullPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error eliminando archivo: %v", err)
+	}
+	return nil
+}
+
+// PublicURL devuelve la URL con la que el router sirve el archivo
+func (s *LocalStorage) PublicURL(bucket, path string) string {
+	if cleaned, err := cleanObjectPath(path); err == nil {
+		path = cleaned
+	}
+	return fmt.Sprintf("%s%s/%s/%s", s.baseURL, localPublicPrefix, bucket, path)
+}
+
+// SignedURL genera una URL con expiración firmada con HMAC
+func (s *LocalStorage) SignedURL(ctx context.Context, bucket, path string, expiresIn time.Duration) (string, error) {
+	fullPath, path, err := s.resolve(bucket, path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(fullPath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+
+	expires := time.Now().Add(expiresIn).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("token", s.sign(bucket, path, expires))
+
+	return fmt.Sprintf("%s%s/%s/%s?%s", s.baseURL, localSignedPrefix, bucket, path, query.Encode()), nil
+}
+
+// Exists revisa si el archivo existe en disco
+func (s *LocalStorage) Exists(ctx context.Context, bucket, path string) (bool, error) {
+	fullPath, _, err := s.resolve(bucket, path)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := os.Stat(fullPath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// List recorre el directorio del bucket
+func (s *LocalStorage) List(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error) {
+	bucketDir, _, err := s.resolve(bucket, ".keep")
+	if err != nil {
+		return nil, err
+	}
+	bucketDir = filepath.Dir(bucketDir)
+	prefix = strings.TrimPrefix(prefix, "/")
+
+	var objects []ObjectInfo
+	err = filepath.WalkDir(bucketDir, func(fullPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(bucketDir, fullPath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !strings.HasPrefix(rel, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, ObjectInfo{Path: rel, Size: info.Size(), UpdatedAt: info.ModTime().UTC()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listando archivos: %v", err)
+	}
+
+	return objects, nil
+}
+
+// ObjectPath reconoce las URLs públicas generadas por PublicURL
+func (s *LocalStorage) ObjectPath(bucket, publicURL string) (string, bool) {
+	return objectPathFromURL(fmt.Sprintf("%s%s/%s/", s.baseURL, localPublicPrefix, bucket), publicURL)
+}
+
+// RegisterRoutes expone los archivos públicos y firmados en el router
+func (s *LocalStorage) RegisterRoutes(router gin.IRouter) {
+	router.GET(localPublicPrefix+"/:bucket/*path", s.servePublic)
+	router.GET(localSignedPrefix+"/:bucket/*path", s.serveSigned)
+}
+
+func (s *LocalStorage) servePublic(c *gin.Context) {
+	fullPath, _, err := s.resolve(c.Param("bucket"), c.Param("path"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ruta inválida"})
+		return
+	}
+	s.serveFile(c, fullPath)
+}
+
+func (s *LocalStorage) serveSigned(c *gin.Context) {
+	bucket := c.Param("bucket")
+	fullPath, path, err := s.resolve(bucket, c.Param("path"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ruta inválida"})
+		return
+	}
+
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		c.JSON(http.StatusForbidden, gin.H{"error": "URL firmada expirada o inválida"})
+		return
+	}
+	if !hmac.Equal([]byte(c.Query("token")), []byte(s.sign(bucket, path, expires))) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Firma inválida"})
+		return
+	}
+
+	s.serveFile(c, fullPath)
+}
+
+func (s *LocalStorage) serveFile(c *gin.Context, fullPath string) {
+	info, err := os.Stat(fullPath)
+	if err != nil || info.IsDir() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Archivo no encontrado"})
+		return
+	}
+	c.File(fullPath)
+}
+
+// resolve devuelve la ruta absoluta en disco y la ruta normalizada del objeto
+func (s *LocalStorage) resolve(bucket, path string) (string, string, error) {
+	if bucket == "" || strings.ContainsAny(bucket, `/\`) || bucket == "." || bucket == ".." {
+		return "", "", fmt.Errorf("bucket inválido: '%s'", bucket)
+	}
+	path, err := cleanObjectPath(path)
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.Join(s.root, bucket, filepath.FromSlash(path)), path, nil
+}
+
+func (s *LocalStorage) sign(bucket, path string, expires int64) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	fmt.Fprintf(mac, "%s/%s:%d", bucket, path, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
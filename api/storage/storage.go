@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"TT-SEM-2-BACK/api/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BucketPasos es el bucket donde se guardan las imágenes y videos de los materiales
+const BucketPasos = "pasos-bucket"
+
+// ErrNotFound se devuelve cuando el objeto solicitado no existe en el storage
+var ErrNotFound = errors.New("objeto no encontrado en el storage")
+
+// Storage define las operaciones que necesitamos de cualquier backend de archivos
+type Storage interface {
+	// Put guarda el contenido en bucket/path (sobrescribe si existe) y devuelve la URL pública
+	Put(ctx context.Context, bucket, path string, content io.Reader, contentType string) (string, error)
+	// Delete elimina el objeto. No falla si el objeto ya no existe
+	Delete(ctx context.Context, bucket, path string) error
+	// PublicURL construye la URL pública del objeto (no verifica que exista)
+	PublicURL(bucket, path string) string
+	// SignedURL genera una URL temporal firmada válida durante expiresIn
+	SignedURL(ctx context.Context, bucket, path string, expiresIn time.Duration) (string, error)
+	// Exists indica si el objeto existe
+	Exists(ctx context.Context, bucket, path string) (bool, error)
+	// List devuelve recursivamente los objetos del bucket cuya ruta empieza con prefix
+	List(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error)
+	// ObjectPath obtiene la ruta del objeto a partir de una URL pública de este backend
+	ObjectPath(bucket, publicURL string) (string, bool)
+}
+
+// ObjectInfo describe un objeto guardado en el storage
+type ObjectInfo struct {
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Variables globales para el Singleton
+var (
+	storageInstance Storage
+	storageOnce     sync.Once
+	storageErr      error
+)
+
+// GetStorage devuelve la instancia ÚNICA del backend configurado en STORAGE_BACKEND
+func GetStorage() (Storage, error) {
+	storageOnce.Do(func() {
+		switch backend := config.StorageBackend(); backend {
+		case "supabase":
+			storageInstance, storageErr = NewSupabaseStorage(
+				config.GetEnv("SUPABASE_PROJECT", ""),
+				config.GetEnv("SUPABASE_SERVICE_KEY", ""),
+			)
+		case "local":
+			storageInstance, storageErr = NewLocalStorage(
+				config.StorageLocalDir(),
+				config.StoragePublicURL(),
+				config.GetEnv("STORAGE_SIGNING_KEY", ""),
+			)
+		default:
+			storageErr = fmt.Errorf("STORAGE_BACKEND desconocido: '%s' (usa 'supabase' o 'local')", backend)
+		}
+
+		if storageErr == nil {
+			log.Printf("✅ Storage configurado: %s", config.StorageBackend())
+		}
+	})
+
+	return storageInstance, storageErr
+}
+
+// UploadFile sube un archivo de un form multipart detectando su MIME type
+func UploadFile(ctx context.Context, store Storage, fileHeader *multipart.FileHeader, bucket, path string) (string, error) {
+	// 1. Abrir el archivo
+	file, err := fileHeader.Open()
+	if err != nil {
+		return "", fmt.Errorf("no se pudo abrir el archivo del form: %v", err)
+	}
+	defer file.Close()
+
+	// 2. Leer el contenido del archivo
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return "", fmt.Errorf("error leyendo el archivo: %v", err)
+	}
+
+	// 3. Detectar MIME type
+	mimeType := DetectContentType(fileHeader.Filename, fileBytes)
+
+	log.Printf("Subiendo archivo: %s (MIME: %s, Size: %d bytes)", fileHeader.Filename, mimeType, len(fileBytes))
+
+	return store.Put(ctx, bucket, path, bytes.NewReader(fileBytes), mimeType)
+}
+
+// DetectContentType detecta el MIME type usando el contenido y, si no alcanza, la extensión
+func DetectContentType(filename string, content []byte) string {
+	mimeType := http.DetectContentType(content)
+
+	if mimeType == "application/octet-stream" {
+		switch strings.ToLower(filepath.Ext(filename)) {
+		case ".jpg", ".jpeg":
+			mimeType = "image/jpeg"
+		case ".png":
+			mimeType = "image/png"
+		case ".gif":
+			mimeType = "image/gif"
+		case ".webp":
+			mimeType = "image/webp"
+		case ".mp4":
+			mimeType = "video/mp4"
+		case ".mov":
+			mimeType = "video/quicktime"
+		}
+	}
+
+	return mimeType
+}
+
+// SafeFilename reemplaza los espacios del nombre de archivo para usarlo en rutas
+func SafeFilename(filename string) string {
+	return strings.ReplaceAll(filename, " ", "_")
+}
+
+// objectPathFromURL extrae la ruta del objeto si la URL empieza con el prefijo público del bucket
+func objectPathFromURL(publicPrefix, publicURL string) (string, bool) {
+	if publicURL == "" || !strings.HasPrefix(publicURL, publicPrefix) {
+		return "", false
+	}
+	path := strings.TrimPrefix(publicURL, publicPrefix)
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	path, err := cleanObjectPath(path)
+	if err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// cleanObjectPath normaliza la ruta de un objeto y evita que escape del bucket
+func cleanObjectPath(path string) (string, error) {
+	cleaned := filepath.ToSlash(filepath.Clean("/" + path))
+	cleaned = strings.TrimPrefix(cleaned, "/")
+	if cleaned == "" || cleaned == "." {
+		return "", fmt.Errorf("ruta de objeto inválida: '%s'", path)
+	}
+	return cleaned, nil
+}
+
+// RegisterRoutes registra en el router las rutas que necesite el backend (solo el local sirve archivos)
+func RegisterRoutes(router gin.IRouter) error {
+	store, err := GetStorage()
+	if err != nil {
+		return err
+	}
+	if local, ok := store.(*LocalStorage); ok {
+		local.RegisterRoutes(router)
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SupabaseStorage guarda los archivos en Supabase Storage usando su API HTTP
+type SupabaseStorage struct {
+	baseURL    string
+	serviceKey string
+	client     *http.Client
+}
+
+// NewSupabaseStorage crea el backend de Supabase a partir del proyecto y la service key
+func NewSupabaseStorage(project, serviceKey string) (*SupabaseStorage, error) {
+	if project == "" || serviceKey == "" {
+		return nil, fmt.Errorf("variables de entorno SUPABASE_PROJECT o SUPABASE_SERVICE_KEY no configuradas")
+	}
+
+	return &SupabaseStorage{
+		baseURL:    fmt.Sprintf("https://%s.supabase.co/storage/v1", project),
+		serviceKey: serviceKey,
+		client:     &http.Client{Timeout: 2 * time.Minute},
+	}, nil
+}
+
+// Put sube el archivo al bucket (con upsert) y devuelve su URL pública
+func (s *SupabaseStorage) Put(ctx context.Context, bucket, path string, content io.Reader, contentType string) (string, error) {
+	path, err := cleanObjectPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	fileBytes, err := io.ReadAll(content)
+	if err != nil {
+		return "", fmt.Errorf("error leyendo el archivo: %v", err)
+	}
+
+	uploadURL := fmt.Sprintf("%s/object/%s/%s", s.baseURL, bucket, path)
+	log.Printf("Upload URL: %s", uploadURL)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL, bytes.NewReader(fileBytes))
+	if err != nil {
+		return "", fmt.Errorf("error creando request a supabase: %v", err)
+	}
+
+	// Headers para Supabase Storage
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Content-Length", fmt.Sprintf("%d", len(fileBytes)))
+	req.Header.Set("x-upsert", "true")
+
+	bodyStr, status, err := s.do(req)
+	if err != nil {
+		return "", fmt.Errorf("error al enviar request a supabase: %v", err)
+	}
+
+	if status != http.StatusOK && status != http.StatusCreated {
+		log.Printf("Error en respuesta de Supabase: status %d, body: %s", status, bodyStr)
+		return "", fmt.Errorf("error al subir archivo (status %d): %s", status, bodyStr)
+	}
+
+	log.Printf("Archivo subido exitosamente. Response: %s", bodyStr)
+
+	return s.PublicURL(bucket, path), nil
+}
+
+// Delete elimina un objeto del bucket
+func (s *SupabaseStorage) Delete(ctx context.Context, bucket, path string) error {
+	path, err := cleanObjectPath(path)
+	if err != nil {
+		return err
+	}
+
+	deleteURL := fmt.Sprintf("%s/object/%s/%s", s.baseURL, bucket, path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, deleteURL, nil)
+	if err != nil {
+		return fmt.Errorf("error creando request a supabase: %v", err)
+	}
+
+	bodyStr, status, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("error al enviar request a supabase: %v", err)
+	}
+
+	// Un 404 significa que ya no existe, que es lo que queríamos
+	if status != http.StatusOK && status != http.StatusNotFound {
+		return fmt.Errorf("error al eliminar archivo (status %d): %s", status, bodyStr)
+	}
+
+	return nil
+}
+
+// PublicURL construye la URL pública del objeto (el bucket debe ser público)
+func (s *SupabaseStorage) PublicURL(bucket, path string) string {
+	if cleaned, err := cleanObjectPath(path); err == nil {
+		path = cleaned
+	}
+	return fmt.Sprintf("%s/object/public/%s/%s", s.baseURL, bucket, path)
+}
+
+// SignedURL pide a Supabase una URL firmada temporal
+func (s *SupabaseStorage) SignedURL(ctx context.Context, bucket, path string, expiresIn time.Duration) (string, error) {
+	path, err := cleanObjectPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	payload, _ := json.Marshal(map[string]int{"expiresIn": int(expiresIn.Seconds())})
+	signURL := fmt.Sprintf("%s/object/sign/%s/%s", s.baseURL, bucket, path)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, signURL, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("error creando request a supabase: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	bodyStr, status, err := s.do(req)
+	if err != nil {
+		return "", fmt.Errorf("error al enviar request a supabase: %v", err)
+	}
+
+	if status == http.StatusNotFound {
+		return "", ErrNotFound
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("error al firmar URL (status %d): %s", status, bodyStr)
+	}
+
+	var resp struct {
+		SignedURL string `json:"signedURL"`
+	}
+	if err := json.Unmarshal([]byte(bodyStr), &resp); err != nil || resp.SignedURL == "" {
+		return "", fmt.Errorf("respuesta inválida al firmar URL: %s", bodyStr)
+	}
+
+	// Supabase devuelve la ruta relativa a /storage/v1
+	return s.baseURL + resp.SignedURL, nil
+}
+
+// Exists consulta los metadatos del objeto con un HEAD
+func (s *SupabaseStorage) Exists(ctx context.Context, bucket, path string) (bool, error) {
+	path, err := cleanObjectPath(path)
+	if err != nil {
+		return false, err
+	}
+
+	headURL := fmt.Sprintf("%s/object/%s/%s", s.baseURL, bucket, path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, headURL, nil)
+	if err != nil {
+		return false, fmt.Errorf("error creando request a supabase: %v", err)
+	}
+
+	_, status, err := s.do(req)
+	if err != nil {
+		return false, fmt.Errorf("error al enviar request a supabase: %v", err)
+	}
+
+	switch status {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound, http.StatusBadRequest:
+		// Supabase responde 400 "Object not found" en algunas versiones
+		return false, nil
+	default:
+		return false, fmt.Errorf("error consultando archivo (status %d)", status)
+	}
+}
+
+// List recorre las "carpetas" de Supabase, que solo lista un nivel por llamada
+func (s *SupabaseStorage) List(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	pending := []string{strings.Trim(prefix, "/")}
+
+	for len(pending) > 0 {
+		folder := pending[0]
+		pending = pending[1:]
+
+		for offset := 0; ; offset += supabaseListLimit {
+			entries, err := s.listFolder(ctx, bucket, folder, offset)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, entry := range entries {
+				fullPath := entry.Name
+				if folder != "" {
+					fullPath = folder + "/" + entry.Name
+				}
+
+				// Las carpetas vienen sin id
+				if entry.ID == nil {
+					pending = append(pending, fullPath)
+					continue
+				}
+
+				objects = append(objects, ObjectInfo{
+					Path:      fullPath,
+					Size:      entry.Metadata.Size,
+					UpdatedAt: entry.UpdatedAt,
+				})
+			}
+
+			if len(entries) < supabaseListLimit {
+				break
+			}
+		}
+	}
+
+	return objects, nil
+}
+
+// supabaseListLimit es el tamaño de página al listar objetos
+const supabaseListLimit = 1000
+
+type supabaseListEntry struct {
+	Name      string    `json:"name"`
+	ID        *string   `json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Metadata  struct {
+		Size int64 `json:"size"`
+	} `json:"metadata"`
+}
+
+func (s *SupabaseStorage) listFolder(ctx context.Context, bucket, folder string, offset int) ([]supabaseListEntry, error) {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"prefix": folder,
+		"limit":  supabaseListLimit,
+		"offset": offset,
+		"sortBy": map[string]string{"column": "name", "order": "asc"},
+	})
+
+	listURL := fmt.Sprintf("%s/object/list/%s", s.baseURL, bucket)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, listURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("error creando request a supabase: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	bodyStr, status, err := s.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error al enviar request a supabase: %v", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("error listando archivos (status %d): %s", status, bodyStr)
+	}
+
+	var entries []supabaseListEntry
+	if err := json.Unmarshal([]byte(bodyStr), &entries); err != nil {
+		return nil, fmt.Errorf("respuesta inválida al listar archivos: %v", err)
+	}
+	return entries, nil
+}
+
+// ObjectPath reconoce las URLs públicas generadas por PublicURL
+func (s *SupabaseStorage) ObjectPath(bucket, publicURL string) (string, bool) {
+	return objectPathFromURL(fmt.Sprintf("%s/object/public/%s/", s.baseURL, bucket), publicURL)
+}
+
+// do agrega la autenticación, ejecuta la petición y devuelve el body como string
+func (s *SupabaseStorage) do(req *http.Request) (string, int, error) {
+	req.Header.Set("Authorization", "Bearer "+s.serviceKey)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	return string(bodyBytes), resp.StatusCode, nil
+}
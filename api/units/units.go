@@ -0,0 +1,300 @@
+// Package units normaliza los valores de las propiedades mecánicas: interpreta el número que escribió
+// el autor y lo convierte a la unidad base de su magnitud para poder filtrar y ordenar por rangos.
+package units
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Magnitud agrupa las unidades que se pueden convertir entre sí
+type Magnitud string
+
+const (
+	Presion      Magnitud = "presion" // Esfuerzo, resistencia, módulo
+	Densidad     Magnitud = "densidad"
+	Porcentaje   Magnitud = "porcentaje" // Elongación, absorción, humedad
+	Temperatura  Magnitud = "temperatura"
+	Longitud     Magnitud = "longitud"
+	Masa         Magnitud = "masa"
+	Tiempo       Magnitud = "tiempo"
+	Energia      Magnitud = "energia"
+	Volumen      Magnitud = "volumen"
+	Conteo       Magnitud = "conteo" // Piezas, hojas, unidades sueltas
+	DurezaShoreA Magnitud = "dureza_shore_a"
+	DurezaShoreD Magnitud = "dureza_shore_d"
+	Adimensional Magnitud = "adimensional"
+)
+
+var (
+	ErrValorInvalido         = errors.New("valor no numérico")
+	ErrUnidadDesconocida     = errors.New("unidad desconocida")
+	ErrUnidadesIncompatibles = errors.New("unidades incompatibles")
+)
+
+// Unidad es una unidad registrada. valor_base = valor * Factor + Offset
+type Unidad struct {
+	Simbolo  string   `json:"simbolo"`
+	Nombre   string   `json:"nombre"`
+	Magnitud Magnitud `json:"magnitud"`
+	Factor   float64  `json:"-"`
+	Offset   float64  `json:"-"`
+	Base     bool     `json:"base"`
+	alias    []string
+}
+
+// ABase convierte un valor en esta unidad a la unidad base de su magnitud
+func (u Unidad) ABase(v float64) float64 { return v*u.Factor + u.Offset }
+
+// DesdeBase convierte un valor en la unidad base a esta unidad
+func (u Unidad) DesdeBase(v float64) float64 { return (v - u.Offset) / u.Factor }
+
+// registro contiene todas las unidades conocidas. La primera de cada magnitud es la base
+var registro = []Unidad{
+	{Simbolo: "MPa", Nombre: "megapascal", Magnitud: Presion, Factor: 1, Base: true, alias: []string{"n/mm2", "mpa"}},
+	{Simbolo: "Pa", Nombre: "pascal", Magnitud: Presion, Factor: 1e-6, alias: []string{"n/m2"}},
+	{Simbolo: "kPa", Nombre: "kilopascal", Magnitud: Presion, Factor: 1e-3},
+	{Simbolo: "GPa", Nombre: "gigapascal", Magnitud: Presion, Factor: 1e3},
+	{Simbolo: "bar", Nombre: "bar", Magnitud: Presion, Factor: 0.1},
+	{Simbolo: "psi", Nombre: "libra por pulgada cuadrada", Magnitud: Presion, Factor: 0.00689475729, alias: []string{"lb/in2", "lbf/in2"}},
+	{Simbolo: "ksi", Nombre: "kilolibra por pulgada cuadrada", Magnitud: Presion, Factor: 6.89475729},
+
+	{Simbolo: "kg/m³", Nombre: "kilogramo por metro cúbico", Magnitud: Densidad, Factor: 1, Base: true, alias: []string{"kg/m3", "g/l"}},
+	{Simbolo: "g/cm³", Nombre: "gramo por centímetro cúbico", Magnitud: Densidad, Factor: 1000, alias: []string{"g/cm3", "g/cc", "g/ml"}},
+	{Simbolo: "lb/ft³", Nombre: "libra por pie cúbico", Magnitud: Densidad, Factor: 16.0184634, alias: []string{"lb/ft3"}},
+
+	{Simbolo: "%", Nombre: "porcentaje", Magnitud: Porcentaje, Factor: 1, Base: true, alias: []string{"porciento", "por ciento"}},
+
+	{Simbolo: "°C", Nombre: "grado Celsius", Magnitud: Temperatura, Factor: 1, Base: true, alias: []string{"c", "ºc", "grados c", "celsius"}},
+	{Simbolo: "K", Nombre: "kelvin", Magnitud: Temperatura, Factor: 1, Offset: -273.15, alias: []string{"kelvin"}},
+	{Simbolo: "°F", Nombre: "grado Fahrenheit", Magnitud: Temperatura, Factor: 5.0 / 9.0, Offset: -32 * 5.0 / 9.0, alias: []string{"f", "ºf", "fahrenheit"}},
+
+	{Simbolo: "mm", Nombre: "milímetro", Magnitud: Longitud, Factor: 1, Base: true},
+	{Simbolo: "µm", Nombre: "micrómetro", Magnitud: Longitud, Factor: 1e-3, alias: []string{"um", "μm", "micras"}},
+	{Simbolo: "cm", Nombre: "centímetro", Magnitud: Longitud, Factor: 10},
+	{Simbolo: "m", Nombre: "metro", Magnitud: Longitud, Factor: 1000},
+	{Simbolo: "in", Nombre: "pulgada", Magnitud: Longitud, Factor: 25.4, alias: []string{"pulg", "\""}},
+
+	{Simbolo: "g", Nombre: "gramo", Magnitud: Masa, Factor: 1, Base: true, alias: []string{"gr", "grs", "gramos"}},
+	{Simbolo: "mg", Nombre: "miligramo", Magnitud: Masa, Factor: 1e-3},
+	{Simbolo: "kg", Nombre: "kilogramo", Magnitud: Masa, Factor: 1000, alias: []string{"kilo", "kilos", "kilogramos"}},
+	{Simbolo: "lb", Nombre: "libra", Magnitud: Masa, Factor: 453.59237, alias: []string{"libras"}},
+	{Simbolo: "oz", Nombre: "onza", Magnitud: Masa, Factor: 28.349523125, alias: []string{"onzas"}},
+
+	{Simbolo: "mL", Nombre: "mililitro", Magnitud: Volumen, Factor: 1, Base: true, alias: []string{"cc", "cm3", "mililitros"}},
+	{Simbolo: "L", Nombre: "litro", Magnitud: Volumen, Factor: 1000, alias: []string{"lt", "lts", "litro", "litros"}},
+	{Simbolo: "taza", Nombre: "taza", Magnitud: Volumen, Factor: 240, alias: []string{"tazas"}},
+	{Simbolo: "cda", Nombre: "cucharada", Magnitud: Volumen, Factor: 15, alias: []string{"cdas", "cucharada", "cucharadas"}},
+	{Simbolo: "cdta", Nombre: "cucharadita", Magnitud: Volumen, Factor: 5, alias: []string{"cdtas", "cdita", "cditas", "cucharadita", "cucharaditas"}},
+	{Simbolo: "gota", Nombre: "gota", Magnitud: Volumen, Factor: 0.05, alias: []string{"gotas"}},
+
+	{Simbolo: "u", Nombre: "unidad", Magnitud: Conteo, Factor: 1, Base: true, alias: []string{"unidad", "unidades", "pza", "pzas", "pieza", "piezas", "hoja", "hojas"}},
+
+	{Simbolo: "s", Nombre: "segundo", Magnitud: Tiempo, Factor: 1, Base: true, alias: []string{"seg", "segundos"}},
+	{Simbolo: "min", Nombre: "minuto", Magnitud: Tiempo, Factor: 60, alias: []string{"minutos"}},
+	{Simbolo: "h", Nombre: "hora", Magnitud: Tiempo, Factor: 3600, alias: []string{"hr", "hrs", "horas"}},
+	{Simbolo: "d", Nombre: "día", Magnitud: Tiempo, Factor: 86400, alias: []string{"dia", "dias", "días"}},
+
+	{Simbolo: "J", Nombre: "joule", Magnitud: Energia, Factor: 1, Base: true},
+	{Simbolo: "kJ", Nombre: "kilojoule", Magnitud: Energia, Factor: 1000},
+
+	{Simbolo: "Shore A", Nombre: "dureza Shore A", Magnitud: DurezaShoreA, Factor: 1, Base: true, alias: []string{"sha", "shorea"}},
+	{Simbolo: "Shore D", Nombre: "dureza Shore D", Magnitud: DurezaShoreD, Factor: 1, Base: true, alias: []string{"shd", "shored"}},
+
+	{Simbolo: "", Nombre: "sin unidad", Magnitud: Adimensional, Factor: 1, Base: true, alias: []string{"-", "adimensional"}},
+}
+
+// porClave indexa el registro por símbolo y alias normalizados
+var porClave = func() map[string]Unidad {
+	m := make(map[string]Unidad)
+	for _, u := range registro {
+		m[claveUnidad(u.Simbolo)] = u
+		for _, a := range u.alias {
+			m[claveUnidad(a)] = u
+		}
+	}
+	return m
+}()
+
+// claveUnidad normaliza un símbolo para buscarlo: sin espacios, minúsculas y exponentes como dígitos.
+// Las unidades donde la mayúscula importa (Pa vs pa) no chocan en el registro
+func claveUnidad(s string) string {
+	r := strings.NewReplacer("³", "3", "²", "2", "º", "°", "μ", "µ", " ", "", "·", "", "^", "")
+	s = r.Replace(strings.TrimSpace(s))
+	return strings.ToLower(s)
+}
+
+// Buscar devuelve la unidad registrada para un símbolo o alias
+func Buscar(simbolo string) (Unidad, bool) {
+	u, ok := porClave[claveUnidad(simbolo)]
+	return u, ok
+}
+
+// BaseDe devuelve la unidad base de una magnitud
+func BaseDe(m Magnitud) Unidad {
+	for _, u := range registro {
+		if u.Magnitud == m && u.Base {
+			return u
+		}
+	}
+	return Unidad{Magnitud: m, Factor: 1}
+}
+
+// Registro devuelve las unidades conocidas agrupadas por magnitud
+func Registro() map[Magnitud][]Unidad {
+	grupos := make(map[Magnitud][]Unidad)
+	for _, u := range registro {
+		grupos[u.Magnitud] = append(grupos[u.Magnitud], u)
+	}
+	for _, us := range grupos {
+		sort.SliceStable(us, func(i, j int) bool { return us[i].Base && !us[j].Base })
+	}
+	return grupos
+}
+
+// Convertir pasa un valor entre dos unidades compatibles
+func Convertir(v float64, desde, hasta string) (float64, error) {
+	origen, ok := Buscar(desde)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnidadDesconocida, desde)
+	}
+	destino, ok := Buscar(hasta)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnidadDesconocida, hasta)
+	}
+	if origen.Magnitud != destino.Magnitud {
+		return 0, fmt.Errorf("%w: %s y %s", ErrUnidadesIncompatibles, origen.Simbolo, destino.Simbolo)
+	}
+	return destino.DesdeBase(origen.ABase(v)), nil
+}
+
+// Medida es un valor ya convertido a la unidad base de su magnitud
+type Medida struct {
+	Valor    float64
+	Unidad   Unidad // Unidad base
+	Original Unidad // Unidad en la que lo escribió el autor
+}
+
+// reNumeroUnidad separa "25,4 MPa" en número y unidad
+var reNumeroUnidad = regexp.MustCompile(`^([-+]?[0-9][0-9.,]*(?:[eE][-+]?[0-9]+)?)\s*(.*)$`)
+
+// reFraccion reconoce "1/2" y "1 1/2" al inicio del valor
+var reFraccion = regexp.MustCompile(`^(?:(\d+)\s+)?(\d+)/(\d+)`)
+
+// fraccionADecimal reemplaza una fracción inicial por su valor decimal ("1 1/2 tazas" -> "1.5 tazas")
+func fraccionADecimal(s string) string {
+	m := reFraccion.FindStringSubmatch(s)
+	if m == nil {
+		return s
+	}
+	num, _ := strconv.ParseFloat(m[2], 64)
+	den, _ := strconv.ParseFloat(m[3], 64)
+	if den == 0 {
+		return s
+	}
+	v := num / den
+	if m[1] != "" {
+		entero, _ := strconv.ParseFloat(m[1], 64)
+		v += entero
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64) + s[len(m[0]):]
+}
+
+// Normalizar interpreta el valor y la unidad que escribió el autor. Si la unidad viene vacía
+// se busca dentro del valor ("25 MPa"). Acepta coma o punto decimal y separador de miles
+func Normalizar(valor, unidad string) (Medida, error) {
+	valor = strings.TrimSpace(valor)
+	valor = strings.TrimLeft(valor, "~≈")
+	valor = fraccionADecimal(strings.TrimSpace(valor))
+
+	partes := reNumeroUnidad.FindStringSubmatch(valor)
+	if partes == nil {
+		return Medida{}, fmt.Errorf("%w: '%s'", ErrValorInvalido, valor)
+	}
+	if resto := strings.TrimSpace(partes[2]); resto != "" {
+		if strings.TrimSpace(unidad) != "" && claveUnidad(resto) != claveUnidad(unidad) {
+			return Medida{}, fmt.Errorf("%w: '%s'", ErrValorInvalido, valor)
+		}
+		unidad = resto
+	}
+
+	numero, err := parsearNumero(partes[1])
+	if err != nil {
+		return Medida{}, fmt.Errorf("%w: '%s'", ErrValorInvalido, valor)
+	}
+
+	u, ok := Buscar(unidad)
+	if !ok {
+		return Medida{}, fmt.Errorf("%w: '%s'", ErrUnidadDesconocida, unidad)
+	}
+
+	return Medida{Valor: u.ABase(numero), Unidad: BaseDe(u.Magnitud), Original: u}, nil
+}
+
+// parsearNumero acepta "1234.5", "1234,5", "1.234,5" y "1,234.5"
+func parsearNumero(s string) (float64, error) {
+	puntos, comas := strings.Count(s, "."), strings.Count(s, ",")
+	switch {
+	case puntos > 0 && comas > 0:
+		// El último separador es el decimal
+		if strings.LastIndex(s, ",") > strings.LastIndex(s, ".") {
+			s = strings.ReplaceAll(s, ".", "")
+			s = strings.Replace(s, ",", ".", 1)
+		} else {
+			s = strings.ReplaceAll(s, ",", "")
+		}
+	case comas == 1:
+		s = strings.Replace(s, ",", ".", 1)
+	case comas > 1:
+		s = strings.ReplaceAll(s, ",", "")
+	case puntos > 1:
+		s = strings.ReplaceAll(s, ".", "")
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, ErrValorInvalido
+	}
+	return v, nil
+}
+
+// NormalizarPropiedad devuelve el valor en la unidad base y el símbolo de esa base, listos para
+// guardarse junto a la propiedad. Un valor vacío no es un error: simplemente no se normaliza
+func NormalizarPropiedad(valor, unidad string) (*float64, string, error) {
+	if strings.TrimSpace(valor) == "" {
+		return nil, "", nil
+	}
+	m, err := Normalizar(valor, unidad)
+	if err != nil {
+		return nil, "", err
+	}
+	return &m.Valor, m.Unidad.Simbolo, nil
+}
+
+// magnitudesCantidad son las magnitudes válidas para la cantidad de un componente de la composición
+var magnitudesCantidad = map[Magnitud]bool{Masa: true, Volumen: true, Conteo: true, Porcentaje: true}
+
+// NormalizarCantidad interpreta la cantidad de un componente ("200 g", "1 1/2 tazas", "3", "40 %").
+// Un número sin unidad se toma como conteo. Devuelve nil si la cantidad viene vacía
+func NormalizarCantidad(cantidad string) (*Medida, error) {
+	if strings.TrimSpace(cantidad) == "" {
+		return nil, nil
+	}
+	m, err := Normalizar(cantidad, "")
+	if err != nil {
+		return nil, err
+	}
+	if m.Unidad.Magnitud == Adimensional {
+		conteo := BaseDe(Conteo)
+		m.Unidad, m.Original = conteo, conteo
+	}
+	if !magnitudesCantidad[m.Unidad.Magnitud] {
+		return nil, fmt.Errorf("%w: '%s' no es masa, volumen, conteo ni porcentaje", ErrUnidadesIncompatibles, m.Original.Simbolo)
+	}
+	return &m, nil
+}
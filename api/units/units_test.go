@@ -0,0 +1,167 @@
+package units
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func casiIgual(a, b float64) bool { return math.Abs(a-b) < 1e-9*math.Max(1, math.Abs(b)) }
+
+func TestParsearNumero(t *testing.T) {
+	casos := []struct {
+		entrada string
+		quiere  float64
+	}{
+		{"1234.5", 1234.5},
+		{"1234,5", 1234.5},
+		{"1.234,5", 1234.5},    // Punto de miles, coma decimal
+		{"1,234.5", 1234.5},    // Coma de miles, punto decimal
+		{"1.234.567", 1234567}, // Varios puntos: solo pueden ser de miles
+		{"1,234,567", 1234567},
+		{"1.234.567,89", 1234567.89},
+		{"1,5", 1.5},
+		{"-3,25", -3.25},
+		{"2e3", 2000},
+	}
+	for _, c := range casos {
+		got, err := parsearNumero(c.entrada)
+		if err != nil {
+			t.Errorf("parsearNumero(%q): error inesperado %v", c.entrada, err)
+			continue
+		}
+		if !casiIgual(got, c.quiere) {
+			t.Errorf("parsearNumero(%q) = %v, quiere %v", c.entrada, got, c.quiere)
+		}
+	}
+
+	for _, entrada := range []string{"", "abc", "1.2.3,4,5", "NaN", "Inf"} {
+		if _, err := parsearNumero(entrada); !errors.Is(err, ErrValorInvalido) {
+			t.Errorf("parsearNumero(%q): quiere ErrValorInvalido, obtuvo %v", entrada, err)
+		}
+	}
+}
+
+func TestFraccionADecimal(t *testing.T) {
+	casos := []struct{ entrada, quiere string }{
+		{"1/2", "0.5"},
+		{"1 1/2", "1.5"},
+		{"1 1/2 tazas", "1.5 tazas"},
+		{"3/4 cdta", "0.75 cdta"},
+		{"2 tazas", "2 tazas"}, // Sin fracción no cambia
+		{"1/0", "1/0"},         // Denominador cero: se deja como está
+		{"25 MPa", "25 MPa"},
+	}
+	for _, c := range casos {
+		if got := fraccionADecimal(c.entrada); got != c.quiere {
+			t.Errorf("fraccionADecimal(%q) = %q, quiere %q", c.entrada, got, c.quiere)
+		}
+	}
+}
+
+func TestNormalizar(t *testing.T) {
+	casos := []struct {
+		valor, unidad string
+		quiere        float64
+		base          string
+	}{
+		{"25", "MPa", 25, "MPa"},
+		{"25 MPa", "", 25, "MPa"},
+		{"~25 MPa", "", 25, "MPa"}, // Valor aproximado
+		{"≈ 25", "MPa", 25, "MPa"},
+		{"25 MPa", "mpa", 25, "MPa"}, // La unidad del valor coincide con la indicada
+		{"1.234,5", "kPa", 1.2345, "MPa"},
+		{"1,234.5", "kPa", 1.2345, "MPa"},
+		{"1 1/2", "taza", 360, "mL"},
+		{"1 1/2 tazas", "", 360, "mL"},
+		{"2,5", "g/cm3", 2500, "kg/m³"},
+		{"212", "°F", 100, "°C"},
+		{"32 ºF", "", 0, "°C"},
+		{"0", "K", -273.15, "°C"},
+		{"70", "Shore A", 70, "Shore A"},
+		{"1000", "psi", 6.89475729, "MPa"},
+	}
+	for _, c := range casos {
+		m, err := Normalizar(c.valor, c.unidad)
+		if err != nil {
+			t.Errorf("Normalizar(%q, %q): error inesperado %v", c.valor, c.unidad, err)
+			continue
+		}
+		if !casiIgual(m.Valor, c.quiere) || m.Unidad.Simbolo != c.base {
+			t.Errorf("Normalizar(%q, %q) = %v %s, quiere %v %s", c.valor, c.unidad, m.Valor, m.Unidad.Simbolo, c.quiere, c.base)
+		}
+	}
+}
+
+func TestNormalizarErrores(t *testing.T) {
+	casos := []struct {
+		valor, unidad string
+		quiere        error
+	}{
+		{"25 MPa", "psi", ErrValorInvalido}, // El valor trae una unidad distinta de la indicada
+		{"alto", "MPa", ErrValorInvalido},
+		{"", "MPa", ErrValorInvalido},
+		{"25", "furlongs", ErrUnidadDesconocida},
+		{"25 parsecs", "", ErrUnidadDesconocida},
+	}
+	for _, c := range casos {
+		if _, err := Normalizar(c.valor, c.unidad); !errors.Is(err, c.quiere) {
+			t.Errorf("Normalizar(%q, %q): quiere %v, obtuvo %v", c.valor, c.unidad, c.quiere, err)
+		}
+	}
+}
+
+func TestConvertir(t *testing.T) {
+	casos := []struct {
+		valor        float64
+		desde, hasta string
+		quiere       float64
+	}{
+		{212, "°F", "°C", 100},
+		{-40, "°F", "°C", -40},
+		{100, "°C", "°F", 212},
+		{0, "°C", "K", 273.15},
+		{300, "K", "°F", 80.33},
+		{1, "GPa", "MPa", 1000},
+		{1, "ksi", "psi", 1000},
+		{1, "kg", "lb", 1 / 0.45359237},
+		{2, "L", "taza", 2000.0 / 240},
+		{1, "g/cm³", "kg/m3", 1000},
+	}
+	for _, c := range casos {
+		got, err := Convertir(c.valor, c.desde, c.hasta)
+		if err != nil {
+			t.Errorf("Convertir(%v, %q, %q): error inesperado %v", c.valor, c.desde, c.hasta, err)
+			continue
+		}
+		if math.Abs(got-c.quiere) > 1e-6*math.Max(1, math.Abs(c.quiere)) {
+			t.Errorf("Convertir(%v, %q, %q) = %v, quiere %v", c.valor, c.desde, c.hasta, got, c.quiere)
+		}
+	}
+
+	if _, err := Convertir(1, "kg", "mm"); !errors.Is(err, ErrUnidadesIncompatibles) {
+		t.Errorf("Convertir kg a mm: quiere ErrUnidadesIncompatibles, obtuvo %v", err)
+	}
+	if _, err := Convertir(1, "furlong", "mm"); !errors.Is(err, ErrUnidadDesconocida) {
+		t.Errorf("Convertir furlong a mm: quiere ErrUnidadDesconocida, obtuvo %v", err)
+	}
+	if _, err := Convertir(1, "mm", "furlong"); !errors.Is(err, ErrUnidadDesconocida) {
+		t.Errorf("Convertir mm a furlong: quiere ErrUnidadDesconocida, obtuvo %v", err)
+	}
+}
+
+func TestNormalizarCantidad(t *testing.T) {
+	m, err := NormalizarCantidad("  ")
+	if err != nil || m != nil {
+		t.Errorf("NormalizarCantidad vacía = %v, %v; quiere nil, nil", m, err)
+	}
+
+	m, err = NormalizarCantidad("3")
+	if err != nil || m.Unidad.Magnitud != Conteo || m.Valor != 3 {
+		t.Errorf("NormalizarCantidad(\"3\") = %+v, %v; quiere 3 u", m, err)
+	}
+
+	if _, err := NormalizarCantidad("25 MPa"); !errors.Is(err, ErrUnidadesIncompatibles) {
+		t.Errorf("NormalizarCantidad(\"25 MPa\"): quiere ErrUnidadesIncompatibles, obtuvo %v", err)
+	}
+}
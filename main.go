@@ -5,6 +5,7 @@ import (
 	"TT-SEM-2-BACK/api/handlers/material"
 	auth "TT-SEM-2-BACK/api/handlers/usuarios"
 	"TT-SEM-2-BACK/api/middleware"
+	"TT-SEM-2-BACK/api/storage"
 	"log"
 	"os"
 	"time"
@@ -38,6 +39,12 @@ func main() {
 
 	log.Println("✅ Base de datos conectada correctamente")
 
+	// Aplicar migraciones pendientes antes de aceptar peticiones
+	db, _ := database.GetDB()
+	if err := database.RunMigrations(db); err != nil {
+		log.Fatalf("❌ Error crítico: No se pudieron aplicar las migraciones: %v", err)
+	}
+
 	// Configuraracion CORS
 	corsConfig := cors.Config{
 		AllowOrigins:     []string{"https://tt-sem-2-front.vercel.app"},
@@ -56,6 +63,11 @@ func main() {
 	router := gin.Default()
 	router.Use(cors.New(corsConfig))
 
+	// Storage (Supabase o local). El backend local sirve sus archivos desde este mismo router
+	if err := storage.RegisterRoutes(router); err != nil {
+		log.Printf("⚠️ Storage no disponible, las subidas de archivos fallarán: %v", err)
+	}
+
 	// ========== RUTAS PÚBLICAS ==========
 	router.POST("/auth/register", auth.RegisterUserFromGoogle)
 
@@ -63,7 +75,17 @@ func main() {
 	router.GET("/materials", material.GetMaterials)
 	router.GET("/materials/:id", material.GetMaterial)
 	router.GET("/materials/:id/derived", material.GetDerivedMaterials)
+	router.GET("/materials/:id/similar", material.GetSimilarMaterials)
+	router.GET("/materials/:id/lineage", material.GetMaterialLineage)
+	router.GET("/materials/:id/diff-parent", material.GetMaterialDiffParent)
+	router.GET("/materials/:id/scaled", material.GetScaledMaterial)
 	router.GET("/materials/filters", material.GetMaterialFilters)
+	router.GET("/materials/search", material.SearchMaterials)
+	router.GET("/materials/by-property", material.GetMaterialsByProperty)
+	router.GET("/materials/compare", material.CompareMaterials)
+	router.GET("/units", material.GetUnits)
+	router.GET("/vocabulary", material.GetTerminos)
+	router.GET("/categories", material.GetCategorias)
 	router.GET("/materials-summary", material.GetMaterialsSummary)
 	router.GET("/users/:google_id/public", auth.GetPublicUserProfile)
 
@@ -74,13 +96,55 @@ func main() {
 		// Rutas generales
 		protected.GET("/me", auth.GetMe)
 		protected.POST("/users/request-role", auth.RequestCollaboratorRole)
+		protected.GET("/users/request-role", auth.GetMyRoleRequests)
+
+		// Invitaciones a colaborar (cualquier usuario puede recibirlas)
+		protected.GET("/invitations", material.GetMisInvitaciones)
+		protected.POST("/invitations/:id/accept", material.AcceptInvitacion)
+		protected.POST("/invitations/:id/decline", material.DeclineInvitacion)
+
+		// Materiales en los que el usuario tiene un rol (propietario, editor o lector). Cualquier usuario puede
+		// colaborar, así que el permiso no depende del rol global sino del rol en el material: lo revisa cada handler
+		protected.GET("/materials/:id/preview", material.GetMaterialPreview)
+		protected.PUT("/materials/:id", material.UpdateMaterial)
+
+		// Ciclo de vida (borrador -> pendiente -> aprobado/rechazado, archivado)
+		protected.POST("/materials/:id/submit", material.SubmitMaterial)
+		protected.POST("/materials/:id/withdraw", material.WithdrawMaterial)
+		protected.POST("/materials/:id/archive", material.ArchiveMaterial)
+		protected.POST("/materials/:id/unarchive", material.UnarchiveMaterial)
+
+		// Colaboradores del material: rol y estado de cada invitación. Solo el propietario los gestiona
+		protected.GET("/materials/:id/collaborators", material.GetColaboradores)
+		protected.POST("/materials/:id/collaborators", material.InviteColaborador)
+		protected.PUT("/materials/:id/collaborators/:invitacionId", material.UpdateColaboradorRol)
+		protected.DELETE("/materials/:id/collaborators/:invitacionId", material.RemoveColaborador)
+		protected.POST("/materials/:id/transfer", material.TransferMaterial)
+
+		// Revisiones de materiales publicados
+		protected.GET("/materials/:id/revisions", material.GetRevisions)
+		protected.GET("/materials/:id/revisions/diff", material.DiffRevisions)
+		protected.GET("/materials/:id/revisions/:numero", material.GetRevision)
+		protected.POST("/materials/:id/revisions/:numero/restore", material.RestoreRevision)
+
+		// Comentarios de moderación (el admin abre hilos, el propietario y los editores responden y resuelven)
+		protected.GET("/materials/:id/comments", material.GetComentarios)
+		protected.POST("/materials/:id/comments", material.CreateComentario)
+		protected.POST("/materials/:id/comments/:comentarioId/resolve", material.ResolveComentario)
+		protected.POST("/materials/:id/comments/:comentarioId/reopen", material.ReopenComentario)
 
 		// ========== RUTAS ADMINISTRADOR Y COLABORADOR ==========
 		adminCollab := protected.Group("/")
 		adminCollab.Use(middleware.RequireRole("administrador", "colaborador"))
 		{
 			adminCollab.POST("/materials", material.CreateMaterial)
-			adminCollab.PUT("/materials/:id", material.UpdateMaterial)
+			adminCollab.POST("/materials/:id/derive", material.DeriveMaterial)
+
+			// Catálogo de ingredientes y costo estimado
+			adminCollab.GET("/ingredients", material.GetIngredientes)
+			adminCollab.POST("/ingredients", material.CreateIngrediente)
+			adminCollab.PUT("/ingredients/:id", material.UpdateIngrediente)
+			adminCollab.GET("/materials/:id/cost", material.GetMaterialCost)
 
 			// Notificaciones
 			adminCollab.GET("/notifications", auth.GetNotifications)
@@ -99,11 +163,43 @@ func main() {
 			adminOnly.DELETE("/users/:google_id/hard", auth.HardDeleteUsuario)
 			adminOnly.GET("/users/stats", auth.GetDashboardStats)
 
+			// Solicitudes de rol
+			adminOnly.GET("/users/role-requests", auth.GetRoleRequests)
+			adminOnly.POST("/users/role-requests/:id/approve", auth.ApproveRoleRequest)
+			adminOnly.POST("/users/role-requests/:id/deny", auth.DenyRoleRequest)
+
 			// Materiales Pendientes y Moderación
 			adminOnly.GET("/materials/pending", material.GetMaterialsPendientes)
+			adminOnly.GET("/materials/pending-revisions", material.GetPendingRevisions)
+			adminOnly.GET("/materials/:id/review", material.GetMaterialReview)
 			adminOnly.POST("/materials/:id/approve", material.ApproveMaterial)
 			adminOnly.POST("/materials/:id/reject", material.RejectMaterial)
+			adminOnly.POST("/materials/:id/revisions/:numero/approve", material.ApproveRevision)
+			adminOnly.POST("/materials/:id/revisions/:numero/reject", material.RejectRevision)
 			adminOnly.DELETE("/materials/:id", material.DeleteMaterial)
+
+			// Explorador de administración: todos los estados, eliminados incluidos, con exportación CSV
+			adminOnly.GET("/admin/materials", material.GetMaterialsAdmin)
+			adminOnly.GET("/admin/materials/:id", material.GetMaterialAdmin)
+			adminOnly.POST("/admin/materials/:id/toggle-approval", material.ToggleApprovalMaterial)
+
+			// Vocabulario controlado (elementos y herramientas)
+			adminOnly.GET("/vocabulary/unmatched", material.GetTerminosSinCatalogar)
+			adminOnly.POST("/vocabulary", material.CreateTermino)
+			adminOnly.PUT("/vocabulary/:id", material.UpdateTermino)
+			adminOnly.DELETE("/vocabulary/:id", material.DeleteTermino)
+			adminOnly.POST("/vocabulary/:id/merge", material.MergeTermino)
+
+			// Árbol de categorías
+			adminOnly.POST("/categories", material.CreateCategoria)
+			adminOnly.PUT("/categories/:id", material.UpdateCategoria)
+			adminOnly.DELETE("/categories/:id", material.DeleteCategoria)
+
+			// Catálogo de ingredientes
+			adminOnly.DELETE("/ingredients/:id", material.DeleteIngrediente)
+
+			// Mantenimiento del storage
+			adminOnly.POST("/admin/storage/reconcile", material.ReconcileStorage)
 		}
 	}
 